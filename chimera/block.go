@@ -95,6 +95,13 @@ func (bs *blockScanner) Scan(data []byte, s *Scratch, h Handler, ctx interface{}
 		}()
 	}
 
+	release, err := s.acquire(bs.db, "scan")
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
 	return ch.Scan(bs.db, data, 0, s.s, h.OnMatch, h.OnError, ctx) //nolint: wrapcheck
 }
 
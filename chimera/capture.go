@@ -0,0 +1,91 @@
+package chimera
+
+import (
+	"sync"
+
+	"github.com/flier/gohs/hyperscan"
+	"github.com/flier/gohs/internal/ch"
+)
+
+var _ hyperscan.CaptureCompiler = CaptureCompiler
+
+// CaptureCompiler compiles a Hyperscan pattern with Chimera, to extract the submatches with hyperscan.WithCaptures.
+func CaptureCompiler(p *hyperscan.Pattern) (hyperscan.Capturer, error) {
+	db, err := NewBlockDatabase(NewPattern(p.Expression, compileFlags(p)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &capturer{BlockDatabase: db}, nil
+}
+
+// compileFlags maps the flags of a Hyperscan pattern to the Chimera ones.
+func compileFlags(p *hyperscan.Pattern) (flags CompileFlag) {
+	for hsFlag, chFlag := range map[hyperscan.CompileFlag]CompileFlag{
+		hyperscan.Caseless:        Caseless,
+		hyperscan.DotAll:          DotAll,
+		hyperscan.MultiLine:       MultiLine,
+		hyperscan.Utf8Mode:        Utf8Mode,
+		hyperscan.UnicodeProperty: UnicodeProperty,
+	} {
+		if p.Flags&hsFlag == hsFlag {
+			flags |= chFlag
+		}
+	}
+
+	return
+}
+
+// capturer pools the scratch spaces of the scans, which are freed once they are dropped from the pool.
+type capturer struct {
+	BlockDatabase
+
+	scratches sync.Pool
+}
+
+func (c *capturer) scratch() (*Scratch, error) {
+	if s, ok := c.scratches.Get().(*Scratch); ok {
+		return s, nil
+	}
+
+	return NewManagedScratch(c.BlockDatabase)
+}
+
+// FindSubmatchIndex returns the leftmost match ending at the end of data and its captured subexpressions.
+func (c *capturer) FindSubmatchIndex(data []byte) (loc []int) {
+	s, err := c.scratch()
+	if err != nil {
+		return nil
+	}
+
+	defer c.scratches.Put(s)
+
+	h := &ch.MatchRecorder{}
+
+	if err := c.Scan(data, s, h, nil); err != nil {
+		return nil
+	}
+
+	for _, e := range h.Events {
+		if int(e.To) != len(data) || (loc != nil && loc[0] <= int(e.From)) {
+			continue
+		}
+
+		loc = make([]int, 0, 2*(len(e.Captured)+1))
+		loc = append(loc, int(e.From), int(e.To))
+
+		for i, capture := range e.Captured {
+			if i == 0 {
+				continue // the whole match
+			}
+
+			if capture == nil {
+				loc = append(loc, -1, -1)
+			} else {
+				loc = append(loc, int(capture.From), int(capture.To))
+			}
+		}
+	}
+
+	return loc
+}
@@ -0,0 +1,66 @@
+package chimera_test
+
+import (
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/chimera"
+	"github.com/flier/gohs/hyperscan"
+)
+
+func TestCaptureCompiler(t *testing.T) {
+	Convey("Given a Hyperscan database with the capturing patterns", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `(\w+)@(\w+)\.com`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `(?i)id=(\d+)`, Flags: hyperscan.SomLeftMost, Id: 2},
+			},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("When extract the submatches with Chimera", func() {
+			m, err := hyperscan.WithCaptures(db.(hyperscan.BlockDatabase), chimera.CaptureCompiler)
+			So(err, ShouldBeNil)
+
+			defer m.Close()
+
+			res := m.FindAllSubmatch([]byte("foo@bar.com ID=42"), -1)
+
+			So(res[1], ShouldResemble, [][][]byte{{[]byte("foo@bar.com"), []byte("foo"), []byte("bar")}})
+			So(res[2], ShouldResemble, [][][]byte{{[]byte("ID=42"), []byte("42")}})
+		})
+
+		Convey("When extract the submatches from many goroutines", func() {
+			m, err := hyperscan.WithCaptures(db.(hyperscan.BlockDatabase), chimera.CaptureCompiler)
+			So(err, ShouldBeNil)
+
+			defer m.Close()
+
+			var wg sync.WaitGroup
+
+			found := make([]int, 8)
+
+			for i := range found {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					for j := 0; j < 50; j++ {
+						found[i] += len(m.FindAllSubmatch([]byte("a@b.com id=1 c@d.com"), -1)[1])
+					}
+				}(i)
+			}
+
+			wg.Wait()
+
+			So(found, ShouldResemble, []int{100, 100, 100, 100, 100, 100, 100, 100})
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package chimera
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 	"strconv"
@@ -144,6 +145,14 @@ func (b *DatabaseBuilder) Build() (Database, error) {
 
 	db, err := ch.CompileExtMulti(b.Patterns, b.Mode, platform, b.MatchLimit, b.MatchLimitRecursion)
 	if err != nil {
+		var compileErr *CompileError
+
+		if errors.As(err, &compileErr) && compileErr.Expression >= 0 && compileErr.Expression < len(b.Patterns) {
+			if p := b.Patterns[compileErr.Expression]; p.Pos.IsValid() {
+				return nil, fmt.Errorf("%s: pattern %d `%s`, %w", p.Pos, p.ID, p.Expression, err)
+			}
+		}
+
 		return nil, err //nolint: wrapcheck
 	}
 
@@ -0,0 +1,23 @@
+package chimera
+
+import (
+	"github.com/flier/gohs/hyperscan"
+)
+
+var _ hyperscan.Fallback = Fallback
+
+// Fallback compiles a pattern rejected by Hyperscan with Chimera, to build a database with hyperscan.BuildWithFallback.
+func Fallback(p *hyperscan.Pattern) (hyperscan.FallbackMatcher, error) {
+	db, err := NewBlockDatabase(NewPattern(p.Expression, compileFlags(p)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fallback{db}, nil
+}
+
+type fallback struct {
+	BlockDatabase
+}
+
+func (f *fallback) String() string { return "chimera" }
@@ -0,0 +1,36 @@
+package chimera_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/chimera"
+	"github.com/flier/gohs/hyperscan"
+)
+
+func TestFallback(t *testing.T) {
+	Convey("Given a pattern set with a pattern rejected by Hyperscan", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `(?U)a+b`, Flags: hyperscan.SomLeftMost, Id: 2},
+			},
+		}
+
+		Convey("When build it with the Chimera fallback", func() {
+			db, report, err := b.BuildWithFallback(chimera.Fallback)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			So(report.Rerouted, ShouldHaveLength, 1)
+			So(report.Rerouted[0].Id, ShouldEqual, 2)
+			So(report.Rerouted[0].Engine, ShouldEqual, "chimera")
+
+			Convey("Then the matches of both engines are reported", func() {
+				So(db.(hyperscan.BlockDatabase).FindAllStringIndex("aab foo", -1), ShouldResemble, [][]int{{0, 3}, {4, 7}})
+			})
+		})
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/flier/gohs/hyperscan"
 	"github.com/flier/gohs/internal/ch"
 )
 
@@ -75,12 +76,56 @@ func (p *Pattern) String() string {
 // Patterns is a set of matching patterns.
 type Patterns []*Pattern
 
-// ParsePatterns parse lines as `Patterns`.
+// Position is the origin of a pattern in a source file.
+type Position = hyperscan.Position
+
+// ParseError is the error of a line which failed to parse.
+type ParseError = hyperscan.ParseError
+
+// ParseErrors lists the errors of all the lines which failed to parse.
+type ParseErrors = hyperscan.ParseErrors
+
+// ParsePatterns parse lines as `Patterns`, each pattern carries its position.
+//
+// It stops at the first bad line and returns a *ParseError.
 func ParsePatterns(r io.Reader) (patterns Patterns, err error) {
+	patterns, errs, err := parsePatterns("", r, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return patterns, nil
+}
+
+// ParsePatternsFrom parse lines of the named file as `Patterns`, each pattern carries its position.
+//
+// It continues past the bad lines, and returns the parsed patterns and ParseErrors listing all the bad lines.
+func ParsePatternsFrom(filename string, r io.Reader) (Patterns, error) {
+	patterns, errs, err := parsePatterns(filename, r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return patterns, errs
+	}
+
+	return patterns, nil
+}
+
+func parsePatterns(filename string, r io.Reader, failFast bool) (patterns Patterns, errs ParseErrors, err error) {
 	s := bufio.NewScanner(r)
+	pos := Position{Filename: filename}
 
 	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
+		pos.Line++
+
+		text := s.Text()
+		line := strings.TrimSpace(text)
 
 		if line == "" {
 			// skip empty line
@@ -92,14 +137,27 @@ func ParsePatterns(r io.Reader) (patterns Patterns, err error) {
 			continue
 		}
 
+		pos.Column = strings.Index(text, line) + 1
+
 		p, err := ParsePattern(line)
 		if err != nil {
-			return nil, err
+			errs = append(errs, &ParseError{Position: pos, Err: err})
+
+			if failFast {
+				break
+			}
+
+			continue
 		}
 
+		p.Pos = pos
 		patterns = append(patterns, p)
 	}
 
+	if err := s.Err(); err != nil {
+		return nil, nil, fmt.Errorf("read patterns, %w", err)
+	}
+
 	return
 }
 
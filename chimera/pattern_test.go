@@ -1,6 +1,8 @@
 package chimera_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -80,3 +82,47 @@ func TestPattern(t *testing.T) {
 		})
 	})
 }
+
+func TestParsePatterns(t *testing.T) {
+	Convey("Given a rule file with bad lines", t, func() {
+		rules := `# comment
+1:/foo/i
+
+  2:/a/Z
+3:/bar/s
+4:/baz/Q-
+`
+
+		Convey("When parse the patterns", func() {
+			_, err := chimera.ParsePatterns(strings.NewReader(rules))
+
+			Convey("Then it stops at the first bad line", func() {
+				var parseErr *chimera.ParseError
+
+				So(errors.As(err, &parseErr), ShouldBeTrue)
+				So(parseErr.Position, ShouldResemble, chimera.Position{Line: 4, Column: 3})
+				So(err.Error(), ShouldStartWith, "4:3: ")
+			})
+		})
+
+		Convey("When parse the patterns of a file", func() {
+			patterns, err := chimera.ParsePatternsFrom("rules.txt", strings.NewReader(rules))
+
+			Convey("Then the patterns carry their positions", func() {
+				So(patterns, ShouldHaveLength, 2)
+				So(patterns[0].Pos, ShouldResemble, chimera.Position{Filename: "rules.txt", Line: 2, Column: 1})
+				So(patterns[1].Pos.String(), ShouldEqual, "rules.txt:5:1")
+			})
+
+			Convey("Then all the bad lines are reported", func() {
+				var parseErrs chimera.ParseErrors
+
+				So(errors.As(err, &parseErrs), ShouldBeTrue)
+				So(parseErrs, ShouldHaveLength, 2)
+				So(parseErrs[0].String(), ShouldEqual, "rules.txt:4:3")
+				So(parseErrs[1].String(), ShouldEqual, "rules.txt:6:1")
+				So(parseErrs.Unwrap(), ShouldHaveLength, 2)
+			})
+		})
+	})
+}
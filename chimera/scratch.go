@@ -1,14 +1,40 @@
 package chimera
 
 import (
+	"os"
 	"runtime"
 
 	"github.com/flier/gohs/internal/ch"
+	"github.com/flier/gohs/internal/guard"
 )
 
+// ScratchCheckEnv is the environment variable which enables the checked scratch mode if set to a non-empty value.
+const ScratchCheckEnv = "GOHS_SCRATCHCHECK"
+
+var scratchCheck = newScratchCheck()
+
+func newScratchCheck() *guard.Checker {
+	c := &guard.Checker{InUse: ErrScratchInUse, Invalid: ErrInvalid}
+
+	c.Set(os.Getenv(ScratchCheckEnv) != "")
+
+	return c
+}
+
+// SetScratchCheck enables or disables the checked scratch mode, and returns whether it was enabled.
+//
+// See hyperscan.SetScratchCheck for the checks of the scratch spaces.
+func SetScratchCheck(enabled bool) bool { return scratchCheck.Set(enabled) }
+
+// ScratchCheckEnabled returns whether the checked scratch mode is enabled.
+func ScratchCheckEnabled() bool { return scratchCheck.Enabled() }
+
 // Scratch is a Chimera scratch space.
 type Scratch struct {
 	s ch.Scratch
+
+	// The guard of the scratch space, if allocated in the checked scratch mode.
+	guard *guard.Guard
 }
 
 // NewScratch allocate a "scratch" space for use by Chimera.
@@ -20,7 +46,7 @@ func NewScratch(db Database) (*Scratch, error) {
 		return nil, err //nolint: wrapcheck
 	}
 
-	return &Scratch{s}, nil
+	return &Scratch{s, scratchCheck.New(db.(database).c())}, nil
 }
 
 // NewManagedScratch is a wrapper for NewScratch that sets
@@ -45,7 +71,13 @@ func (s *Scratch) Size() (int, error) { return ch.ScratchSize(s.s) } //nolint: w
 func (s *Scratch) Realloc(db Database) error {
 	r, _ := db.(database)
 
-	return ch.ReallocScratch(r.c(), &s.s) //nolint: wrapcheck
+	if err := ch.ReallocScratch(r.c(), &s.s); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	s.guard.Realloc(r.c())
+
+	return nil
 }
 
 // Clone allocate a scratch space that is a clone of an existing scratch space.
@@ -55,8 +87,17 @@ func (s *Scratch) Clone() (*Scratch, error) {
 		return nil, err //nolint: wrapcheck
 	}
 
-	return &Scratch{cloned}, nil
+	return &Scratch{cloned, s.guard.Clone()}, nil
 }
 
 // Free a scratch block previously allocated.
 func (s *Scratch) Free() error { return ch.FreeScratch(s.s) } //nolint: wrapcheck
+
+// acquire holds the scratch space for scanning the database until the returned function is called.
+func (s *Scratch) acquire(db ch.Database, op string) (func(), error) {
+	if err := s.guard.Check(db, op); err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	return s.guard.Acquire(op) //nolint: wrapcheck
+}
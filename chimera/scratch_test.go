@@ -0,0 +1,77 @@
+package chimera_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/chimera"
+)
+
+var noMatch = chimera.HandlerFunc(func(id uint, from, to uint64, flags uint,
+	captured []*chimera.Capture, context interface{},
+) chimera.Callback {
+	return chimera.Continue
+})
+
+//nolint:funlen
+func TestScratchCheck(t *testing.T) {
+	Convey("Given a scratch allocated in the checked scratch mode", t, func() {
+		enabled := chimera.SetScratchCheck(true)
+
+		Reset(func() {
+			chimera.SetScratchCheck(enabled)
+		})
+
+		So(chimera.ScratchCheckEnabled(), ShouldBeTrue)
+
+		foo, err := chimera.NewBlockDatabase(chimera.NewPattern(`foo\d+`, 0))
+		So(err, ShouldBeNil)
+
+		defer foo.Close()
+
+		bar, err := chimera.NewBlockDatabase(chimera.NewPattern(`bar\d+`, 0))
+		So(err, ShouldBeNil)
+
+		defer bar.Close()
+
+		s, err := chimera.NewScratch(foo)
+		So(err, ShouldBeNil)
+
+		defer s.Free()
+
+		Convey("When scan another database with it", func() {
+			err := bar.Scan([]byte("bar1"), s, noMatch, nil)
+
+			Convey("Then it fails with the call sites", func() {
+				So(errors.Is(err, chimera.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "scan with scratch allocated for 1 other databases")
+				So(err.Error(), ShouldContainSubstring, "TestScratchCheck")
+			})
+
+			Convey("Then it passes once reallocated", func() {
+				So(s.Realloc(bar), ShouldBeNil)
+				So(bar.Scan([]byte("bar1"), s, noMatch, nil), ShouldBeNil)
+			})
+		})
+
+		Convey("When scan with it while it is in use", func() {
+			var inner error
+
+			err := foo.Scan([]byte("foo1"), s, chimera.HandlerFunc(func(id uint, from, to uint64, flags uint,
+				captured []*chimera.Capture, context interface{},
+			) chimera.Callback {
+				inner = foo.Scan([]byte("foo2"), s, noMatch, nil)
+
+				return chimera.Continue
+			}), nil)
+
+			Convey("Then the nested scan fails with the call sites", func() {
+				So(err, ShouldBeNil)
+				So(errors.Is(inner, chimera.ErrScratchInUse), ShouldBeTrue)
+				So(inner.Error(), ShouldContainSubstring, "scan with scratch in use by goroutine")
+			})
+		})
+	})
+}
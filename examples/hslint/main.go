@@ -0,0 +1,92 @@
+/*
+ * Hyperscan example program 3: hslint
+ *
+ * This example checks the pattern files for the patterns which cause trouble:
+ * the ones matching the empty data or only at the end of data, with unbounded
+ * width in streaming mode, returning unordered matches, with redundant flags,
+ * duplicate or subsumed patterns, duplicate IDs and risky constructs.
+ *
+ * The pattern files are in the format of the pcapscan example, with the
+ * `%include` and `%define` directives of the preprocessor.
+ *
+ * Build instructions:
+ *
+ *     go build github.com/flier/gohs/examples/hslint
+ *
+ * Usage:
+ *
+ *     ./hslint [-stream] [-severity info|warning|error] <pattern file>...
+ *
+ * The program exits with status 1 if any error is found.
+ *
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+var (
+	flagStream   = flag.Bool("stream", false, "Check the patterns for the streaming mode.")
+	flagSeverity = flag.String("severity", "info", "The minimum severity of the reported findings.")
+)
+
+func parseSeverity(s string) (hyperscan.Severity, bool) {
+	for _, severity := range []hyperscan.Severity{hyperscan.SeverityInfo, hyperscan.SeverityWarning, hyperscan.SeverityError} {
+		if severity.String() == s {
+			return severity, true
+		}
+	}
+
+	return 0, false
+}
+
+func main() {
+	flag.Parse()
+
+	minSeverity, ok := parseSeverity(*flagSeverity)
+	if flag.NArg() < 1 || !ok {
+		_, prog := filepath.Split(os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-stream] [-severity info|warning|error] <pattern file>...\n", prog)
+		os.Exit(-1)
+	}
+
+	var patterns hyperscan.Patterns
+
+	pp := hyperscan.NewPreprocessor()
+	status := 0
+
+	for _, filename := range flag.Args() {
+		parsed, err := pp.ParseFile(filename)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+
+		patterns = append(patterns, parsed...)
+	}
+
+	b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.BlockMode}
+	if *flagStream {
+		b.Mode = hyperscan.StreamMode
+	}
+
+	for _, finding := range b.Lint() {
+		if finding.Severity < minSeverity {
+			continue
+		}
+
+		fmt.Println(finding)
+
+		if finding.Severity == hyperscan.SeverityError {
+			status = 1
+		}
+	}
+
+	os.Exit(status)
+}
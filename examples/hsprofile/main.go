@@ -0,0 +1,91 @@
+/*
+ * Hyperscan example program 4: hsprofile
+ *
+ * This example profiles the cost of each pattern of a large rule set: it
+ * compiles each pattern in isolation and the whole set without each group of
+ * patterns, then records the size of the database, the stream state and the
+ * scratch space, and the throughput of scanning the corpus files.
+ *
+ * The patterns are ranked by the cost saved when they are left out, so the
+ * rules which suddenly grew the database or the scan time come first.
+ *
+ * Build instructions:
+ *
+ *     go build github.com/flier/gohs/examples/hsprofile
+ *
+ * Usage:
+ *
+ *     ./hsprofile [-stream] [-n repeats] [-group size] [-top n] [-rank size|stream|scratch|scan] <pattern file> [corpus file]...
+ *
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+var (
+	flagStream = flag.Bool("stream", false, "Profile the patterns for the streaming mode.")
+	flagRepeat = flag.Int("n", 1, "Repeating the corpus scan several times.")
+	flagGroup  = flag.Int("group", 0, "The number of patterns left out together, √N if zero, none if negative.")
+	flagTop    = flag.Int("top", 20, "The number of reported patterns, all the patterns if negative.")
+	flagRank   = flag.String("rank", "size", "The metric used to rank the patterns.")
+)
+
+var metrics = map[string]hyperscan.ProfileMetric{
+	"size":    hyperscan.RankBySize,
+	"stream":  hyperscan.RankByStreamSize,
+	"scratch": hyperscan.RankByScratchSize,
+	"scan":    hyperscan.RankByScanTime,
+}
+
+func main() {
+	flag.Parse()
+
+	metric, ok := metrics[*flagRank]
+	if flag.NArg() < 1 || !ok {
+		_, prog := filepath.Split(os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [-stream] [-n repeats] [-group size] [-top n] "+
+			"[-rank size|stream|scratch|scan] <pattern file> [corpus file]...\n", prog)
+		os.Exit(-1)
+	}
+
+	patterns, err := hyperscan.NewPreprocessor().ParseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to parse patterns: %s\n", err)
+		os.Exit(-1)
+	}
+
+	opts := &hyperscan.ProfileOptions{Mode: hyperscan.BlockMode, Repeat: *flagRepeat, GroupSize: *flagGroup, RankBy: metric}
+	if *flagStream {
+		opts.Mode = hyperscan.StreamMode
+	}
+
+	for _, filename := range flag.Args()[1:] {
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Unable to read corpus: %s\n", err)
+			os.Exit(-1)
+		}
+
+		opts.Corpus = append(opts.Corpus, data)
+	}
+
+	report, err := patterns.Profile(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Unable to profile patterns: %s\n", err)
+		os.Exit(-1)
+	}
+
+	report.Patterns = report.Top(*flagTop)
+
+	if _, err := report.WriteTo(os.Stdout); err != nil {
+		os.Exit(-1)
+	}
+}
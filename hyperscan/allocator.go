@@ -0,0 +1,210 @@
+package hyperscan
+
+import (
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// AllocFunc is the type of the callback function used by Hyperscan to allocate memory,
+// which returns nil if the allocation fails.
+type AllocFunc = hs.AllocFunc
+
+// FreeFunc is the type of the callback function used by Hyperscan to free memory.
+type FreeFunc = hs.FreeFunc
+
+// SetAllocator sets the allocate and free functions used by Hyperscan for all the categories of memory,
+// the default allocator of the C runtime is used if any of them is nil.
+func SetAllocator(alloc AllocFunc, free FreeFunc) error { return hs.SetAllocator(alloc, free) } //nolint: wrapcheck
+
+// ClearAllocator restores the default allocator for all the categories of memory.
+func ClearAllocator() error { return hs.ClearAllocator() } //nolint: wrapcheck
+
+// SetDatabaseAllocator sets the allocate and free functions used by Hyperscan for the compiled databases.
+func SetDatabaseAllocator(alloc AllocFunc, free FreeFunc) error {
+	return hs.SetDatabaseAllocator(alloc, free) //nolint: wrapcheck
+}
+
+// ClearDatabaseAllocator restores the default allocator for the compiled databases.
+func ClearDatabaseAllocator() error { return hs.ClearDatabaseAllocator() } //nolint: wrapcheck
+
+// SetScratchAllocator sets the allocate and free functions used by Hyperscan for the scratch spaces.
+func SetScratchAllocator(alloc AllocFunc, free FreeFunc) error {
+	return hs.SetScratchAllocator(alloc, free) //nolint: wrapcheck
+}
+
+// ClearScratchAllocator restores the default allocator for the scratch spaces.
+func ClearScratchAllocator() error { return hs.ClearScratchAllocator() } //nolint: wrapcheck
+
+// SetStreamAllocator sets the allocate and free functions used by Hyperscan for the stream states.
+func SetStreamAllocator(alloc AllocFunc, free FreeFunc) error {
+	return hs.SetStreamAllocator(alloc, free) //nolint: wrapcheck
+}
+
+// ClearStreamAllocator restores the default allocator for the stream states.
+func ClearStreamAllocator() error { return hs.ClearStreamAllocator() } //nolint: wrapcheck
+
+// SetMiscAllocator sets the allocate and free functions used by Hyperscan for the miscellaneous data,
+// such as the compile errors, the expression info and the database info.
+func SetMiscAllocator(alloc AllocFunc, free FreeFunc) error {
+	return hs.SetMiscAllocator(alloc, free) //nolint: wrapcheck
+}
+
+// ClearMiscAllocator restores the default allocator for the miscellaneous data.
+func ClearMiscAllocator() error { return hs.ClearMiscAllocator() } //nolint: wrapcheck
+
+// AllocCategory is the category of the memory allocated by Hyperscan.
+type AllocCategory int
+
+const (
+	// DatabaseMemory is the memory of the compiled databases.
+	DatabaseMemory AllocCategory = iota
+	// ScratchMemory is the memory of the scratch spaces.
+	ScratchMemory
+	// StreamMemory is the memory of the stream states.
+	StreamMemory
+	// MiscMemory is the memory of the miscellaneous data.
+	MiscMemory
+
+	allocCategories
+)
+
+var allocCategoryNames = [allocCategories]string{"database", "scratch", "stream", "misc"}
+
+func (c AllocCategory) String() string {
+	if c < 0 || c >= allocCategories {
+		return fmt.Sprintf("AllocCategory(%d)", int(c))
+	}
+
+	return allocCategoryNames[c]
+}
+
+// AllocStats are the statistics of the memory of a category allocated by AccountingAllocator.
+type AllocStats struct {
+	LiveBytes  int64 // The bytes allocated and not freed.
+	LiveAllocs int64 // The allocations not freed.
+	PeakBytes  int64 // The largest number of live bytes.
+	Allocs     int64 // The number of allocations.
+	Frees      int64 // The number of frees.
+	Failures   int64 // The number of allocations refused by the budget or failed.
+	Budget     int64 // The largest number of live bytes, unlimited if zero.
+}
+
+// AccountingAllocator allocates the memory of Hyperscan with the allocator of the C runtime,
+// and accounts the live bytes and the allocations per category.
+//
+// An allocation which would exceed the budget of its category fails,
+// so the Hyperscan function allocating the memory returns ErrNoMemory.
+type AccountingAllocator struct {
+	lock  sync.Mutex
+	stats [allocCategories]AllocStats
+	sizes map[unsafe.Pointer]allocation
+}
+
+type allocation struct {
+	category AllocCategory
+	size     int64
+}
+
+// NewAccountingAllocator returns an accounting allocator without budget.
+func NewAccountingAllocator() *AccountingAllocator {
+	return &AccountingAllocator{sizes: make(map[unsafe.Pointer]allocation)}
+}
+
+// SetBudget sets the largest number of live bytes of the category, unlimited if zero.
+func (a *AccountingAllocator) SetBudget(c AllocCategory, bytes int64) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.stats[c].Budget = bytes
+}
+
+// Stats returns the statistics of the category.
+func (a *AccountingAllocator) Stats(c AllocCategory) AllocStats {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.stats[c]
+}
+
+// Alloc returns the allocate function of the category.
+func (a *AccountingAllocator) Alloc(c AllocCategory) AllocFunc {
+	return func(size uint) unsafe.Pointer {
+		a.lock.Lock()
+		defer a.lock.Unlock()
+
+		stats := &a.stats[c]
+
+		if stats.Budget > 0 && stats.LiveBytes+int64(size) > stats.Budget {
+			stats.Failures++
+
+			return nil
+		}
+
+		p := hs.DefaultAlloc(size)
+		if p == nil {
+			stats.Failures++
+
+			return nil
+		}
+
+		a.sizes[p] = allocation{c, int64(size)}
+
+		stats.Allocs++
+		stats.LiveAllocs++
+		stats.LiveBytes += int64(size)
+
+		if stats.LiveBytes > stats.PeakBytes {
+			stats.PeakBytes = stats.LiveBytes
+		}
+
+		return p
+	}
+}
+
+// Free returns the free function of the category,
+// which frees the memory allocated before the allocator was installed without accounting it.
+func (a *AccountingAllocator) Free(c AllocCategory) FreeFunc {
+	return func(p unsafe.Pointer) {
+		if p == nil {
+			return
+		}
+
+		a.lock.Lock()
+
+		if alloc, ok := a.sizes[p]; ok {
+			delete(a.sizes, p)
+
+			stats := &a.stats[alloc.category]
+			stats.Frees++
+			stats.LiveAllocs--
+			stats.LiveBytes -= alloc.size
+		}
+
+		a.lock.Unlock()
+
+		hs.DefaultFree(p)
+	}
+}
+
+// Install sets the allocator for all the categories of memory.
+func (a *AccountingAllocator) Install() error {
+	for c, set := range []func(AllocFunc, FreeFunc) error{
+		SetDatabaseAllocator, SetScratchAllocator, SetStreamAllocator, SetMiscAllocator,
+	} {
+		if err := set(a.Alloc(AllocCategory(c)), a.Free(AllocCategory(c))); err != nil {
+			return fmt.Errorf("set %s allocator, %w", AllocCategory(c), err)
+		}
+	}
+
+	return nil
+}
+
+// Uninstall restores the default allocator for all the categories of memory.
+//
+// The memory allocated by the accounting allocator could still be freed by the default allocator.
+func (a *AccountingAllocator) Uninstall() error {
+	return ClearAllocator()
+}
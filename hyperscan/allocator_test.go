@@ -0,0 +1,112 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestAccountingAllocator(t *testing.T) {
+	Convey("Given an installed accounting allocator", t, func() {
+		a := hyperscan.NewAccountingAllocator()
+		So(a.Install(), ShouldBeNil)
+
+		Reset(func() {
+			So(a.Uninstall(), ShouldBeNil)
+		})
+
+		Convey("When compile a stream database and open a stream", func() {
+			db, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+			So(err, ShouldBeNil)
+
+			st, err := db.Open(0, nil, nil, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then the memory is accounted per category", func() {
+				for _, c := range []hyperscan.AllocCategory{
+					hyperscan.DatabaseMemory, hyperscan.ScratchMemory, hyperscan.StreamMemory,
+				} {
+					stats := a.Stats(c)
+					So(stats.LiveAllocs, ShouldBeGreaterThan, 0)
+					So(stats.LiveBytes, ShouldBeGreaterThan, 0)
+					So(stats.PeakBytes, ShouldBeGreaterThanOrEqualTo, stats.LiveBytes)
+				}
+
+				So(st.Close(), ShouldBeNil)
+				So(db.Close(), ShouldBeNil)
+
+				stats := a.Stats(hyperscan.DatabaseMemory)
+				So(stats.LiveAllocs, ShouldEqual, 0)
+				So(stats.LiveBytes, ShouldEqual, 0)
+				So(stats.Frees, ShouldEqual, stats.Allocs)
+
+				So(a.Stats(hyperscan.StreamMemory).LiveBytes, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When marshal a database and query its info", func() {
+			db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			data, err := db.Marshal()
+			So(err, ShouldBeNil)
+
+			_, err = db.Info()
+			So(err, ShouldBeNil)
+
+			_, err = hyperscan.SerializedDatabaseInfo(data)
+			So(err, ShouldBeNil)
+
+			Convey("Then the misc memory is released", func() {
+				stats := a.Stats(hyperscan.MiscMemory)
+				So(stats.Allocs, ShouldBeGreaterThanOrEqualTo, 3)
+				So(stats.LiveAllocs, ShouldEqual, 0)
+				So(stats.LiveBytes, ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the stream budget is exceeded", func() {
+			db, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			s, err := hyperscan.NewScratch(db)
+			So(err, ShouldBeNil)
+
+			defer s.Free()
+
+			st, err := db.Open(0, s, nil, nil)
+			So(err, ShouldBeNil)
+
+			a.SetBudget(hyperscan.StreamMemory, a.Stats(hyperscan.StreamMemory).LiveBytes)
+
+			Convey("Then opening another stream fails with ErrNoMemory", func() {
+				_, err := db.Open(0, s, nil, nil)
+				So(errors.Is(err, hyperscan.ErrNoMemory), ShouldBeTrue)
+
+				stats := a.Stats(hyperscan.StreamMemory)
+				So(stats.Failures, ShouldEqual, 1)
+				So(stats.LiveAllocs, ShouldEqual, 1)
+
+				So(st.Close(), ShouldBeNil)
+
+				st, err = db.Open(0, s, nil, nil)
+				So(err, ShouldBeNil)
+				So(st.Close(), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given the categories of memory", t, func() {
+		So(hyperscan.DatabaseMemory.String(), ShouldEqual, "database")
+		So(hyperscan.MiscMemory.String(), ShouldEqual, "misc")
+		So(hyperscan.AllocCategory(42).String(), ShouldEqual, "AllocCategory(42)")
+	})
+}
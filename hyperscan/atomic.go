@@ -0,0 +1,846 @@
+package hyperscan
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AtomicDatabase is a database handle which could be swapped while it is used by the other goroutines.
+//
+// Each scan pins the current version of the database, and each stream pins it until the stream is closed,
+// so Swap publishes a new version for the following scans, and the old version is closed once it isn't pinned anymore.
+// The scratch spaces of the scans and streams without scratch are pooled per version,
+// and Swap allocates the pool of the new version with as many scratch spaces as the pool of the old one.
+// A stream holds its scratch space until it is closed.
+//
+// A scratch given to a scan or a stream, which was allocated for another version, is grown with Scratch.Realloc
+// for the pinned version. A scratch space reallocated by Hyperscan is still usable with the databases
+// it was allocated for, so the streams using it could still scan with it.
+//
+// Block, Stream and Vectored return the views implementing the database interfaces of each mode.
+type AtomicDatabase struct {
+	lock    sync.Mutex
+	current *dbVersion
+}
+
+// NewAtomicDatabase creates a handle of the database, which is owned by the handle.
+func NewAtomicDatabase(db Database) *AtomicDatabase {
+	v, _ := newDBVersion(db, 0)
+
+	return &AtomicDatabase{current: v}
+}
+
+// dbVersion is a version of the database, which is closed with its pooled scratch spaces once it isn't pinned.
+type dbVersion struct {
+	db   Database
+	refs int32
+
+	lock      sync.Mutex
+	scratches []*Scratch
+}
+
+// newDBVersion creates a version of the database with a pool of n scratch spaces.
+func newDBVersion(db Database, n int) (*dbVersion, error) {
+	v := &dbVersion{db: db, refs: 1}
+
+	for i := 0; i < n; i++ {
+		s, err := newScratch(db)
+		if err != nil {
+			v.freeScratches()
+
+			return nil, fmt.Errorf("allocate scratch, %w", err)
+		}
+
+		v.scratches = append(v.scratches, s)
+	}
+
+	return v, nil
+}
+
+func (v *dbVersion) acquire() { atomic.AddInt32(&v.refs, 1) }
+
+func (v *dbVersion) release() {
+	if atomic.AddInt32(&v.refs, -1) > 0 {
+		return
+	}
+
+	v.freeScratches()
+
+	_ = v.db.Close()
+}
+
+func (v *dbVersion) freeScratches() {
+	for _, s := range v.scratches {
+		_ = s.Free()
+	}
+
+	v.scratches = nil
+}
+
+// pooled returns the number of the pooled scratch spaces.
+func (v *dbVersion) pooled() int {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return len(v.scratches)
+}
+
+// owns returns whether the scratch space was allocated for the version.
+func (v *dbVersion) owns(s *Scratch) bool {
+	d, ok := v.db.(database)
+
+	return !ok || s.db == d.c()
+}
+
+// adopt grows the scratch space for the version, if it was allocated for another version.
+func (v *dbVersion) adopt(s *Scratch) error {
+	if v.owns(s) {
+		return nil
+	}
+
+	if err := s.Realloc(v.db); err != nil {
+		return fmt.Errorf("realloc scratch for the version, %w", err)
+	}
+
+	return nil
+}
+
+// scratch takes a scratch space of the version from the pool, or allocates one.
+func (v *dbVersion) scratch() (*Scratch, error) {
+	v.lock.Lock()
+
+	if n := len(v.scratches); n > 0 {
+		s := v.scratches[n-1]
+		v.scratches = v.scratches[:n-1]
+		v.lock.Unlock()
+
+		return s, nil
+	}
+
+	v.lock.Unlock()
+
+	return newScratch(v.db)
+}
+
+func (v *dbVersion) put(s *Scratch) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.scratches = append(v.scratches, s)
+}
+
+// pinned returns the scratch for a scan of the version, and the function to return the scratch to the pool.
+//
+// The given scratch is used after growing it for the version if needed.
+func (v *dbVersion) pinned(s *Scratch) (*Scratch, func(), error) {
+	if s != nil {
+		if err := v.adopt(s); err != nil {
+			return nil, nil, err
+		}
+
+		return s, func() {}, nil
+	}
+
+	s, err := v.scratch()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s, func() { v.put(s) }, nil
+}
+
+// acquire pins the current version.
+func (a *AtomicDatabase) acquire() (*dbVersion, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.current == nil {
+		return nil, fmt.Errorf("closed database, %w", ErrInvalid)
+	}
+
+	a.current.acquire()
+
+	return a.current, nil
+}
+
+// pinner pins the current version of a swappable database.
+type pinner interface {
+	pin() (Database, func(), error)
+}
+
+// pin pins the current version of the database until the returned function is called.
+func (a *AtomicDatabase) pin() (Database, func(), error) {
+	v, err := a.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return v.db, v.release, nil
+}
+
+// Load pins the current version of the database until the returned function is called.
+func (a *AtomicDatabase) Load() (Database, func(), error) { return a.pin() }
+
+// Swap publishes the database as the new version, which must be of the same mode as the current one,
+// and closes the old version once it isn't pinned anymore.
+//
+// The database is owned by the handle if it is swapped in.
+func (a *AtomicDatabase) Swap(db Database) error {
+	old, err := a.acquire()
+	if err != nil {
+		return fmt.Errorf("swap closed database, %w", ErrInvalid)
+	}
+
+	mode, n := modeOf(old.db), old.pooled()
+
+	old.release()
+
+	if modeOf(db) != mode {
+		return fmt.Errorf("swap database of another mode, %w", ErrInvalid)
+	}
+
+	if n == 0 {
+		n = 1
+	}
+
+	v, err := newDBVersion(db, n)
+	if err != nil {
+		return err
+	}
+
+	a.lock.Lock()
+
+	old = a.current
+
+	if old == nil {
+		a.lock.Unlock()
+		v.freeScratches()
+
+		return fmt.Errorf("swap closed database, %w", ErrInvalid)
+	}
+
+	a.current = v
+	a.lock.Unlock()
+
+	old.release()
+
+	return nil
+}
+
+// modeOf returns the mode of the database.
+func modeOf(db Database) ModeFlag {
+	switch db.(type) {
+	case StreamDatabase:
+		return StreamMode
+	case VectoredDatabase:
+		return VectoredMode
+	case BlockDatabase:
+		return BlockMode
+	default:
+		return 0
+	}
+}
+
+// Info provides information about the current version.
+func (a *AtomicDatabase) Info() (DbInfo, error) {
+	db, release, err := a.pin()
+	if err != nil {
+		return "", err
+	}
+
+	defer release()
+
+	return db.Info() //nolint: wrapcheck
+}
+
+// Size provides the size of the current version in bytes.
+func (a *AtomicDatabase) Size() (int, error) {
+	db, release, err := a.pin()
+	if err != nil {
+		return 0, err
+	}
+
+	defer release()
+
+	return db.Size() //nolint: wrapcheck
+}
+
+// Marshal serializes the current version.
+func (a *AtomicDatabase) Marshal() ([]byte, error) {
+	db, release, err := a.pin()
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	return db.Marshal() //nolint: wrapcheck
+}
+
+// Unmarshal reconstructs a database of the same mode from the bytes, and swaps it in.
+func (a *AtomicDatabase) Unmarshal(b []byte) error {
+	db, release, err := a.pin()
+	if err != nil {
+		return err
+	}
+
+	mode := modeOf(db)
+
+	release()
+
+	ndb, err := unmarshalDatabase(b, mode)
+	if err != nil {
+		return err
+	}
+
+	if err = a.Swap(ndb); err != nil {
+		_ = ndb.Close()
+	}
+
+	return err
+}
+
+// Close releases the current version, which is closed once it isn't pinned anymore.
+func (a *AtomicDatabase) Close() error {
+	a.lock.Lock()
+
+	v := a.current
+	a.current = nil
+
+	a.lock.Unlock()
+
+	if v == nil {
+		return fmt.Errorf("close closed database, %w", ErrInvalid)
+	}
+
+	v.release()
+
+	return nil
+}
+
+// Watch polls the file every interval, and swaps in the database loaded from the file when it is changed.
+//
+// The errors of loading the file are reported to onError if not nil, the current version is kept,
+// and the file is reloaded on each tick until it is swapped in.
+// The returned function stops the polling.
+func (a *AtomicDatabase) Watch(path string, interval time.Duration,
+	load func(path string) (Database, error), onError func(err error),
+) (stop func()) {
+	report := func(err error) {
+		if onError != nil {
+			onError(err)
+		}
+	}
+
+	last, _ := os.Stat(path)
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			fi, err := os.Stat(path)
+			if err != nil {
+				report(fmt.Errorf("watch %s, %w", path, err))
+
+				continue
+			}
+
+			if last != nil && fi.ModTime().Equal(last.ModTime()) && fi.Size() == last.Size() {
+				continue
+			}
+
+			db, err := load(path)
+			if err != nil {
+				report(fmt.Errorf("reload %s, %w", path, err))
+
+				continue
+			}
+
+			if err = a.Swap(db); err != nil {
+				_ = db.Close()
+
+				report(err)
+
+				continue
+			}
+
+			// The file is reloaded again on the next tick until it is swapped in.
+			last = fi
+		}
+	}()
+
+	var once sync.Once
+
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// RuleFileLoader returns a loader of Watch, which compiles the patterns of a rule file in the mode.
+func RuleFileLoader(mode ModeFlag) func(path string) (Database, error) {
+	return func(path string) (Database, error) {
+		patterns, err := NewPreprocessor().ParseFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		b := DatabaseBuilder{Patterns: patterns, Mode: mode}
+
+		return b.Build()
+	}
+}
+
+// SerializedFileLoader returns a loader of Watch, which reconstructs a serialized database of the mode.
+func SerializedFileLoader(mode ModeFlag) func(path string) (Database, error) {
+	return func(path string) (Database, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read database, %w", err)
+		}
+
+		return unmarshalDatabase(data, mode)
+	}
+}
+
+// Block returns the view of a block database.
+func (a *AtomicDatabase) Block() BlockDatabase { return atomicBlockDatabase{a} }
+
+// Stream returns the view of a streaming database.
+func (a *AtomicDatabase) Stream() StreamDatabase { return atomicStreamDatabase{a} }
+
+// Vectored returns the view of a vectored database.
+func (a *AtomicDatabase) Vectored() VectoredDatabase { return atomicVectoredDatabase{a} }
+
+type atomicBlockDatabase struct {
+	*AtomicDatabase
+}
+
+// with calls the function with a matcher of the pinned block database, or does nothing if the database is closed.
+func (d atomicBlockDatabase) with(fn func(db BlockDatabase)) {
+	if db, release, err := d.pin(); err == nil {
+		defer release()
+
+		if bdb, ok := db.(BlockDatabase); ok {
+			fn(blockMatcherOf(bdb))
+		}
+	}
+}
+
+// blockMatcherOf returns the database with a matcher of its own,
+// since the state of a matcher can't be shared by the concurrent scans.
+func blockMatcherOf(db BlockDatabase) BlockDatabase {
+	if bdb, ok := db.(*blockDatabase); ok {
+		return &blockDatabase{newBlockMatcher(bdb.blockScanner)}
+	}
+
+	return db
+}
+
+func (d atomicBlockDatabase) Scan(data []byte, s *Scratch, handler MatchHandler, context interface{}) error {
+	v, err := d.acquire()
+	if err != nil {
+		return err
+	}
+
+	defer v.release()
+
+	bdb, ok := v.db.(BlockScanner)
+	if !ok {
+		return fmt.Errorf("block scan, %w", ErrInvalid)
+	}
+
+	s, put, err := v.pinned(s)
+	if err != nil {
+		return err
+	}
+
+	defer put()
+
+	return bdb.Scan(data, s, handler, context) //nolint: wrapcheck
+}
+
+func (d atomicBlockDatabase) Find(data []byte) (b []byte) {
+	d.with(func(db BlockDatabase) { b = db.Find(data) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindIndex(data []byte) (loc []int) {
+	d.with(func(db BlockDatabase) { loc = db.FindIndex(data) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindAll(data []byte, n int) (b [][]byte) {
+	d.with(func(db BlockDatabase) { b = db.FindAll(data, n) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindAllIndex(data []byte, n int) (loc [][]int) {
+	d.with(func(db BlockDatabase) { loc = db.FindAllIndex(data, n) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindString(s string) (r string) {
+	d.with(func(db BlockDatabase) { r = db.FindString(s) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindStringIndex(s string) (loc []int) {
+	d.with(func(db BlockDatabase) { loc = db.FindStringIndex(s) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindAllString(s string, n int) (r []string) {
+	d.with(func(db BlockDatabase) { r = db.FindAllString(s, n) })
+
+	return
+}
+
+func (d atomicBlockDatabase) FindAllStringIndex(s string, n int) (loc [][]int) {
+	d.with(func(db BlockDatabase) { loc = db.FindAllStringIndex(s, n) })
+
+	return
+}
+
+func (d atomicBlockDatabase) Match(b []byte) (matched bool) {
+	d.with(func(db BlockDatabase) { matched = db.Match(b) })
+
+	return
+}
+
+func (d atomicBlockDatabase) MatchString(s string) (matched bool) {
+	d.with(func(db BlockDatabase) { matched = db.MatchString(s) })
+
+	return
+}
+
+func (d atomicBlockDatabase) ReplaceAll(src, repl []byte) (b []byte) {
+	d.with(func(db BlockDatabase) { b = db.ReplaceAll(src, repl) })
+
+	return
+}
+
+func (d atomicBlockDatabase) ReplaceAllString(src, repl string) (s string) {
+	d.with(func(db BlockDatabase) { s = db.ReplaceAllString(src, repl) })
+
+	return
+}
+
+func (d atomicBlockDatabase) ReplaceAllLiteral(src, repl []byte) (b []byte) {
+	d.with(func(db BlockDatabase) { b = db.ReplaceAllLiteral(src, repl) })
+
+	return
+}
+
+func (d atomicBlockDatabase) ReplaceAllFunc(src []byte, repl func(id uint, match []byte) []byte) (b []byte) {
+	d.with(func(db BlockDatabase) { b = db.ReplaceAllFunc(src, repl) })
+
+	return
+}
+
+func (d atomicBlockDatabase) Split(s string, n int) (r []string) {
+	d.with(func(db BlockDatabase) { r = db.Split(s, n) })
+
+	return
+}
+
+type atomicStreamDatabase struct {
+	*AtomicDatabase
+}
+
+// pinStream pins the current version of the streaming database until the returned function is called.
+func (d atomicStreamDatabase) pinStream() (StreamDatabase, func(), error) {
+	db, release, err := d.pin()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sdb, ok := db.(StreamDatabase)
+	if !ok {
+		release()
+
+		return nil, nil, fmt.Errorf("stream database, %w", ErrInvalid)
+	}
+
+	return sdb, release, nil
+}
+
+// with calls the function with a matcher of the pinned streaming database, or does nothing if the database is closed.
+func (d atomicStreamDatabase) with(fn func(db StreamDatabase)) {
+	if db, release, err := d.pinStream(); err == nil {
+		defer release()
+
+		fn(streamMatcherOf(db))
+	}
+}
+
+// streamMatcherOf returns the database with a matcher of its own,
+// since the state of a matcher can't be shared by the concurrent scans.
+func streamMatcherOf(db StreamDatabase) StreamDatabase {
+	if sdb, ok := db.(*streamDatabase); ok {
+		return &streamDatabase{newStreamMatcher(sdb.streamScanner)}
+	}
+
+	return db
+}
+
+func (d atomicStreamDatabase) StreamSize() (int, error) {
+	db, release, err := d.pinStream()
+	if err != nil {
+		return 0, err
+	}
+
+	defer release()
+
+	return db.StreamSize() //nolint: wrapcheck
+}
+
+// open opens a stream of the pinned version, which is released with the scratch of the stream when it is closed.
+func (d atomicStreamDatabase) open(s *Scratch,
+	open func(db StreamDatabase, s *Scratch) (Stream, error),
+) (Stream, error) {
+	v, err := d.acquire()
+	if err != nil {
+		return nil, err
+	}
+
+	sdb, ok := v.db.(StreamDatabase)
+	if !ok {
+		v.release()
+
+		return nil, fmt.Errorf("open stream, %w", ErrInvalid)
+	}
+
+	var pooled *Scratch
+
+	if s == nil {
+		if s, err = v.scratch(); err != nil {
+			v.release()
+
+			return nil, err
+		}
+
+		pooled = s
+	} else if err = v.adopt(s); err != nil {
+		v.release()
+
+		return nil, err
+	}
+
+	st, err := open(sdb, s)
+	if err != nil {
+		if pooled != nil {
+			v.put(pooled)
+		}
+
+		v.release()
+
+		return nil, err
+	}
+
+	return &atomicStream{Stream: st, v: v, pooled: pooled}, nil
+}
+
+func (d atomicStreamDatabase) Open(flags ScanFlag, s *Scratch, handler MatchHandler, context interface{}) (Stream, error) {
+	return d.open(s, func(db StreamDatabase, s *Scratch) (Stream, error) {
+		return db.Open(flags, s, handler, context) //nolint: wrapcheck
+	})
+}
+
+func (d atomicStreamDatabase) Scan(reader io.Reader, s *Scratch, handler MatchHandler, context interface{}) error {
+	v, err := d.acquire()
+	if err != nil {
+		return err
+	}
+
+	defer v.release()
+
+	sdb, ok := v.db.(StreamScanner)
+	if !ok {
+		return fmt.Errorf("stream scan, %w", ErrInvalid)
+	}
+
+	s, put, err := v.pinned(s)
+	if err != nil {
+		return err
+	}
+
+	defer put()
+
+	return sdb.Scan(reader, s, handler, context) //nolint: wrapcheck
+}
+
+func (d atomicStreamDatabase) Find(reader io.ReadSeeker) (b []byte) {
+	d.with(func(db StreamDatabase) { b = db.Find(reader) })
+
+	return
+}
+
+func (d atomicStreamDatabase) FindIndex(reader io.Reader) (loc []int) {
+	d.with(func(db StreamDatabase) { loc = db.FindIndex(reader) })
+
+	return
+}
+
+func (d atomicStreamDatabase) FindAll(reader io.ReadSeeker, n int) (b [][]byte) {
+	d.with(func(db StreamDatabase) { b = db.FindAll(reader, n) })
+
+	return
+}
+
+func (d atomicStreamDatabase) FindAllIndex(reader io.Reader, n int) (loc [][]int) {
+	d.with(func(db StreamDatabase) { loc = db.FindAllIndex(reader, n) })
+
+	return
+}
+
+func (d atomicStreamDatabase) Match(reader io.Reader) (matched bool) {
+	d.with(func(db StreamDatabase) { matched = db.Match(reader) })
+
+	return
+}
+
+func (d atomicStreamDatabase) ReplaceAllFunc(dst io.Writer, src io.Reader, repl func(id uint, match []byte) []byte) error {
+	db, release, err := d.pinStream()
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	return streamMatcherOf(db).ReplaceAllFunc(dst, src, repl) //nolint: wrapcheck
+}
+
+// Compress compresses the stream with the version pinned by the stream.
+func (d atomicStreamDatabase) Compress(stream Stream) ([]byte, error) {
+	st, ok := stream.(*atomicStream)
+	if !ok {
+		return nil, fmt.Errorf("stream %v, %w", stream, ErrInvalid)
+	}
+
+	return st.v.db.(StreamDatabase).Compress(st.Stream) //nolint: wrapcheck
+}
+
+func (d atomicStreamDatabase) Expand(buf []byte, flags ScanFlag, s *Scratch,
+	handler MatchHandler, context interface{},
+) (Stream, error) {
+	return d.open(s, func(db StreamDatabase, s *Scratch) (Stream, error) {
+		return db.Expand(buf, flags, s, handler, context) //nolint: wrapcheck
+	})
+}
+
+// ResetAndExpand expands the compressed stream into the stream with the version pinned by the stream.
+func (d atomicStreamDatabase) ResetAndExpand(stream Stream, buf []byte, flags ScanFlag, s *Scratch,
+	handler MatchHandler, context interface{},
+) (Stream, error) {
+	st, ok := stream.(*atomicStream)
+	if !ok {
+		return nil, fmt.Errorf("stream %v, %w", stream, ErrInvalid)
+	}
+
+	if s == nil {
+		s = st.pooled
+	} else if err := st.v.adopt(s); err != nil {
+		return nil, err
+	}
+
+	expanded, err := st.v.db.(StreamDatabase).ResetAndExpand(st.Stream, buf, flags, s, handler, context)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	st.Stream = expanded
+
+	return st, nil
+}
+
+// atomicStream is a stream which pins the version of the database until it is closed.
+type atomicStream struct {
+	Stream
+	v *dbVersion
+
+	// The scratch taken from the pool of the version, if the stream was opened without scratch.
+	pooled *Scratch
+}
+
+func (s *atomicStream) Close() error {
+	err := s.Stream.Close()
+
+	if s.v != nil {
+		if s.pooled != nil {
+			s.v.put(s.pooled)
+			s.pooled = nil
+		}
+
+		s.v.release()
+		s.v = nil
+	}
+
+	return err //nolint: wrapcheck
+}
+
+// Clone clones the stream, which takes another scratch from the pool if the stream holds a pooled one.
+func (s *atomicStream) Clone() (Stream, error) {
+	cloned, err := s.Stream.Clone()
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	var pooled *Scratch
+
+	if st, ok := cloned.(*stream); ok && s.pooled != nil {
+		if pooled, err = s.v.scratch(); err != nil {
+			_ = cloned.Close()
+
+			return nil, err
+		}
+
+		st.useScratch(pooled)
+	}
+
+	s.v.acquire()
+
+	return &atomicStream{Stream: cloned, v: s.v, pooled: pooled}, nil
+}
+
+type atomicVectoredDatabase struct {
+	*AtomicDatabase
+}
+
+func (d atomicVectoredDatabase) Scan(data [][]byte, s *Scratch, handler MatchHandler, context interface{}) error {
+	v, err := d.acquire()
+	if err != nil {
+		return err
+	}
+
+	defer v.release()
+
+	vdb, ok := v.db.(VectoredScanner)
+	if !ok {
+		return fmt.Errorf("vectored scan, %w", ErrInvalid)
+	}
+
+	s, put, err := v.pinned(s)
+	if err != nil {
+		return err
+	}
+
+	defer put()
+
+	return vdb.Scan(data, s, handler, context) //nolint: wrapcheck
+}
@@ -0,0 +1,350 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestAtomicDatabase(t *testing.T) {
+	Convey("Given a swappable block database", t, func() {
+		foo, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		bar, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`bar`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		a := hyperscan.NewAtomicDatabase(foo)
+		db := a.Block()
+
+		So(db.FindAllString("foo bar", -1), ShouldResemble, []string{"foo"})
+
+		Convey("When swap the database", func() {
+			So(a.Swap(bar), ShouldBeNil)
+
+			Convey("Then the scans use the new version", func() {
+				So(db.FindAllString("foo bar", -1), ShouldResemble, []string{"bar"})
+
+				_, err := foo.Size()
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When the old version is pinned", func() {
+			old, release, err := a.Load()
+			So(err, ShouldBeNil)
+			So(a.Swap(bar), ShouldBeNil)
+
+			Convey("Then it is closed once released", func() {
+				So(old.(hyperscan.BlockDatabase).MatchString("foo"), ShouldBeTrue)
+
+				release()
+
+				_, err := foo.Size()
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When scan with a scratch across the swap", func() {
+			s, err := hyperscan.NewScratch(db)
+			So(err, ShouldBeNil)
+
+			defer s.Free()
+
+			So(a.Swap(bar), ShouldBeNil)
+
+			var ids []uint
+
+			handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+				ids = append(ids, uint(to))
+
+				return nil
+			}
+
+			Convey("Then the scratch of the old version is grown for the new version", func() {
+				So(db.Scan([]byte("foo bar"), s, handler, nil), ShouldBeNil)
+				So(ids, ShouldResemble, []uint{7})
+			})
+
+			Convey("Then the scratch could be reallocated for the new version", func() {
+				So(s.Realloc(db), ShouldBeNil)
+				So(db.Scan([]byte("foo bar"), s, handler, nil), ShouldBeNil)
+				So(ids, ShouldResemble, []uint{7})
+			})
+		})
+
+		Convey("When scan concurrently while swapping", func() {
+			var wg sync.WaitGroup
+
+			errs := make(chan error, 4)
+
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < 50; j++ {
+						if err := db.Scan([]byte("foo bar"), nil, func(uint, uint64, uint64, uint, interface{}) error {
+							return nil
+						}, nil); err != nil {
+							errs <- err
+
+							return
+						}
+					}
+				}()
+			}
+
+			for i := 0; i < 10; i++ {
+				next, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`ba[rz]`, 0))
+				So(err, ShouldBeNil)
+				So(a.Swap(next), ShouldBeNil)
+			}
+
+			wg.Wait()
+			close(errs)
+
+			Convey("Then no scan fails", func() {
+				So(<-errs, ShouldBeNil)
+			})
+		})
+
+		Convey("When find concurrently while swapping", func() {
+			var wg sync.WaitGroup
+
+			found := make(chan []string, 4*50)
+
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					for j := 0; j < 50; j++ {
+						found <- db.FindAllString("foo bar baz", -1)
+					}
+				}()
+			}
+
+			for i := 0; i < 10; i++ {
+				next, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`ba[rz]`, hyperscan.SomLeftMost))
+				So(err, ShouldBeNil)
+				So(a.Swap(next), ShouldBeNil)
+			}
+
+			wg.Wait()
+			close(found)
+
+			Convey("Then each find sees the matches of one version", func() {
+				for matches := range found {
+					So(matches, ShouldBeIn, [][]string{{"foo"}, {"bar", "baz"}})
+				}
+			})
+		})
+
+		Convey("When swap a database of another mode", func() {
+			sdb, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`foo`, 0))
+			So(err, ShouldBeNil)
+
+			defer sdb.Close()
+
+			Convey("Then it is rejected", func() {
+				So(errors.Is(a.Swap(sdb), hyperscan.ErrInvalid), ShouldBeTrue)
+			})
+		})
+
+		Convey("When unmarshal a serialized database", func() {
+			data, err := bar.Marshal()
+			So(err, ShouldBeNil)
+			So(bar.Close(), ShouldBeNil)
+
+			So(a.Unmarshal(data), ShouldBeNil)
+
+			Convey("Then it is swapped in", func() {
+				So(db.Match([]byte("bar")), ShouldBeTrue)
+				So(db.Match([]byte("foo")), ShouldBeFalse)
+			})
+		})
+
+		Convey("When the database is closed", func() {
+			So(a.Close(), ShouldBeNil)
+
+			Convey("Then the scans fail", func() {
+				So(db.Scan([]byte("foo"), nil, nil, nil), ShouldNotBeNil)
+				So(db.MatchString("foo"), ShouldBeFalse)
+				So(a.Close(), ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a swappable stream database", t, func() {
+		foo, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`foo`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		bar, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`bar`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		a := hyperscan.NewAtomicDatabase(foo)
+		db := a.Stream()
+
+		defer a.Close()
+
+		var matches []uint64
+
+		handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+			matches = append(matches, to)
+
+			return nil
+		}
+
+		Convey("When a stream is open across the swap", func() {
+			s, err := db.Open(0, nil, handler, nil)
+			So(err, ShouldBeNil)
+			So(s.Scan([]byte("fo")), ShouldBeNil)
+
+			So(a.Swap(bar), ShouldBeNil)
+
+			Convey("Then the stream keeps the old version until it is closed", func() {
+				So(s.Scan([]byte("o bar")), ShouldBeNil)
+
+				cloned, err := s.Clone()
+				So(err, ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+
+				_, err = foo.Size()
+				So(err, ShouldBeNil)
+
+				So(cloned.Close(), ShouldBeNil)
+
+				_, err = foo.Size()
+				So(err, ShouldNotBeNil)
+
+				So(matches, ShouldResemble, []uint64{3})
+
+				n, err := db.StreamSize()
+				So(err, ShouldBeNil)
+				So(n, ShouldBeGreaterThan, 0)
+			})
+
+			Convey("Then the new version scans with its own scratch", func() {
+				for i := 0; i < 3; i++ {
+					next, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`ba[rz]`, hyperscan.SomLeftMost))
+					So(err, ShouldBeNil)
+					So(a.Swap(next), ShouldBeNil)
+					So(db.Match(strings.NewReader("baz")), ShouldBeTrue)
+				}
+
+				So(s.Scan([]byte("o")), ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+				So(matches, ShouldResemble, []uint64{3})
+			})
+		})
+
+		Convey("When open a stream with a scratch of the old version", func() {
+			sc, err := hyperscan.NewScratch(db)
+			So(err, ShouldBeNil)
+
+			defer sc.Free()
+
+			So(a.Swap(bar), ShouldBeNil)
+
+			Convey("Then it is grown for the new version", func() {
+				s, err := db.Open(0, sc, handler, nil)
+				So(err, ShouldBeNil)
+				So(s.Scan([]byte("bar")), ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+				So(matches, ShouldResemble, []uint64{3})
+			})
+		})
+
+		Convey("When a stream with a scratch is open across the swap", func() {
+			sc, err := hyperscan.NewScratch(db)
+			So(err, ShouldBeNil)
+
+			defer sc.Free()
+
+			s, err := db.Open(0, sc, handler, nil)
+			So(err, ShouldBeNil)
+
+			So(a.Swap(bar), ShouldBeNil)
+
+			Convey("Then the stream still scans with the scratch grown for the new version", func() {
+				o, err := db.Open(0, sc, handler, nil)
+				So(err, ShouldBeNil)
+				So(o.Scan([]byte("bar")), ShouldBeNil)
+				So(o.Close(), ShouldBeNil)
+
+				So(s.Scan([]byte("foo")), ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+				So(matches, ShouldResemble, []uint64{3, 3})
+			})
+		})
+	})
+
+	Convey("Given a watched rule file", t, func() {
+		path := filepath.Join(t.TempDir(), "rules.txt")
+		So(os.WriteFile(path, []byte("1:/foo/\n"), 0o600), ShouldBeNil)
+
+		load := hyperscan.RuleFileLoader(hyperscan.BlockMode)
+
+		db, err := load(path)
+		So(err, ShouldBeNil)
+
+		a := hyperscan.NewAtomicDatabase(db)
+
+		defer a.Close()
+
+		errs := make(chan error, 16)
+		stop := a.Watch(path, 5*time.Millisecond, load, func(err error) { errs <- err })
+
+		defer stop()
+
+		Convey("When the rule file is changed", func() {
+			So(os.WriteFile(path, []byte("1:/foo/\n2:/bar/\n"), 0o600), ShouldBeNil)
+
+			Convey("Then the database is reloaded", func() {
+				deadline := time.Now().Add(5 * time.Second)
+
+				for !a.Block().MatchString("bar") && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+
+				So(a.Block().MatchString("bar"), ShouldBeTrue)
+				So(errs, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When the rule file is broken", func() {
+			So(os.WriteFile(path, []byte("1:/foo(/\n"), 0o600), ShouldBeNil)
+
+			Convey("Then it is reloaded until it is fixed", func() {
+				So(<-errs, ShouldNotBeNil)
+				So(<-errs, ShouldNotBeNil)
+
+				info, err := os.Stat(path)
+				So(err, ShouldBeNil)
+				// Same size and modification time as the broken file.
+				So(os.WriteFile(path, []byte("1:/fo|z/\n"), 0o600), ShouldBeNil)
+				So(os.Chtimes(path, info.ModTime(), info.ModTime()), ShouldBeNil)
+
+				deadline := time.Now().Add(5 * time.Second)
+
+				for !a.Block().MatchString("baz") && time.Now().Before(deadline) {
+					time.Sleep(5 * time.Millisecond)
+				}
+
+				So(a.Block().MatchString("baz"), ShouldBeTrue)
+			})
+		})
+	})
+}
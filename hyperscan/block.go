@@ -58,12 +58,49 @@ type BlockMatcher interface {
 	MatchString(s string) bool
 }
 
+// BlockReplacer implements regular expression replacement and splitting.
+//
+// The replacement and splitting work on the non-overlapping matches of the selected Semantics,
+// the LeftmostLongest semantics is used instead of RawMatches.
+//
+// The start of a match is needed to replace it, so the patterns must be compiled with SomLeftMost,
+// except the ones compiled with PrefilterMode and verified by VerifyPrefilter. If the database is built
+// by DatabaseBuilder with a pattern without SomLeftMost, nothing is replaced or split, like StreamReplacer rejects it.
+type BlockReplacer interface {
+	// ReplaceAll returns a copy of src, replacing matches of the pattern database with the replacement text repl.
+	// Inside repl, $ signs are interpreted as in regexp.Regexp.Expand, only `$0` (the whole match) is available.
+	ReplaceAll(src, repl []byte) []byte
+
+	// ReplaceAllString returns a copy of src, replacing matches of the pattern database
+	// with the replacement string repl. Inside repl, $ signs are interpreted as in ReplaceAll.
+	ReplaceAllString(src, repl string) string
+
+	// ReplaceAllLiteral returns a copy of src, replacing matches of the pattern database
+	// with the replacement bytes repl. The replacement repl is substituted directly, without using Expand.
+	ReplaceAllLiteral(src, repl []byte) []byte
+
+	// ReplaceAllFunc returns a copy of src in which all matches of the pattern database have been replaced
+	// by the return value of function repl applied to the ID of matched pattern and the matched byte slice.
+	// The replacement returned by repl is substituted directly, without using Expand.
+	ReplaceAllFunc(src []byte, repl func(id uint, match []byte) []byte) []byte
+
+	// Split slices s into substrings separated by the matches and returns a slice of
+	// the substrings between those matches, like regexp.Regexp.Split.
+	//
+	// The count determines the number of substrings to return:
+	//   n > 0: at most n substrings; the last substring will be the unsplit remainder.
+	//   n == 0: the result is nil (zero substrings)
+	//   n < 0: all substrings
+	Split(s string, n int) []string
+}
+
 // BlockDatabase scan the target data that is a discrete,
 // contiguous block which can be scanned in one call and does not require state to be retained.
 type BlockDatabase interface {
 	Database
 	BlockScanner
 	BlockMatcher
+	BlockReplacer
 }
 
 type blockDatabase struct {
@@ -76,15 +113,20 @@ func newBlockDatabase(db hs.Database) *blockDatabase {
 
 type blockScanner struct {
 	*baseDatabase
+	opts *scanOptions
 }
 
 func newBlockScanner(bdb *baseDatabase) *blockScanner {
-	return &blockScanner{bdb}
+	return &blockScanner{baseDatabase: bdb}
 }
 
 func (bs *blockScanner) Scan(data []byte, s *Scratch, handler MatchHandler, context interface{}) (err error) {
+	if err = checkLive("scan", bs.leak, s); err != nil {
+		return
+	}
+
 	if s == nil {
-		s, err = NewScratch(bs)
+		s, err = newScratch(bs)
 
 		if err != nil {
 			return
@@ -95,13 +137,57 @@ func (bs *blockScanner) Scan(data []byte, s *Scratch, handler MatchHandler, cont
 		}()
 	}
 
-	return hs.Scan(bs.db, data, 0, s.s, handler, context) //nolint: wrapcheck
+	release, err := s.acquire(bs.db, "scan")
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	f := bs.opts.newFilter(bs.ids)
+	if f != nil {
+		handler = f.handler(handler)
+	}
+
+	if v := bs.opts.newVerification(bs.baseDatabase, 0); v != nil {
+		v.data = data
+		handler = v.handler(handler)
+	}
+
+	m := bs.fallbacks.newMerge(data)
+
+	if len(bs.shards) > 0 {
+		var events []hs.MatchEvent
+
+		if events, err = bs.scanShards(s, func(db hs.Database, scratch hs.Scratch, handler MatchHandler) error {
+			return hs.Scan(db, data, 0, scratch, handler, nil)
+		}); err != nil {
+			return err
+		}
+
+		m = m.with(events)
+	}
+
+	if m != nil {
+		err = m.flush(handler, context, hs.Scan(bs.db, data, 0, s.s, m.handler(handler), context))
+	} else {
+		err = hs.Scan(bs.db, data, 0, s.s, handler, context)
+	}
+
+	if f != nil {
+		err = f.result(err)
+	}
+
+	return err //nolint: wrapcheck
 }
 
 type blockMatcher struct {
 	*blockScanner
 	*hs.MatchRecorder
 	n int
+
+	// The scratch of the scans, or nil to allocate one for each scan.
+	scratch *Scratch
 }
 
 func newBlockMatcher(scanner *blockScanner) *blockMatcher {
@@ -130,7 +216,7 @@ func (m *blockMatcher) Handle(id uint, from, to uint64, flags uint, context inte
 func (m *blockMatcher) scan(data []byte) error {
 	m.MatchRecorder = &hs.MatchRecorder{}
 
-	return m.blockScanner.Scan(data, nil, m.Handle, nil)
+	return m.blockScanner.Scan(data, m.scratch, m.Handle, nil)
 }
 
 const findIndexMatches = 2
@@ -144,6 +230,14 @@ func (m *blockMatcher) Find(data []byte) []byte {
 }
 
 func (m *blockMatcher) FindIndex(data []byte) []int {
+	if m.opts.matchSemantics() != RawMatches {
+		if locs := m.FindAllIndex(data, 1); len(locs) == 1 {
+			return locs[0]
+		}
+
+		return nil
+	}
+
 	if m.Match(data) && len(m.Events) == 1 {
 		return []int{int(m.Events[0].From), int(m.Events[0].To)}
 	}
@@ -166,15 +260,38 @@ func (m *blockMatcher) FindAllIndex(data []byte, n int) (locs [][]int) {
 		n = len(data) + 1
 	}
 
-	m.n = n
+	for _, e := range m.findAll(data, n, m.opts.matchSemantics()) {
+		locs = append(locs, []int{int(e.From), int(e.To)})
+	}
+
+	return
+}
+
+// findAll returns at most n matches (or all the matches if n < 0) with the semantics.
+func (m *blockMatcher) findAll(data []byte, n int, sem Semantics) []hs.MatchEvent {
+	if sem == RawMatches {
+		m.n = n
 
-	if err := m.scan(data); (err == nil || errors.Is(err, ErrScanTerminated)) && len(m.Events) > 0 {
-		for _, e := range m.Events {
-			locs = append(locs, []int{int(e.From), int(e.To)})
+		if err := m.scan(data); err == nil || errors.Is(err, ErrScanTerminated) {
+			return m.Events
 		}
+
+		return nil
 	}
 
-	return
+	m.n = -1
+
+	if err := m.scan(data); err != nil && !errors.Is(err, ErrScanTerminated) {
+		return nil
+	}
+
+	matches := sem.selectMatches(m.Events, m.regexps, data)
+
+	if n >= 0 && len(matches) > n {
+		matches = matches[:n]
+	}
+
+	return matches
 }
 
 func (m *blockMatcher) FindString(s string) string {
@@ -0,0 +1,241 @@
+package hyperscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// BundleVersion is the layout version of the database bundle written by Bundle.WriteTo.
+const BundleVersion = 1
+
+// bundleMagic identifies the database bundle.
+var bundleMagic = [4]byte{'G', 'H', 'S', 'B'}
+
+// maxBundleVariants is the largest number of variants accepted by Bundle.ReadFrom.
+const maxBundleVariants = 1024
+
+// bundleHeader is the fixed size header of the database bundle, followed by the containers of the variants.
+type bundleHeader struct {
+	Magic    [4]byte
+	Version  uint16
+	Variants uint16
+}
+
+// BundleBuilder compiles the same patterns for several target platforms into a bundle.
+type BundleBuilder struct {
+	// Array of patterns to compile.
+	Patterns
+
+	// Compiler mode flags that affect the database as a whole. (Default: block mode)
+	Mode ModeFlag
+
+	// The target platforms, a generic variant without CPU features is always added as the fallback.
+	Platforms []Platform
+}
+
+// Build compiles a variant for each target platform.
+func (b *BundleBuilder) Build() (*Bundle, error) {
+	platforms := append([]Platform{NewPlatform(Generic, 0)}, b.Platforms...)
+	bundle := &Bundle{}
+
+	for _, platform := range platforms {
+		if bundle.variant(platform) != nil {
+			continue
+		}
+
+		builder := DatabaseBuilder{Patterns: b.Patterns, Mode: b.Mode, Platform: platform}
+
+		db, err := builder.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := NewContainer(db)
+		_ = db.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.Tune = platform.Tune()
+
+		bundle.Variants = append(bundle.Variants, c)
+	}
+
+	return bundle, nil
+}
+
+// Bundle holds the variants of a database compiled for several target platforms.
+//
+// The bundle is written as a header followed by the container of each variant.
+type Bundle struct {
+	Variants []*Container
+}
+
+// variant returns the variant compiled for the platform.
+func (b *Bundle) variant(platform Platform) *Container {
+	for _, c := range b.Variants {
+		if info, err := c.Info.DatabaseInfo(); err == nil &&
+			info.Features == platform.CpuFeatures() && c.Tune == platform.Tune() {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Select returns the best variant for the platform, which is the current host if nil.
+//
+// The variant compatible with the platform which uses the most CPU features is selected,
+// the variant tuned for the platform is preferred, and the generic variant is the fallback.
+func (b *Bundle) Select(platform Platform) (*Container, error) {
+	if platform == nil {
+		platform = PopulatePlatform()
+	}
+
+	var (
+		best      *Container
+		bestScore int
+		lastErr   error
+	)
+
+	for _, c := range b.Variants {
+		info, err := c.Info.DatabaseInfo()
+		if err == nil {
+			err = info.CheckCompatible(platform)
+		}
+
+		if err != nil {
+			lastErr = err
+
+			continue
+		}
+
+		score := 2 * bits.OnesCount(uint(info.Features))
+		if c.Tune == platform.Tune() {
+			score++
+		}
+
+		if best == nil || score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+
+	if best == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("empty bundle, %w", ErrInvalid)
+		}
+
+		return nil, lastErr
+	}
+
+	return best, nil
+}
+
+// Load reconstructs the database of the best variant for the platform, which is the current host if nil.
+func (b *Bundle) Load(platform Platform) (Database, *Manifest, error) {
+	c, err := b.Select(platform)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := c.Database()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, &c.Manifest, nil
+}
+
+// ReadBundle reads a bundle.
+func ReadBundle(r io.Reader) (*Bundle, error) {
+	var b Bundle
+
+	if _, err := b.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	return &b, nil
+}
+
+// LoadBundle reads a bundle, and reconstructs the database of the best variant for the current host.
+func LoadBundle(r io.Reader) (Database, *Manifest, error) {
+	b, err := ReadBundle(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return b.Load(nil)
+}
+
+// WriteTo writes the bundle.
+func (b *Bundle) WriteTo(w io.Writer) (int64, error) {
+	if len(b.Variants) > maxBundleVariants {
+		return 0, fmt.Errorf("bundle of %d variants, %w", len(b.Variants), ErrInvalid)
+	}
+
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.LittleEndian, &bundleHeader{bundleMagic, BundleVersion, uint16(len(b.Variants))})
+
+	n, err := w.Write(buf.Bytes())
+	if err != nil {
+		return int64(n), fmt.Errorf("write bundle, %w", err)
+	}
+
+	written := int64(n)
+
+	for _, c := range b.Variants {
+		n, err := c.WriteTo(w)
+		written += n
+
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// ReadFrom reads the bundle, which is rejected if it is written by a newer layout or corrupted.
+func (b *Bundle) ReadFrom(r io.Reader) (int64, error) {
+	var h bundleHeader
+
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return 0, fmt.Errorf("read bundle header, %w", err)
+	}
+
+	read := int64(binary.Size(&h))
+
+	if h.Magic != bundleMagic {
+		return read, fmt.Errorf("bundle magic %q, %w", h.Magic[:], ErrInvalid)
+	}
+
+	if h.Version > BundleVersion {
+		return read, fmt.Errorf("bundle version %d, newer than %d, %w", h.Version, BundleVersion, ErrInvalid)
+	}
+
+	if h.Variants > maxBundleVariants {
+		return read, fmt.Errorf("bundle of %d variants, %w", h.Variants, ErrInvalid)
+	}
+
+	variants := make([]*Container, h.Variants)
+
+	for i := range variants {
+		variants[i] = &Container{}
+
+		n, err := variants[i].ReadFrom(r)
+		read += n
+
+		if err != nil {
+			return read, fmt.Errorf("bundle variant %d, %w", i, err)
+		}
+	}
+
+	b.Variants = variants
+
+	return read, nil
+}
@@ -0,0 +1,104 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestBundle(t *testing.T) {
+	Convey("Given a bundle builder for several platforms", t, func() {
+		b := hyperscan.BundleBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `ba[rz]`, Flags: hyperscan.SomLeftMost, Id: 2},
+			},
+			Platforms: []hyperscan.Platform{
+				hyperscan.NewPlatform(hyperscan.Haswell, hyperscan.AVX2),
+				hyperscan.NewPlatform(hyperscan.SkylakeServer, hyperscan.AVX2|hyperscan.AVX512),
+				hyperscan.NewPlatform(hyperscan.Generic, 0),
+			},
+		}
+
+		bundle, err := b.Build()
+		So(err, ShouldBeNil)
+
+		Convey("Then a variant is compiled for each platform with the generic fallback", func() {
+			So(bundle.Variants, ShouldHaveLength, 3)
+			So(bundle.Variants[0].Tune, ShouldEqual, hyperscan.Generic)
+		})
+
+		Convey("When select the variant for an AVX-512 host", func() {
+			c, err := bundle.Select(hyperscan.NewPlatform(hyperscan.SkylakeServer, hyperscan.AVX2|hyperscan.AVX512))
+
+			So(err, ShouldBeNil)
+			So(c.Tune, ShouldEqual, hyperscan.SkylakeServer)
+		})
+
+		Convey("When select the variant for an AVX2 host", func() {
+			c, err := bundle.Select(hyperscan.NewPlatform(hyperscan.Broadwell, hyperscan.AVX2))
+
+			So(err, ShouldBeNil)
+			So(c.Tune, ShouldEqual, hyperscan.Haswell)
+		})
+
+		Convey("When select the variant for a generic host", func() {
+			c, err := bundle.Select(hyperscan.NewPlatform(hyperscan.Silvermont, 0))
+
+			So(err, ShouldBeNil)
+			So(c.Tune, ShouldEqual, hyperscan.Generic)
+		})
+
+		Convey("When the bundle has no compatible variant", func() {
+			avx512 := &hyperscan.Bundle{Variants: bundle.Variants[2:]}
+
+			_, err := avx512.Select(hyperscan.NewPlatform(hyperscan.Generic, 0))
+
+			So(errors.Is(err, hyperscan.ErrDatabasePlatformError), ShouldBeTrue)
+		})
+
+		Convey("When write and load the bundle on the host", func() {
+			var buf bytes.Buffer
+
+			n, err := bundle.WriteTo(&buf)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, buf.Len())
+
+			data := buf.Bytes()
+
+			db, manifest, err := hyperscan.LoadBundle(bytes.NewReader(data))
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			Convey("Then the best variant for the host is loaded", func() {
+				expected, err := bundle.Select(hyperscan.PopulatePlatform())
+				So(err, ShouldBeNil)
+				So(manifest.Tune, ShouldEqual, expected.Tune)
+				So(manifest.Info, ShouldEqual, expected.Info)
+
+				So(db.(hyperscan.BlockDatabase).FindAllString("foo bar", -1), ShouldResemble, []string{"foo", "bar"})
+			})
+
+			Convey("Then a newer layout is rejected", func() {
+				binary.LittleEndian.PutUint16(data[4:], hyperscan.BundleVersion+1)
+
+				_, err := hyperscan.ReadBundle(bytes.NewReader(data))
+
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+			})
+
+			Convey("Then a truncated bundle is rejected", func() {
+				_, err := hyperscan.ReadBundle(bytes.NewReader(data[:len(data)-1]))
+
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
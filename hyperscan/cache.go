@@ -0,0 +1,198 @@
+package hyperscan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// DatabaseCache keeps the compiled databases in a directory, so the same pattern set is compiled only once.
+//
+// The databases are keyed by the patterns (expressions, flags, IDs and extended parameters), the mode,
+// the target platform (the host platform if nil) and the version of Hyperscan.
+// A cached database is validated with SerializedDatabaseInfo before use, and recompiled if it is invalid.
+// The databases are written to a temporary file then renamed, so the concurrent writers never leave a partial file.
+type DatabaseCache struct {
+	dir string
+
+	lock  sync.Mutex
+	stats CacheStats
+}
+
+// CacheStats are the statistics of DatabaseCache.
+type CacheStats struct {
+	Hits    int // The number of databases loaded from the cache.
+	Misses  int // The number of databases compiled and written to the cache.
+	Invalid int // The number of invalid cached databases, which were recompiled.
+}
+
+// NewDatabaseCache creates a cache in the directory, which is created if it doesn't exist.
+func NewDatabaseCache(dir string) (*DatabaseCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint: gomnd
+		return nil, fmt.Errorf("create cache directory, %w", err)
+	}
+
+	return &DatabaseCache{dir: dir}, nil
+}
+
+// Dir returns the directory of the cache.
+func (c *DatabaseCache) Dir() string { return c.dir }
+
+// Stats returns the statistics of the cache.
+func (c *DatabaseCache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.stats
+}
+
+// Key returns the cache key of the database built by the builder.
+func (c *DatabaseCache) Key(b *DatabaseBuilder) string {
+	platform := b.Platform
+	if platform == nil {
+		platform = PopulatePlatform()
+	}
+
+	sum := hashPatterns(b.Patterns, b.mode(), platform)
+
+	h := sha256.New()
+	_, _ = h.Write(sum[:])
+	_, _ = h.Write([]byte(Version()))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Path returns the file of the database built by the builder in the cache.
+func (c *DatabaseCache) Path(b *DatabaseBuilder) string {
+	return filepath.Join(c.dir, c.Key(b)+".db")
+}
+
+// Build loads the database built by the builder from the cache,
+// or compiles the database and writes it to the cache.
+//
+// The sharded databases can't be cached.
+func (c *DatabaseCache) Build(b *DatabaseBuilder) (Database, error) {
+	if b.Shards > 1 {
+		return nil, fmt.Errorf("cache database of %d shards, %w", b.Shards, ErrInvalid)
+	}
+
+	if b.Patterns == nil {
+		return nil, ErrInvalid
+	}
+
+	mode := b.mode()
+	path := c.Path(b)
+
+	db, err := c.load(path, mode)
+
+	switch {
+	case err == nil:
+		base := db.(interface{ base() *baseDatabase }).base()
+		base.builtFrom(b.Patterns)
+		base.mode = mode
+		base.tune = b.tune()
+		c.count(func(s *CacheStats) { s.Hits++ })
+
+		return db, nil
+
+	case errors.Is(err, os.ErrNotExist):
+		c.count(func(s *CacheStats) { s.Misses++ })
+
+	default:
+		_ = os.Remove(path)
+		c.count(func(s *CacheStats) { s.Invalid++ })
+	}
+
+	built, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.store(path, built); err != nil {
+		_ = built.Close()
+
+		return nil, err
+	}
+
+	return built, nil
+}
+
+func (c *DatabaseCache) count(fn func(s *CacheStats)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	fn(&c.stats)
+}
+
+// load reads the cached database of the mode, after validating its version and mode.
+func (c *DatabaseCache) load(path string, mode ModeFlag) (Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	info, err := SerializedDatabaseInfo(data)
+	if err != nil {
+		return nil, fmt.Errorf("cached database info, %w", err)
+	}
+
+	version, _, dbMode, err := info.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(Version(), version) {
+		return nil, fmt.Errorf("cached database of version %s, %w", version, ErrInvalid)
+	}
+
+	if m, err := ParseModeFlag(dbMode); err != nil || m != mode&hs.ModeMask {
+		return nil, fmt.Errorf("cached database of mode %s, %w", dbMode, ErrInvalid)
+	}
+
+	db, err := unmarshalDatabase(data, mode)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal cached database, %w", err)
+	}
+
+	return db, nil
+}
+
+// store writes the database to a temporary file in the cache directory, then renames it to the path.
+func (c *DatabaseCache) store(path string, db Database) error {
+	data, err := db.Marshal()
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	f, err := os.CreateTemp(c.dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create cache file, %w", err)
+	}
+
+	defer os.Remove(f.Name())
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("write cache file, %w", err)
+	}
+
+	if err = os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("rename cache file, %w", err)
+	}
+
+	return nil
+}
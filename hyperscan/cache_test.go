@@ -0,0 +1,137 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestDatabaseCache(t *testing.T) {
+	Convey("Given a database cache", t, func() {
+		cache, err := hyperscan.NewDatabaseCache(filepath.Join(t.TempDir(), "cache"))
+		So(err, ShouldBeNil)
+
+		b := &hyperscan.DatabaseBuilder{Patterns: hyperscan.Patterns{
+			{Expression: `foo`, Id: 1},
+			{Expression: `ba[rz]`, Flags: hyperscan.SomLeftMost, Id: 2},
+		}}
+
+		Convey("When build a database twice", func() {
+			db, err := cache.Build(b)
+			So(err, ShouldBeNil)
+
+			expected := db.(hyperscan.BlockDatabase).FindAllString("foo bar baz", -1)
+			So(db.Close(), ShouldBeNil)
+
+			So(cache.Path(b), ShouldStartWith, cache.Dir())
+			_, err = os.Stat(cache.Path(b))
+			So(err, ShouldBeNil)
+
+			db, err = cache.Build(b)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			Convey("Then the second one is loaded from the cache", func() {
+				So(cache.Stats(), ShouldResemble, hyperscan.CacheStats{Hits: 1, Misses: 1})
+				So(db.(hyperscan.BlockDatabase).FindAllString("foo bar baz", -1), ShouldResemble, expected)
+			})
+		})
+
+		Convey("When build a stream database", func() {
+			db, err := cache.Build(&hyperscan.DatabaseBuilder{Patterns: b.Patterns, Mode: hyperscan.StreamMode})
+			So(err, ShouldBeNil)
+			So(db.Close(), ShouldBeNil)
+
+			db, err = cache.Build(&hyperscan.DatabaseBuilder{Patterns: b.Patterns, Mode: hyperscan.StreamMode})
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			Convey("Then it is cached under another key", func() {
+				So(cache.Key(&hyperscan.DatabaseBuilder{Patterns: b.Patterns, Mode: hyperscan.StreamMode}),
+					ShouldNotEqual, cache.Key(b))
+				So(cache.Stats().Hits, ShouldEqual, 1)
+				So(db, ShouldImplement, (*hyperscan.StreamDatabase)(nil))
+			})
+		})
+
+		Convey("When the patterns are changed", func() {
+			changed := &hyperscan.DatabaseBuilder{Patterns: hyperscan.Patterns{
+				{Expression: `foo`, Id: 1},
+				{Expression: `ba[rz]`, Flags: hyperscan.SomLeftMost, Id: 3},
+			}}
+
+			Convey("Then the key is changed", func() {
+				So(cache.Key(changed), ShouldNotEqual, cache.Key(b))
+			})
+		})
+
+		Convey("When the cached database is corrupted", func() {
+			So(os.WriteFile(cache.Path(b), []byte("garbage"), 0o600), ShouldBeNil)
+
+			db, err := cache.Build(b)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			Convey("Then it is recompiled", func() {
+				So(cache.Stats(), ShouldResemble, hyperscan.CacheStats{Invalid: 1})
+
+				data, err := os.ReadFile(cache.Path(b))
+				So(err, ShouldBeNil)
+
+				_, err = hyperscan.SerializedDatabaseInfo(data)
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Convey("When build the same database concurrently", func() {
+			var wg sync.WaitGroup
+
+			errs := make([]error, 8)
+
+			for i := range errs {
+				wg.Add(1)
+
+				go func(i int) {
+					defer wg.Done()
+
+					db, err := cache.Build(b)
+					if err == nil {
+						err = db.Close()
+					}
+
+					errs[i] = err
+				}(i)
+			}
+
+			wg.Wait()
+
+			Convey("Then a single file is written", func() {
+				for _, err := range errs {
+					So(err, ShouldBeNil)
+				}
+
+				files, err := os.ReadDir(cache.Dir())
+				So(err, ShouldBeNil)
+				So(files, ShouldHaveLength, 1)
+			})
+		})
+
+		Convey("When build a sharded database", func() {
+			_, err := cache.Build(&hyperscan.DatabaseBuilder{Patterns: b.Patterns, Shards: 2})
+
+			Convey("Then it is rejected", func() {
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+			})
+		})
+	})
+}
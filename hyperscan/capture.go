@@ -0,0 +1,233 @@
+package hyperscan
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// Capturer finds the submatches of a pattern in the region of a match reported by Hyperscan.
+//
+// The *regexp.Regexp implements Capturer, the region must be matched by an expression anchored at the end.
+type Capturer interface {
+	// FindSubmatchIndex returns a slice holding the index pairs identifying the leftmost match
+	// ending at the end of data and the matches, if any, of its subexpressions.
+	// A return value of nil indicates no match.
+	FindSubmatchIndex(data []byte) []int
+}
+
+// CaptureCompiler compiles a pattern into a Capturer.
+type CaptureCompiler func(p *Pattern) (Capturer, error)
+
+// StdCapturer compiles the pattern with the regexp package.
+func StdCapturer(p *Pattern) (Capturer, error) {
+	expr, ok := p.stdExpr()
+	if !ok {
+		return nil, fmt.Errorf("pattern `%s` with extended parameters, %w", p.Expression, ErrInvalid)
+	}
+
+	re, err := regexp.Compile(expr + `\z`)
+	if err != nil {
+		return nil, fmt.Errorf("compile pattern `%s`, %w", p.Expression, err)
+	}
+
+	return re, nil
+}
+
+// CaptureMatcher finds the submatches of the matches reported by a block database, keyed by pattern ID.
+//
+// The capture-capable engine only runs on the region of each match reported by Hyperscan,
+// the matches of a pattern are selected with the Semantics of the database, as BlockReplacer does.
+//
+// The region of a match needs the start of match, so the patterns should be compiled with SomLeftMost.
+// Otherwise, the region of a match starts at the end of the previous match of the same pattern.
+type CaptureMatcher struct {
+	scanner   *blockScanner
+	som       map[uint]bool
+	capturers map[uint][]Capturer
+}
+
+// WithCaptures returns a CaptureMatcher of the block database built by DatabaseBuilder,
+// which compiles the patterns with the capture-capable engine, or the regexp package if compile is nil.
+func WithCaptures(db BlockDatabase, compile CaptureCompiler) (*CaptureMatcher, error) {
+	bdb, ok := db.(*blockDatabase)
+	if !ok || bdb.patterns == nil {
+		return nil, fmt.Errorf("database without patterns, %w", ErrInvalid)
+	}
+
+	if compile == nil {
+		compile = StdCapturer
+	}
+
+	m := &CaptureMatcher{
+		scanner:   bdb.blockScanner,
+		som:       make(map[uint]bool),
+		capturers: make(map[uint][]Capturer),
+	}
+
+	for _, p := range bdb.patterns {
+		if p.Id < 0 {
+			continue
+		}
+
+		c, err := compile(p)
+		if err != nil {
+			_ = m.Close()
+
+			return nil, fmt.Errorf("compile pattern %d, %w", p.Id, err)
+		}
+
+		id := uint(p.Id)
+
+		if _, exists := m.som[id]; !exists {
+			m.som[id] = true
+		}
+
+		m.som[id] = m.som[id] && p.Flags&SomLeftMost == SomLeftMost
+		m.capturers[id] = append(m.capturers[id], c)
+	}
+
+	return m, nil
+}
+
+// Close releases the capturers which implement io.Closer, the database is not closed.
+func (m *CaptureMatcher) Close() error {
+	for _, capturers := range m.capturers {
+		for _, c := range capturers {
+			if closer, ok := c.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+
+	return nil
+}
+
+// FindSubmatch returns the text of the leftmost match of each pattern and the matches of its subexpressions.
+func (m *CaptureMatcher) FindSubmatch(data []byte) map[uint][][]byte {
+	all := m.FindAllSubmatch(data, 1)
+	res := make(map[uint][][]byte, len(all))
+
+	for id, matches := range all {
+		res[id] = matches[0]
+	}
+
+	return res
+}
+
+// FindSubmatchIndex returns the index pairs identifying the leftmost match of each pattern
+// and the matches of its subexpressions.
+func (m *CaptureMatcher) FindSubmatchIndex(data []byte) map[uint][]int {
+	all := m.FindAllSubmatchIndex(data, 1)
+	res := make(map[uint][]int, len(all))
+
+	for id, locs := range all {
+		res[id] = locs[0]
+	}
+
+	return res
+}
+
+// FindAllSubmatch is the 'All' version of FindSubmatch, it returns at most n matches of each pattern
+// (or all the matches if n < 0).
+func (m *CaptureMatcher) FindAllSubmatch(data []byte, n int) map[uint][][][]byte {
+	all := m.FindAllSubmatchIndex(data, n)
+	res := make(map[uint][][][]byte, len(all))
+
+	for id, locs := range all {
+		for _, loc := range locs {
+			submatches := make([][]byte, len(loc)/2)
+
+			for i := range submatches {
+				if loc[2*i] >= 0 {
+					submatches[i] = data[loc[2*i]:loc[2*i+1]:loc[2*i+1]]
+				}
+			}
+
+			res[id] = append(res[id], submatches)
+		}
+	}
+
+	return res
+}
+
+// FindAllSubmatchIndex is the 'All' version of FindSubmatchIndex, it returns at most n matches of each pattern
+// (or all the matches if n < 0).
+func (m *CaptureMatcher) FindAllSubmatchIndex(data []byte, n int) map[uint][][]int {
+	res := make(map[uint][][]int)
+
+	if n == 0 {
+		return res
+	}
+
+	events := make(map[uint][]hs.MatchEvent)
+
+	err := m.scanner.Scan(data, nil, func(id uint, from, to uint64, flags uint, context interface{}) error {
+		events[id] = append(events[id], hs.MatchEvent{ID: id, From: from, To: to})
+
+		return nil
+	}, nil)
+	if err != nil {
+		return res
+	}
+
+	sem := m.scanner.opts.replaceSemantics()
+
+	for id, evts := range events {
+		var locs [][]int
+
+		if m.som[id] {
+			for _, e := range sem.selectMatches(evts, m.scanner.regexps, data) {
+				if loc := m.capture(id, data, int(e.From), int(e.To)); loc != nil {
+					locs = append(locs, loc)
+				}
+
+				if len(locs) == n {
+					break
+				}
+			}
+		} else {
+			pos := 0
+
+			for _, e := range evts {
+				if int(e.To) < pos || (int(e.To) == pos && len(locs) > 0) {
+					continue
+				}
+
+				if loc := m.capture(id, data, pos, int(e.To)); loc != nil {
+					locs = append(locs, loc)
+					pos = loc[1]
+				}
+
+				if len(locs) == n {
+					break
+				}
+			}
+		}
+
+		if len(locs) > 0 {
+			res[id] = locs
+		}
+	}
+
+	return res
+}
+
+// capture finds the submatches of pattern in the region of data.
+func (m *CaptureMatcher) capture(id uint, data []byte, from, to int) []int {
+	for _, c := range m.capturers[id] {
+		if loc := c.FindSubmatchIndex(data[from:to]); loc != nil {
+			for i := range loc {
+				if loc[i] >= 0 {
+					loc[i] += from
+				}
+			}
+
+			return loc
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package hyperscan_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestCaptureMatcher(t *testing.T) {
+	Convey("Given a block database with the capturing patterns", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `(\w+)@(\w+)\.com`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `(\d+)-(\d+)?x`, Flags: hyperscan.SomLeftMost, Id: 2},
+				{Expression: `k(e+)y`, Id: 3},
+			},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		m, err := hyperscan.WithCaptures(db.(hyperscan.BlockDatabase), nil)
+		So(err, ShouldBeNil)
+
+		defer m.Close()
+
+		data := []byte("mail foo@bar.com 12-x or baz@qux.com 3-45x keey ky key")
+
+		Convey("When find the leftmost submatches", func() {
+			res := m.FindSubmatch(data)
+
+			So(res, ShouldHaveLength, 3)
+			So(res[1], ShouldResemble, [][]byte{[]byte("foo@bar.com"), []byte("foo"), []byte("bar")})
+			So(res[2], ShouldResemble, [][]byte{[]byte("12-x"), []byte("12"), nil})
+			So(res[3], ShouldResemble, [][]byte{[]byte("keey"), []byte("ee")})
+
+			So(m.FindSubmatchIndex(data)[1], ShouldResemble, []int{5, 16, 5, 8, 9, 12})
+		})
+
+		Convey("When find all the submatches", func() {
+			res := m.FindAllSubmatchIndex(data, -1)
+
+			So(res[1], ShouldResemble, [][]int{{5, 16, 5, 8, 9, 12}, {25, 36, 25, 28, 29, 32}})
+			So(res[2], ShouldResemble, [][]int{{17, 21, 17, 19, -1, -1}, {37, 42, 37, 38, 39, 41}})
+			So(res[3], ShouldResemble, [][]int{{43, 47, 44, 46}, {51, 54, 52, 53}})
+		})
+
+		Convey("When find the limited submatches", func() {
+			res := m.FindAllSubmatch(data, 1)
+
+			So(res[1], ShouldHaveLength, 1)
+			So(res[2], ShouldHaveLength, 1)
+		})
+
+		Convey("When nothing matched", func() {
+			So(m.FindAllSubmatch([]byte("nothing"), -1), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given a block database without patterns", t, func() {
+		db, err := hyperscan.Compile(`\d+`)
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		_, err = hyperscan.WithCaptures(db.(hyperscan.BlockDatabase), nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given a pattern not supported by the capture engine", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{hyperscan.NewPattern(`foo`, 0, hyperscan.EditDistance(1))},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		_, err = hyperscan.WithCaptures(db.(hyperscan.BlockDatabase), nil)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -114,10 +114,44 @@ type database interface {
 
 type baseDatabase struct {
 	db hs.Database
+
+	// The patterns and their IDs, if the database was built by DatabaseBuilder.
+	patterns   Patterns
+	ids        bitmap
+	prefilters bitmap
+	widths     map[uint]int
+	regexps    *stdRegexps
+
+	// The patterns rejected by Hyperscan, if the database was built by BuildWithFallback.
+	fallbacks fallbacks
+
+	// The other shards, if the database was compiled in shards.
+	shards []hs.Database
+
+	// The mode flags, if the database was built by DatabaseBuilder.
+	mode ModeFlag
+
+	// The tune family of the target platform, if the database was built by DatabaseBuilder.
+	tune TuneFlag
+
+	// Releases the memory of the database placed by LoadDatabase, which isn't freed by Hyperscan.
+	release func() error
+
+	// The record of the leak detector, if the database is tracked.
+	leak *leakRecord
 }
 
 func newBaseDatabase(db hs.Database) *baseDatabase {
-	return &baseDatabase{db}
+	return &baseDatabase{db: db, leak: trackObject("database")}
+}
+
+// builtFrom remembers the patterns which the database was built from.
+func (d *baseDatabase) builtFrom(patterns Patterns) {
+	d.patterns = append(Patterns(nil), patterns...)
+	d.ids = patterns.ids()
+	d.prefilters = patterns.prefilters()
+	d.widths = patterns.prefilterWidths()
+	d.regexps = newStdRegexps(d.patterns)
 }
 
 // UnmarshalDatabase reconstruct a pattern database from a stream of bytes.
@@ -127,7 +161,19 @@ func UnmarshalDatabase(data []byte) (Database, error) {
 		return nil, err //nolint: wrapcheck
 	}
 
-	return &baseDatabase{db}, nil
+	return newBaseDatabase(db), nil
+}
+
+// unmarshalDatabase reconstructs a database of the mode from a stream of bytes.
+func unmarshalDatabase(data []byte, mode ModeFlag) (Database, error) {
+	switch mode & hs.ModeMask {
+	case StreamMode:
+		return UnmarshalStreamDatabase(data)
+	case VectoredMode:
+		return UnmarshalVectoredDatabase(data)
+	default:
+		return UnmarshalBlockDatabase(data)
+	}
 }
 
 // UnmarshalBlockDatabase reconstruct a block database from a stream of bytes.
@@ -172,9 +218,35 @@ func SerializedDatabaseInfo(data []byte) (DbInfo, error) {
 
 func (d *baseDatabase) c() hs.Database { return d.db }
 
-func (d *baseDatabase) Size() (int, error) { return hs.DatabaseSize(d.db) } //nolint: wrapcheck
+func (d *baseDatabase) base() *baseDatabase { return d }
+
+func (d *baseDatabase) Size() (int, error) {
+	if err := d.leak.use("size of"); err != nil {
+		return 0, err
+	}
+
+	size, err := hs.DatabaseSize(d.db)
+	if err != nil {
+		return 0, err //nolint: wrapcheck
+	}
+
+	for _, shard := range d.shards {
+		n, err := hs.DatabaseSize(shard)
+		if err != nil {
+			return 0, err //nolint: wrapcheck
+		}
+
+		size += n
+	}
+
+	return size, nil
+}
 
 func (d *baseDatabase) Info() (DbInfo, error) {
+	if err := d.leak.use("info of"); err != nil {
+		return "", err
+	}
+
 	i, err := hs.DatabaseInfo(d.db)
 	if err != nil {
 		return "", err //nolint: wrapcheck
@@ -183,8 +255,41 @@ func (d *baseDatabase) Info() (DbInfo, error) {
 	return DbInfo(i), nil
 }
 
-func (d *baseDatabase) Close() error { return hs.FreeDatabase(d.db) } //nolint: wrapcheck
+func (d *baseDatabase) Close() error {
+	if err := d.leak.release("close"); err != nil {
+		return err
+	}
+
+	d.fallbacks.close()
+	freeDatabases(d.shards)
+
+	if d.release != nil {
+		return d.release()
+	}
+
+	return hs.FreeDatabase(d.db) //nolint: wrapcheck
+}
+
+func (d *baseDatabase) Marshal() ([]byte, error) {
+	if err := d.leak.use("marshal"); err != nil {
+		return nil, err
+	}
+
+	if len(d.shards) > 0 {
+		return nil, fmt.Errorf("marshal database of %d shards, %w", d.Shards(), ErrInvalid)
+	}
+
+	return hs.SerializeDatabase(d.db) //nolint: wrapcheck
+}
+
+func (d *baseDatabase) Unmarshal(data []byte) error {
+	if err := d.leak.use("unmarshal"); err != nil {
+		return err
+	}
 
-func (d *baseDatabase) Marshal() ([]byte, error) { return hs.SerializeDatabase(d.db) } //nolint: wrapcheck
+	if len(d.shards) > 0 {
+		return fmt.Errorf("unmarshal database of %d shards, %w", d.Shards(), ErrInvalid)
+	}
 
-func (d *baseDatabase) Unmarshal(data []byte) error { return hs.DeserializeDatabaseAt(data, d.db) } //nolint: wrapcheck
+	return hs.DeserializeDatabaseAt(data, d.db) //nolint: wrapcheck
+}
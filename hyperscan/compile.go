@@ -1,6 +1,7 @@
 package hyperscan
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
 	"strconv"
@@ -140,6 +141,12 @@ type DatabaseBuilder struct {
 	// If not nil, the platform structure is used to determine the target platform for the database.
 	// If nil, a database suitable for running on the current host platform is produced.
 	Platform Platform
+
+	// The number of shards compiled concurrently, a single database is compiled if less than 2.
+	Shards int
+
+	// Partition assigns the patterns to the shards. (Default: PartitionByCount)
+	Partition Partitioner
 }
 
 // AddExpressions add more expressions to the database.
@@ -164,6 +171,80 @@ func (b *DatabaseBuilder) Build() (Database, error) {
 		return nil, ErrInvalid
 	}
 
+	mode := b.mode()
+	platform, _ := b.Platform.(*hs.PlatformInfo)
+
+	dbs, err := b.compile(mode, platform)
+	if err != nil {
+		return nil, b.compileError(err)
+	}
+
+	return b.newDatabase(mode, dbs)
+}
+
+// newDatabase creates a database of the mode from the compiled shards.
+func (b *DatabaseBuilder) newDatabase(mode ModeFlag, dbs []hs.Database) (Database, error) {
+	switch mode & hs.ModeMask {
+	case StreamMode:
+		sdb := newStreamDatabase(dbs[0])
+		sdb.shards = dbs[1:]
+		sdb.builtFrom(b.Patterns)
+		sdb.mode = mode
+		sdb.tune = b.tune()
+
+		return sdb, nil
+	case VectoredMode:
+		vdb := newVectoredDatabase(dbs[0])
+		vdb.shards = dbs[1:]
+		vdb.builtFrom(b.Patterns)
+		vdb.mode = mode
+		vdb.tune = b.tune()
+
+		return vdb, nil
+	case BlockMode:
+		bdb := newBlockDatabase(dbs[0])
+		bdb.shards = dbs[1:]
+		bdb.builtFrom(b.Patterns)
+		bdb.mode = mode
+		bdb.tune = b.tune()
+
+		return bdb, nil
+	default:
+		freeDatabases(dbs)
+
+		return nil, fmt.Errorf("mode %d, %w", mode, ErrInvalid)
+	}
+}
+
+// tune returns the tune family of the target platform, or the one of the host if the platform isn't given.
+func (b *DatabaseBuilder) tune() TuneFlag {
+	if b.Platform != nil {
+		return b.Platform.Tune()
+	}
+
+	if platform, err := hs.PopulatePlatform(); err == nil {
+		return platform.Tune()
+	}
+
+	return Generic
+}
+
+// compileError attaches the failing pattern to the compile error, if the pattern was parsed from a source file.
+func (b *DatabaseBuilder) compileError(err error) error {
+	var compileErr *CompileError
+
+	if errors.As(err, &compileErr) && compileErr.Expression >= 0 && compileErr.Expression < len(b.Patterns) {
+		if p := b.Patterns[compileErr.Expression]; p.Pos.IsValid() {
+			return &PatternError{p, compileErr.Expression, err}
+		}
+	}
+
+	return err //nolint: wrapcheck
+}
+
+// mode returns the mode of database, which defaults to the block mode,
+// and the small SOM horizon for the streaming patterns with SomLeftMost.
+func (b *DatabaseBuilder) mode() ModeFlag {
 	mode := b.Mode
 
 	if mode == 0 {
@@ -182,23 +263,7 @@ func (b *DatabaseBuilder) Build() (Database, error) {
 		}
 	}
 
-	platform, _ := b.Platform.(*hs.PlatformInfo)
-
-	db, err := hs.CompileMulti(b.Patterns, mode, platform)
-	if err != nil {
-		return nil, err //nolint: wrapcheck
-	}
-
-	switch mode & hs.ModeMask {
-	case StreamMode:
-		return newStreamDatabase(db), nil
-	case VectoredMode:
-		return newVectoredDatabase(db), nil
-	case BlockMode:
-		return newBlockDatabase(db), nil
-	default:
-		return nil, fmt.Errorf("mode %d, %w", mode, ErrInvalid)
-	}
+	return mode
 }
 
 // NewBlockDatabase create a block database base on the patterns.
@@ -0,0 +1,306 @@
+package hyperscan
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// ContainerVersion is the layout version of the database container written by Container.WriteTo.
+const ContainerVersion = 1
+
+// maxContainerData is the largest serialized database accepted by Container.ReadFrom.
+const maxContainerData = 1 << 40
+
+// containerMagic identifies the database container.
+var containerMagic = [4]byte{'G', 'H', 'S', 'D'}
+
+// containerHeader is the fixed size header of the database container, followed by the manifest and the data.
+type containerHeader struct {
+	Magic       [4]byte
+	Version     uint16
+	_           uint16
+	ManifestLen uint32
+	DataLen     uint64
+	Checksum    uint32 // The CRC-32C of the manifest and the data.
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Manifest describes the serialized database of a container.
+type Manifest struct {
+	// The mode flags of the database, including the SOM horizon.
+	Mode ModeFlag `json:"-"`
+
+	// The version of Hyperscan which compiled the database.
+	Version string `json:"version"`
+
+	// The version and platform information of the database.
+	Info DbInfo `json:"info"`
+
+	// The tune family the database was compiled for,
+	// if the database was built by DatabaseBuilder for a platform other than Generic.
+	Tune TuneFlag `json:"tune,omitempty"`
+
+	// The time the container was created.
+	Created time.Time `json:"created"`
+
+	// The patterns of the database and their metadata, if any.
+	Rules *PatternSet `json:"rules,omitempty"`
+}
+
+var somHorizons = map[ModeFlag]string{
+	SomHorizonSmallMode:  "small",
+	SomHorizonMediumMode: "medium",
+	SomHorizonLargeMode:  "large",
+}
+
+// jsonManifest is the JSON form of Manifest, which writes the mode and the SOM horizon as strings.
+type jsonManifest struct {
+	*manifestAlias
+	Mode       string `json:"mode"`
+	SomHorizon string `json:"som_horizon,omitempty"`
+}
+
+type manifestAlias Manifest
+
+// SomHorizon returns the SOM horizon of the streaming database, or zero if not set.
+func (m *Manifest) SomHorizon() ModeFlag {
+	return m.Mode & (SomHorizonSmallMode | SomHorizonMediumMode | SomHorizonLargeMode)
+}
+
+// Patterns returns the patterns of the database, or nil if unknown.
+func (m *Manifest) Patterns() Patterns {
+	if m.Rules == nil {
+		return nil
+	}
+
+	return m.Rules.Patterns()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonManifest{ //nolint: wrapcheck
+		(*manifestAlias)(m), (m.Mode & hs.ModeMask).String(), somHorizons[m.SomHorizon()],
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Manifest) UnmarshalJSON(b []byte) error {
+	v := jsonManifest{manifestAlias: (*manifestAlias)(m)}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	mode, err := ParseModeFlag(v.Mode)
+	if err != nil {
+		return err
+	}
+
+	if v.SomHorizon != "" {
+		found := false
+
+		for flag, name := range somHorizons {
+			if strings.EqualFold(name, v.SomHorizon) {
+				mode |= flag
+				found = true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("SOM horizon `%s`, %w", v.SomHorizon, ErrInvalid)
+		}
+	}
+
+	m.Mode = mode
+
+	return nil
+}
+
+// Container is a self-describing serialized database, which holds the raw Hyperscan database with its manifest.
+//
+// The container is written as a header, the manifest in the JSON format and the serialized database.
+// The header holds a magic number, the layout version, the lengths of the manifest and the database,
+// and the checksum of both, so a container written by a newer layout is rejected by an older reader.
+type Container struct {
+	Manifest
+
+	// The serialized database.
+	Data []byte
+}
+
+// NewContainer serializes the database with its manifest.
+func NewContainer(db Database) (*Container, error) {
+	data, err := db.Marshal()
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	info, err := db.Info()
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	c := &Container{Manifest: Manifest{Version: Version(), Info: info, Created: time.Now().UTC()}, Data: data}
+
+	if d, ok := db.(interface{ base() *baseDatabase }); ok {
+		base := d.base()
+
+		c.Mode = base.mode
+		c.Tune = base.tune
+
+		if base.patterns != nil {
+			c.Rules = NewPatternSet(base.patterns)
+		}
+	}
+
+	if c.Mode == 0 {
+		if c.Mode, err = info.Mode(); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// ReadContainer reads a container.
+func ReadContainer(r io.Reader) (*Container, error) {
+	var c Container
+
+	if _, err := c.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// LoadContainer reads a container, and reconstructs its database.
+func LoadContainer(r io.Reader) (Database, *Manifest, error) {
+	c, err := ReadContainer(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := c.Database()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return db, &c.Manifest, nil
+}
+
+// Database reconstructs the database of the container, after validating the database info against the manifest.
+func (c *Container) Database() (Database, error) {
+	info, err := SerializedDatabaseInfo(c.Data)
+	if err != nil {
+		return nil, fmt.Errorf("container database info, %w", err)
+	}
+
+	if info != c.Info {
+		return nil, fmt.Errorf("container database info `%s`, expected `%s`, %w", info, c.Info, ErrInvalid)
+	}
+
+	db, err := unmarshalDatabase(c.Data, c.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	if d, ok := db.(interface{ base() *baseDatabase }); ok {
+		base := d.base()
+		base.mode = c.Mode
+		base.tune = c.Tune
+
+		if patterns := c.Patterns(); patterns != nil {
+			base.builtFrom(patterns)
+		}
+	}
+
+	return db, nil
+}
+
+// WriteTo writes the container.
+func (c *Container) WriteTo(w io.Writer) (int64, error) {
+	manifest, err := json.Marshal(&c.Manifest)
+	if err != nil {
+		return 0, fmt.Errorf("encode manifest, %w", err)
+	}
+
+	h := containerHeader{
+		Magic:       containerMagic,
+		Version:     ContainerVersion,
+		ManifestLen: uint32(len(manifest)),
+		DataLen:     uint64(len(c.Data)),
+		Checksum:    crc32.Update(crc32.Checksum(manifest, castagnoli), castagnoli, c.Data),
+	}
+
+	var buf bytes.Buffer
+
+	_ = binary.Write(&buf, binary.LittleEndian, &h)
+
+	cw := &countWriter{w: w}
+
+	for _, b := range [][]byte{buf.Bytes(), manifest, c.Data} {
+		if _, err = cw.Write(b); err != nil {
+			return cw.n, fmt.Errorf("write container, %w", err)
+		}
+	}
+
+	return cw.n, nil
+}
+
+// ReadFrom reads the container, which is rejected if it is written by a newer layout or corrupted.
+func (c *Container) ReadFrom(r io.Reader) (int64, error) {
+	var h containerHeader
+
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return 0, fmt.Errorf("read container header, %w", err)
+	}
+
+	n := int64(binary.Size(&h))
+
+	if h.Magic != containerMagic {
+		return n, fmt.Errorf("container magic %q, %w", h.Magic[:], ErrInvalid)
+	}
+
+	if h.Version > ContainerVersion {
+		return n, fmt.Errorf("container version %d, newer than %d, %w", h.Version, ContainerVersion, ErrInvalid)
+	}
+
+	if h.DataLen > maxContainerData {
+		return n, fmt.Errorf("container data of %d bytes, %w", h.DataLen, ErrInvalid)
+	}
+
+	var buf bytes.Buffer
+
+	copied, err := io.CopyN(&buf, r, int64(h.ManifestLen)+int64(h.DataLen))
+	n += copied
+
+	if err != nil {
+		return n, fmt.Errorf("read container, %w", err)
+	}
+
+	b := buf.Bytes()
+
+	if sum := crc32.Checksum(b, castagnoli); sum != h.Checksum {
+		return n, fmt.Errorf("container checksum %08x, expected %08x, %w", sum, h.Checksum, ErrInvalid)
+	}
+
+	var manifest Manifest
+
+	if err = json.Unmarshal(b[:h.ManifestLen], &manifest); err != nil {
+		return n, fmt.Errorf("decode manifest, %w", err)
+	}
+
+	c.Manifest = manifest
+	c.Data = b[h.ManifestLen:]
+
+	return n, nil
+}
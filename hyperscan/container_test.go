@@ -0,0 +1,181 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestContainer(t *testing.T) {
+	Convey("Given a database built for a platform", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{{Expression: `foo`, Id: 1}},
+			Platform: hyperscan.NewPlatform(hyperscan.Haswell, 0),
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("When write it in a container", func() {
+			c, err := hyperscan.NewContainer(db)
+			So(err, ShouldBeNil)
+
+			var buf bytes.Buffer
+
+			_, err = c.WriteTo(&buf)
+			So(err, ShouldBeNil)
+
+			Convey("Then the tune family is kept by the manifest and the loaded database", func() {
+				So(c.Tune, ShouldEqual, hyperscan.Haswell)
+
+				loaded, manifest, err := hyperscan.LoadContainer(&buf)
+				So(err, ShouldBeNil)
+
+				defer loaded.Close()
+
+				So(manifest.Tune, ShouldEqual, hyperscan.Haswell)
+
+				c, err := hyperscan.NewContainer(loaded)
+				So(err, ShouldBeNil)
+				So(c.Tune, ShouldEqual, hyperscan.Haswell)
+			})
+		})
+	})
+
+	Convey("Given a streaming database", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+			{Expression: `ba[rz]`, Flags: hyperscan.SomLeftMost | hyperscan.Caseless, Id: 2},
+		}
+
+		db, err := patterns.Build(hyperscan.StreamMode)
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("When write it in a container", func() {
+			c, err := hyperscan.NewContainer(db)
+			So(err, ShouldBeNil)
+			So(c.Mode, ShouldEqual, hyperscan.StreamMode|hyperscan.SomHorizonSmallMode)
+			So(c.Tune, ShouldEqual, hyperscan.PopulatePlatform().Tune())
+
+			c.Rules.Rules[1].Name = "bar-or-baz"
+
+			var buf bytes.Buffer
+
+			n, err := c.WriteTo(&buf)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, buf.Len())
+
+			data := buf.Bytes()
+
+			Convey("Then the database and its manifest are loaded", func() {
+				loaded, manifest, err := hyperscan.LoadContainer(bytes.NewReader(data))
+				So(err, ShouldBeNil)
+
+				defer loaded.Close()
+
+				So(manifest.Mode, ShouldEqual, hyperscan.StreamMode|hyperscan.SomHorizonSmallMode)
+				So(manifest.SomHorizon(), ShouldEqual, hyperscan.SomHorizonSmallMode)
+				So(manifest.Version, ShouldEqual, hyperscan.Version())
+				So(manifest.Rules.Index()[2].Name, ShouldEqual, "bar-or-baz")
+				So(manifest.Patterns()[1].String(), ShouldEqual, patterns[1].String())
+
+				info, err := db.Info()
+				So(err, ShouldBeNil)
+				So(manifest.Info, ShouldEqual, info)
+
+				So(loaded, ShouldImplement, (*hyperscan.StreamDatabase)(nil))
+				So(loaded.(hyperscan.StreamDatabase).FindAllIndex(bytes.NewReader([]byte("foo BAR")), -1),
+					ShouldResemble, [][]int{{0, 3}, {4, 7}})
+			})
+
+			Convey("Then a newer layout is rejected", func() {
+				binary.LittleEndian.PutUint16(data[4:], hyperscan.ContainerVersion+1)
+
+				_, err := hyperscan.ReadContainer(bytes.NewReader(data))
+
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "newer")
+			})
+
+			Convey("Then a corrupted container is rejected", func() {
+				data[len(data)-1] ^= 0xFF
+
+				_, err := hyperscan.ReadContainer(bytes.NewReader(data))
+
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "checksum")
+			})
+
+			Convey("Then a truncated container is rejected", func() {
+				_, err := hyperscan.ReadContainer(bytes.NewReader(data[:len(data)-1]))
+
+				So(err, ShouldNotBeNil)
+			})
+
+			Convey("Then a raw database is rejected", func() {
+				raw, err := db.Marshal()
+				So(err, ShouldBeNil)
+
+				_, err = hyperscan.ReadContainer(bytes.NewReader(raw))
+
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given a block database", t, func() {
+		db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo\d+`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("When load it from a container", func() {
+			c, err := hyperscan.NewContainer(db)
+			So(err, ShouldBeNil)
+
+			var buf bytes.Buffer
+
+			_, err = c.WriteTo(&buf)
+			So(err, ShouldBeNil)
+
+			loaded, manifest, err := hyperscan.LoadContainer(&buf)
+			So(err, ShouldBeNil)
+
+			defer loaded.Close()
+
+			Convey("Then it matches as the original one", func() {
+				So(manifest.Mode, ShouldEqual, hyperscan.BlockMode)
+				So(manifest.SomHorizon(), ShouldEqual, 0)
+				So(loaded.(hyperscan.BlockDatabase).FindAllString("foo1 foo23", -1), ShouldResemble,
+					db.FindAllString("foo1 foo23", -1))
+			})
+		})
+	})
+
+	Convey("Given a sharded database", t, func() {
+		b := hyperscan.DatabaseBuilder{Patterns: hyperscan.Patterns{
+			hyperscan.NewPattern(`foo`, 0), hyperscan.NewPattern(`bar`, 0),
+		}, Shards: 2}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("Then it can't be put in a container", func() {
+			_, err := hyperscan.NewContainer(db)
+
+			So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,554 @@
+package hyperscan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp/syntax"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// FallbackMatcher finds the matches of a pattern rejected by Hyperscan with a secondary engine.
+//
+// The *regexp.Regexp, *Regexp and the block databases of Hyperscan and Chimera implement FallbackMatcher.
+type FallbackMatcher interface {
+	// FindAllIndex returns at most n successive matches of the pattern in data (or all the matches if n < 0).
+	FindAllIndex(data []byte, n int) [][]int
+}
+
+// Fallback compiles a pattern rejected by Hyperscan with a secondary engine.
+type Fallback func(p *Pattern) (FallbackMatcher, error)
+
+// RegexpFallback compiles the pattern with CompileRegexp, which verifies the candidate matches
+// of the pattern compiled with PrefilterMode, or runs the regexp package.
+func RegexpFallback(p *Pattern) (FallbackMatcher, error) {
+	expr, ok := p.stdExpr()
+	if !ok {
+		return nil, fmt.Errorf("pattern `%s` with extended parameters, %w", p.Expression, ErrInvalid)
+	}
+
+	return CompileRegexp(expr)
+}
+
+// PrefilterFallback compiles the pattern with PrefilterMode,
+// and verifies the candidate matches with the capture-capable engine, or the regexp package if compile is nil.
+//
+// The matches are selected with the LeftmostLongest semantics.
+func PrefilterFallback(compile CaptureCompiler) Fallback {
+	return func(p *Pattern) (FallbackMatcher, error) {
+		pattern := *p
+		pattern.Flags = p.Flags&^SomLeftMost | PrefilterMode
+
+		b := DatabaseBuilder{Patterns: Patterns{&pattern}}
+
+		db, err := b.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		verifier, err := NewVerifier(db, compile)
+		if err != nil {
+			_ = db.Close()
+
+			return nil, err
+		}
+
+		view, err := WithScanOptions(db, VerifyPrefilter(verifier, nil), MatchSemantics(LeftmostLongest))
+		if err != nil {
+			_ = db.Close()
+
+			return nil, err
+		}
+
+		return &prefilterMatcher{view.(BlockDatabase), verifier}, nil
+	}
+}
+
+type prefilterMatcher struct {
+	BlockDatabase
+	verifier Verifier
+}
+
+func (m *prefilterMatcher) String() string { return PrefilterEngine.String() }
+
+func (m *prefilterMatcher) Close() error {
+	if closer, ok := m.verifier.(io.Closer); ok {
+		_ = closer.Close()
+	}
+
+	return m.BlockDatabase.Close() //nolint: wrapcheck
+}
+
+// BuildReport lists the patterns rejected by Hyperscan, which were rerouted to the fallback engine.
+type BuildReport struct {
+	Rerouted []*ReroutedPattern
+}
+
+// ReroutedPattern is a pattern rejected by Hyperscan and compiled with the fallback engine.
+type ReroutedPattern struct {
+	*Pattern
+
+	// The reason why Hyperscan rejected the pattern.
+	Reason error
+
+	// The name of the fallback engine.
+	Engine string
+}
+
+func (p *ReroutedPattern) String() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Pattern, p.Reason, p.Engine)
+}
+
+// BuildWithFallback builds a database of the patterns accepted by Hyperscan,
+// and compiles each rejected pattern with the fallback engine, or RegexpFallback if fallback is nil.
+//
+// If the whole set fails to compile, each pattern is compiled individually, in parallel,
+// then the failure of the others is bisected as Validate does,
+// so the accepted patterns are compiled once rather than once per rejected pattern.
+//
+// The returned database scans both Hyperscan and the fallback engines,
+// and merges their matches under the original pattern IDs in the order of the end of match.
+// The report lists every rerouted pattern and the reason.
+//
+// The fallback matches are the ones reported by FindAllIndex of the fallback engine,
+// which are non-overlapping, rather than every match reported by Hyperscan.
+// In streaming mode, each write is scanned by the fallback engine with the end of the previous data,
+// as much as the largest width of a match of the pattern, or 64KiB if the width is unbounded,
+// so a longer match could not be found. The assertions like `^` or `\b` see the rune preceding the scanned data,
+// and a match starting before it is discarded. The fallback engines are not serialized by Marshal.
+func (b *DatabaseBuilder) BuildWithFallback(fallback Fallback) (Database, *BuildReport, error) {
+	if len(b.Patterns) == 0 {
+		return nil, nil, ErrInvalid
+	}
+
+	if fallback == nil {
+		fallback = RegexpFallback
+	}
+
+	db, err := b.Build()
+	if err == nil {
+		return db, &BuildReport{}, nil
+	}
+
+	var compileErr *CompileError
+
+	if !errors.As(err, &compileErr) {
+		return nil, nil, err
+	}
+
+	db, rejected, err := b.buildAccepted()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &BuildReport{}
+
+	var matchers fallbacks
+
+	for _, rejected := range rejected {
+		p := rejected.Pattern
+
+		m, fallbackErr := fallback(p)
+		if fallbackErr != nil {
+			matchers.close()
+
+			if db != nil {
+				_ = db.Close()
+			}
+
+			return nil, nil, fmt.Errorf("pattern %s rejected by Hyperscan (%s) and fallback, %w", p, rejected.Err, fallbackErr)
+		}
+
+		if p.Id >= 0 {
+			matchers = append(matchers, &fallbackPattern{uint(p.Id), matchWidth(p), m})
+		}
+
+		report.Rerouted = append(report.Rerouted, &ReroutedPattern{p, rejected.Err, engineName(m)})
+	}
+
+	if db == nil {
+		matchers.close()
+
+		return nil, report, fmt.Errorf("no pattern accepted by Hyperscan, %w", ErrInvalid)
+	}
+
+	base := db.(interface{ base() *baseDatabase }).base()
+	base.builtFrom(b.Patterns)
+	base.fallbacks = matchers
+
+	return db, report, nil
+}
+
+// buildAccepted finds the patterns rejected by Hyperscan, individually then with the others,
+// and builds the database of the accepted patterns, or returns nil if there is none.
+//
+// The rejected patterns are returned in the order of the patterns.
+func (b *DatabaseBuilder) buildAccepted() (Database, []*PatternError, error) {
+	var (
+		rejected []*PatternError
+		valid    []int
+	)
+
+	for i, err := range b.validateEach(b.compileOnly()) {
+		if err == nil {
+			valid = append(valid, i)
+		} else {
+			rejected = append(rejected, err)
+		}
+	}
+
+	if len(valid) == 0 {
+		return nil, rejected, nil
+	}
+
+	builder := *b
+
+	var db Database
+
+	// bisect stops at the first successful compile of the remaining patterns, which is kept as the database.
+	build := func(patterns Patterns) error {
+		builder.Patterns = patterns
+
+		built, err := builder.Build()
+		if err != nil {
+			return err
+		}
+
+		if db != nil {
+			_ = db.Close()
+		}
+
+		db = built
+
+		return nil
+	}
+
+	failed := bisect(b.Patterns, valid, build)
+
+	for _, err := range failed {
+		var compileErr *CompileError
+
+		if !errors.As(err.Err, &compileErr) {
+			if db != nil {
+				_ = db.Close()
+			}
+
+			return nil, nil, err.Err
+		}
+	}
+
+	if len(failed) == len(valid) && db != nil {
+		_ = db.Close()
+		db = nil
+	}
+
+	rejected = append(rejected, failed...)
+
+	sort.Slice(rejected, func(i, j int) bool { return rejected[i].Index < rejected[j].Index })
+
+	return db, rejected, nil
+}
+
+func engineName(m FallbackMatcher) string {
+	switch m := m.(type) {
+	case interface{ Engine() Engine }:
+		return m.Engine().String()
+	case fmt.Stringer:
+		return m.String()
+	default:
+		return fmt.Sprintf("%T", m)
+	}
+}
+
+type fallbackPattern struct {
+	id    uint
+	width int // The largest width of a match in bytes, or -1 if unbounded.
+	FallbackMatcher
+}
+
+// matchWidth returns the largest width of a match of the pattern in bytes,
+// or -1 if it is unbounded or the expression isn't supported by the regexp package.
+func matchWidth(p *Pattern) int {
+	expr, ok := p.stdExpr()
+	if !ok {
+		return -1
+	}
+
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return -1
+	}
+
+	return maxWidth(re.Simplify())
+}
+
+// maxWidth returns the largest width of a match of the regexp in bytes, or -1 if it is unbounded.
+//
+//nolint:cyclop
+func maxWidth(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpNoMatch, syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return 0
+	case syntax.OpLiteral:
+		n := 0
+
+		for _, r := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				n += utf8.UTFMax
+			} else {
+				n += utf8.RuneLen(r)
+			}
+		}
+
+		return n
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return 0
+		}
+
+		return utf8.RuneLen(re.Rune[len(re.Rune)-1])
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		return utf8.UTFMax
+	case syntax.OpCapture, syntax.OpQuest:
+		return maxWidth(re.Sub[0])
+	case syntax.OpRepeat:
+		n := maxWidth(re.Sub[0])
+		if n < 0 || (re.Max < 0 && n > 0) {
+			return -1
+		}
+
+		if re.Max < 0 {
+			return 0
+		}
+
+		return n * re.Max
+	case syntax.OpStar, syntax.OpPlus:
+		if maxWidth(re.Sub[0]) == 0 {
+			return 0
+		}
+
+		return -1
+	case syntax.OpConcat, syntax.OpAlternate:
+		width := 0
+
+		for _, sub := range re.Sub {
+			n := maxWidth(sub)
+			if n < 0 {
+				return -1
+			}
+
+			if re.Op == syntax.OpConcat {
+				width += n
+			} else if n > width {
+				width = n
+			}
+		}
+
+		return width
+	default:
+		return -1
+	}
+}
+
+// fallbacks are the patterns compiled with the fallback engines.
+type fallbacks []*fallbackPattern
+
+// events returns the matches of the fallback engines in data at the offset, in the order of the end of match.
+func (f fallbacks) events(data []byte, offset uint64) (events []hs.MatchEvent) {
+	for _, p := range f {
+		for _, loc := range p.FindAllIndex(data, -1) {
+			events = append(events, hs.MatchEvent{ID: p.id, From: offset + uint64(loc[0]), To: offset + uint64(loc[1])})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].To < events[j].To })
+
+	return
+}
+
+func (f fallbacks) close() {
+	for _, p := range f {
+		if closer, ok := p.FallbackMatcher.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+// matchMerge merges the matches of the fallback engines or the other shards into the matches reported by Hyperscan.
+type matchMerge struct {
+	events []hs.MatchEvent
+}
+
+// with merges the events in the order of the end of match, m could be nil.
+func (m *matchMerge) with(events []hs.MatchEvent) *matchMerge {
+	if m == nil {
+		return &matchMerge{events}
+	}
+
+	merged := append(append([]hs.MatchEvent(nil), m.events...), events...)
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].To < merged[j].To })
+
+	return &matchMerge{merged}
+}
+
+// newMerge returns a matchMerge for a scan of data, or nil if there is no fallback engine.
+func (f fallbacks) newMerge(data []byte) *matchMerge {
+	if len(f) == 0 {
+		return nil
+	}
+
+	return &matchMerge{f.events(data, 0)}
+}
+
+func (m *matchMerge) handler(next MatchHandler) MatchHandler {
+	if next == nil {
+		next = func(uint, uint64, uint64, uint, interface{}) error { return nil }
+	}
+
+	return func(id uint, from, to uint64, flags uint, context interface{}) error {
+		for len(m.events) > 0 && m.events[0].To <= to {
+			e := m.events[0]
+			m.events = m.events[1:]
+
+			if err := next(e.ID, e.From, e.To, 0, context); err != nil {
+				return err
+			}
+		}
+
+		return next(id, from, to, flags, context)
+	}
+}
+
+// flush reports the remaining matches of the fallback engines once Hyperscan completed the scan.
+func (m *matchMerge) flush(next MatchHandler, context interface{}, err error) error {
+	if err != nil || next == nil {
+		return err
+	}
+
+	for _, e := range m.events {
+		if err := next(e.ID, e.From, e.To, 0, context); err != nil {
+			var hsErr Error
+
+			if errors.As(err, &hsErr) {
+				return hsErr
+			}
+
+			return ErrScanTerminated
+		}
+	}
+
+	m.events = nil
+
+	return nil
+}
+
+// fallbackStream finds the matches of the fallback engines in the history of a stream.
+//
+// The history is as long as the largest width of a match of the fallback engines, at most 64KiB.
+// Each fallback engine scans the new data with the history after its last match,
+// and only reports the matches ending in the new data.
+//
+// The rune preceding the scanned history is kept as the context of the assertions like `^` or `\b`,
+// the matches starting in the context are discarded.
+type fallbackStream struct {
+	fallbacks
+	data   []byte   // the history of the stream, with the context.
+	offset uint64   // the offset of data in the stream.
+	window int      // the length of the history.
+	resume []uint64 // the end of the last match of each fallback engine.
+}
+
+// contextSize is the length of the context kept before the history of a stream.
+const contextSize = utf8.UTFMax
+
+func (f fallbacks) newStream() *fallbackStream {
+	if len(f) == 0 {
+		return nil
+	}
+
+	window := 0
+
+	for _, p := range f {
+		if p.width < 0 || p.width > historySize {
+			window = historySize
+
+			break
+		}
+
+		if p.width > window {
+			window = p.width
+		}
+	}
+
+	return &fallbackStream{fallbacks: f, window: window, resume: make([]uint64, len(f))}
+}
+
+// merge appends the data to the history, and returns the new matches of the fallback engines.
+func (s *fallbackStream) merge(data []byte) *matchMerge {
+	end := s.offset + uint64(len(s.data))
+
+	if n := len(s.data) - s.window - contextSize; n > 0 {
+		s.data = append(s.data[:0], s.data[n:]...)
+		s.offset += uint64(n)
+	}
+
+	s.data = append(s.data, data...)
+
+	var events []hs.MatchEvent
+
+	for i, p := range s.fallbacks {
+		from := s.offset
+
+		if s.offset > 0 {
+			from += contextSize
+		}
+
+		if s.resume[i] > from {
+			from = s.resume[i]
+		}
+
+		if w := uint64(p.width); p.width >= 0 && end > w && end-w > from {
+			from = end - w
+		}
+
+		_, size := utf8.DecodeLastRune(s.data[:from-s.offset])
+		start := from - uint64(size)
+
+		for _, loc := range p.FindAllIndex(s.data[start-s.offset:], -1) {
+			if loc[0] < size {
+				continue
+			}
+
+			if to := start + uint64(loc[1]); to > end {
+				events = append(events, hs.MatchEvent{ID: p.id, From: start + uint64(loc[0]), To: to})
+				s.resume[i] = to
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].To < events[j].To })
+
+	return &matchMerge{events}
+}
+
+func (s *fallbackStream) reset() {
+	s.data = s.data[:0]
+	s.offset = 0
+
+	for i := range s.resume {
+		s.resume[i] = 0
+	}
+}
+
+func (s *fallbackStream) clone() *fallbackStream {
+	cloned := *s
+	cloned.data = append([]byte(nil), s.data...)
+	cloned.resume = append([]uint64(nil), s.resume...)
+
+	return &cloned
+}
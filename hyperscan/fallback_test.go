@@ -0,0 +1,276 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+// doubled captures the matches of `(\w)\1` ending at the end of data.
+type doubled struct{}
+
+func (doubled) FindSubmatchIndex(data []byte) []int {
+	if n := len(data); n >= 2 && data[n-1] == data[n-2] {
+		return []int{n - 2, n, n - 2, n - 1}
+	}
+
+	return nil
+}
+
+//nolint:funlen
+func TestBuildWithFallback(t *testing.T) {
+	Convey("Given a pattern set with a pattern rejected by Hyperscan", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+			{Expression: `(?U)a+b`, Flags: hyperscan.SomLeftMost, Id: 2},
+		}
+
+		type match struct {
+			id       uint
+			from, to uint64
+		}
+
+		var matches []match
+
+		handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+			matches = append(matches, match{id, from, to})
+
+			return nil
+		}
+
+		Convey("When build it as usual", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns}
+
+			_, err := b.Build()
+
+			Convey("Then the whole build fails", func() {
+				var compileErr *hyperscan.CompileError
+
+				So(errors.As(err, &compileErr), ShouldBeTrue)
+				So(compileErr.Expression, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When build a block database with the default fallback", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns}
+
+			db, report, err := b.BuildWithFallback(nil)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			So(report.Rerouted, ShouldHaveLength, 1)
+			So(report.Rerouted[0].Pattern, ShouldEqual, patterns[1])
+			So(report.Rerouted[0].Reason, ShouldNotBeNil)
+			So(report.Rerouted[0].Engine, ShouldEqual, "regexp")
+
+			Convey("Then the matches are merged in the order of the end of match", func() {
+				So(db.(hyperscan.BlockScanner).Scan([]byte("aab foo"), nil, handler, nil), ShouldBeNil)
+				So(matches, ShouldResemble, []match{{2, 0, 3}, {1, 4, 7}})
+
+				So(db.(hyperscan.BlockDatabase).FindAllStringIndex("aab foo", -1), ShouldResemble, [][]int{{0, 3}, {4, 7}})
+			})
+
+			Convey("Then the scan options are applied to the fallback matches", func() {
+				v, err := hyperscan.WithScanOptions(db, hyperscan.OnlyIDs(2))
+				So(err, ShouldBeNil)
+
+				So(v.(hyperscan.BlockScanner).Scan([]byte("aab foo ab"), nil, handler, nil), ShouldBeNil)
+				So(matches, ShouldResemble, []match{{2, 0, 3}, {2, 8, 10}})
+			})
+
+			Convey("Then the scan could be terminated by the fallback matches", func() {
+				err := db.(hyperscan.BlockScanner).Scan([]byte("foo aab"), nil,
+					func(id uint, from, to uint64, flags uint, context interface{}) error {
+						return errors.New("stop")
+					}, nil)
+
+				So(err, ShouldEqual, hyperscan.ErrScanTerminated)
+			})
+		})
+
+		Convey("When build a stream database with the default fallback", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode}
+
+			db, _, err := b.BuildWithFallback(nil)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			s, err := db.(hyperscan.StreamScanner).Open(0, nil, handler, nil)
+			So(err, ShouldBeNil)
+
+			So(s.Scan([]byte("a")), ShouldBeNil)
+			So(s.Scan([]byte("ab f")), ShouldBeNil)
+			So(s.Scan([]byte("oo")), ShouldBeNil)
+			So(s.Close(), ShouldBeNil)
+
+			So(matches, ShouldResemble, []match{{2, 0, 3}, {1, 4, 7}})
+		})
+
+		Convey("When build a vectored database with the default fallback", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.VectoredMode}
+
+			db, _, err := b.BuildWithFallback(nil)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			So(db.(hyperscan.VectoredScanner).Scan([][]byte{[]byte("a"), []byte("ab foo")}, nil, handler, nil), ShouldBeNil)
+			So(matches, ShouldResemble, []match{{2, 0, 3}, {1, 4, 7}})
+		})
+	})
+
+	Convey("Given a pattern with backreference", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `(\w)\1`, Id: 2},
+			},
+		}
+
+		Convey("When build it with the regexp fallback", func() {
+			_, _, err := b.BuildWithFallback(nil)
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When build it with the prefilter fallback", func() {
+			db, report, err := b.BuildWithFallback(hyperscan.PrefilterFallback(
+				func(p *hyperscan.Pattern) (hyperscan.Capturer, error) { return doubled{}, nil }))
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			So(report.Rerouted, ShouldHaveLength, 1)
+			So(report.Rerouted[0].Engine, ShouldEqual, "prefilter")
+
+			So(db.(hyperscan.BlockDatabase).FindAllStringIndex("foo abba", -1), ShouldResemble,
+				[][]int{{1, 3}, {0, 3}, {5, 7}})
+		})
+	})
+
+	Convey("Given a pattern set with several patterns rejected by Hyperscan", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `(?U)c+d`, Flags: hyperscan.SomLeftMost, Id: 1},
+			{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 2},
+			{Expression: `(?U)a{1,3}b`, Flags: hyperscan.SomLeftMost, Id: 3},
+			{Expression: `bar`, Flags: hyperscan.SomLeftMost, Id: 4},
+		}
+
+		Convey("When build a block database with the default fallback", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns}
+
+			db, report, err := b.BuildWithFallback(nil)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			Convey("Then every rejected pattern is rerouted in order", func() {
+				So(report.Rerouted, ShouldHaveLength, 2)
+				So(report.Rerouted[0].Pattern, ShouldEqual, patterns[0])
+				So(report.Rerouted[1].Pattern, ShouldEqual, patterns[2])
+
+				So(db.(hyperscan.BlockDatabase).FindAllStringIndex("cd foo aab bar", -1), ShouldResemble,
+					[][]int{{0, 2}, {3, 6}, {7, 10}, {11, 14}})
+			})
+		})
+
+		Convey("When scan a long stream", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode}
+
+			db, _, err := b.BuildWithFallback(nil)
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			var ends []uint64
+
+			s, err := db.(hyperscan.StreamScanner).Open(0, nil,
+				func(id uint, from, to uint64, flags uint, context interface{}) error {
+					if id == 3 {
+						ends = append(ends, to)
+					}
+
+					return nil
+				}, nil)
+			So(err, ShouldBeNil)
+
+			filler := make([]byte, 1000)
+
+			for i := range filler {
+				filler[i] = 'x'
+			}
+
+			for i := 0; i < 100; i++ {
+				So(s.Scan(filler), ShouldBeNil)
+			}
+
+			So(s.Scan([]byte("a")), ShouldBeNil)
+			So(s.Scan([]byte("ab")), ShouldBeNil)
+			So(s.Scan([]byte("x")), ShouldBeNil)
+			So(s.Close(), ShouldBeNil)
+
+			Convey("Then the matches across the writes are reported once", func() {
+				So(ends, ShouldResemble, []uint64{100003})
+			})
+		})
+	})
+
+	Convey("Given a stream with an anchored pattern rejected by Hyperscan", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `(?U)^f.*q`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 2},
+			},
+			Mode: hyperscan.StreamMode,
+		}
+
+		db, _, err := b.BuildWithFallback(nil)
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		var ids []uint
+
+		s, err := db.(hyperscan.StreamScanner).Open(0, nil,
+			func(id uint, from, to uint64, flags uint, context interface{}) error {
+				ids = append(ids, id)
+
+				return nil
+			}, nil)
+		So(err, ShouldBeNil)
+
+		Convey("When the start of pattern appears at the start of the trimmed history", func() {
+			data := make([]byte, 1<<16)
+			data[0] = 'f'
+
+			for i := 1; i < len(data); i++ {
+				data[i] = 'x'
+			}
+
+			So(s.Scan([]byte("xxxxx")), ShouldBeNil)
+			So(s.Scan(data), ShouldBeNil)
+			So(s.Scan([]byte("q")), ShouldBeNil)
+			So(s.Close(), ShouldBeNil)
+
+			Convey("Then the anchor doesn't match in the middle of stream", func() {
+				So(ids, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a pattern set without any pattern accepted by Hyperscan", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{{Expression: `(?U)a+b`, Id: 1}},
+		}
+
+		_, report, err := b.BuildWithFallback(nil)
+
+		So(err, ShouldNotBeNil)
+		So(report.Rerouted, ShouldHaveLength, 1)
+	})
+}
@@ -0,0 +1,313 @@
+package hyperscan
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// ScanOption is a scan-time option which filters the match events reported by a database,
+// so the same compiled database can serve callers with different reporting needs without recompiling.
+type ScanOption func(opts *scanOptions)
+
+type scanOptions struct {
+	reportOnce bool
+	include    bitmap
+	exclude    bitmap
+	stopAll    bool
+	semantics  Semantics
+	verifier   Verifier
+	stats      *PrefilterStats
+}
+
+// ReportOnce reports each pattern ID at most once per scan (or per stream),
+// it works like the SingleMatch compile flag but could be selected for each scan.
+func ReportOnce() ScanOption {
+	return func(opts *scanOptions) {
+		opts.reportOnce = true
+	}
+}
+
+// OnlyIDs restricts the match reporting to the patterns with the given IDs.
+func OnlyIDs(ids ...uint) ScanOption {
+	return func(opts *scanOptions) {
+		for _, id := range ids {
+			opts.include.set(id)
+		}
+	}
+}
+
+// ExcludeIDs suppresses the match reporting for the patterns with the given IDs.
+func ExcludeIDs(ids ...uint) ScanOption {
+	return func(opts *scanOptions) {
+		for _, id := range ids {
+			opts.exclude.set(id)
+		}
+	}
+}
+
+// StopWhenAllMatched terminates the scan once every requested pattern ID has been reported.
+//
+// The requested IDs are the ones given to OnlyIDs, or all the pattern IDs of a database built by DatabaseBuilder,
+// without the ones given to ExcludeIDs. The scan is never terminated early if the requested IDs are unknown.
+func StopWhenAllMatched() ScanOption {
+	return func(opts *scanOptions) {
+		opts.stopAll = true
+	}
+}
+
+// WithScanOptions returns a view of the block, streaming or vectored database which applies the scan options
+// to all the scans, streams and `Find*` helpers performed through it.
+//
+// The view shares the compiled database with db, closing either of them will free it.
+func WithScanOptions(db Database, opts ...ScanOption) (Database, error) {
+	switch d := db.(type) {
+	case *blockDatabase:
+		bs := &blockScanner{d.baseDatabase, d.opts.with(opts)}
+
+		return &blockDatabase{newBlockMatcher(bs)}, nil
+
+	case *streamDatabase:
+		ss := &streamScanner{d.baseDatabase, d.opts.with(opts)}
+
+		return &streamDatabase{newStreamMatcher(ss)}, nil
+
+	case *vectoredDatabase:
+		vs := &vectoredScanner{d.baseDatabase, d.opts.with(opts)}
+
+		return &vectoredDatabase{newVectoredMatcher(vs)}, nil
+
+	default:
+		return nil, fmt.Errorf("database %T, %w", db, ErrInvalid)
+	}
+}
+
+func (o *scanOptions) with(opts []ScanOption) *scanOptions {
+	var merged scanOptions
+
+	if o != nil {
+		merged = *o
+		merged.include = o.include.clone()
+		merged.exclude = o.exclude.clone()
+	}
+
+	for _, opt := range opts {
+		opt(&merged)
+	}
+
+	return &merged
+}
+
+// errAllMatched terminates the scan once every requested pattern ID has been reported.
+var errAllMatched = errors.New("all requested patterns matched")
+
+// matchFilter applies the scan options to the match events of a single scan or stream.
+type matchFilter struct {
+	*scanOptions
+	seen    bitmap
+	target  bitmap
+	pending int
+	done    bool
+}
+
+// newFilter returns a match filter for a new scan, or nil if the options don't filter the match events.
+func (o *scanOptions) newFilter(ids bitmap) *matchFilter {
+	if o == nil || !(o.reportOnce || o.stopAll || !o.include.empty() || !o.exclude.empty()) {
+		return nil
+	}
+
+	return newMatchFilter(o, ids)
+}
+
+func newMatchFilter(opts *scanOptions, ids bitmap) *matchFilter {
+	f := &matchFilter{scanOptions: opts}
+
+	if !opts.include.empty() {
+		f.target = opts.include.clone()
+	} else {
+		f.target = ids.clone()
+	}
+
+	f.target.clear(opts.exclude)
+	f.pending = f.target.count()
+	f.seen = bitmap{words: make([]uint64, len(f.target.words))}
+
+	if len(ids.words) > len(f.seen.words) {
+		f.seen.words = make([]uint64, len(ids.words))
+	}
+
+	return f
+}
+
+func (f *matchFilter) allowed(id uint) bool {
+	if !f.include.empty() && !f.include.has(id) {
+		return false
+	}
+
+	return !f.exclude.has(id)
+}
+
+func (f *matchFilter) handler(next MatchHandler) MatchHandler {
+	return func(id uint, from, to uint64, flags uint, context interface{}) error {
+		if f.done {
+			return errAllMatched
+		}
+
+		if !f.allowed(id) {
+			return nil
+		}
+
+		if f.seen.has(id) {
+			if f.reportOnce {
+				return nil
+			}
+		} else {
+			f.seen.set(id)
+
+			if f.target.has(id) {
+				f.pending--
+			}
+		}
+
+		if next != nil {
+			if err := next(id, from, to, flags, context); err != nil {
+				return err
+			}
+		}
+
+		if f.stopAll && !f.target.empty() && f.pending == 0 {
+			f.done = true
+
+			return errAllMatched
+		}
+
+		return nil
+	}
+}
+
+// result hides the termination of a scan which was stopped by the filter itself.
+func (f *matchFilter) result(err error) error {
+	if f.done && errors.Is(err, ErrScanTerminated) {
+		return nil
+	}
+
+	return err
+}
+
+func (f *matchFilter) reset() {
+	f.seen.reset()
+
+	f.pending = f.target.count()
+	f.done = false
+}
+
+func (f *matchFilter) clone() *matchFilter {
+	cloned := *f
+	cloned.seen = f.seen.clone()
+
+	return &cloned
+}
+
+const wordBits = 64
+
+// denseIDs bounds the IDs kept in the words of a bitmap, the larger IDs are sparse and kept in a map,
+// so a large ID doesn't allocate the words of all the smaller ones.
+const denseIDs = 1 << 16
+
+// bitmap is a set of pattern IDs.
+type bitmap struct {
+	words  []uint64
+	sparse map[uint]struct{}
+}
+
+func (b bitmap) has(id uint) bool {
+	if id >= denseIDs {
+		_, ok := b.sparse[id]
+
+		return ok
+	}
+
+	w := id / wordBits
+
+	return w < uint(len(b.words)) && b.words[w]&(1<<(id%wordBits)) != 0
+}
+
+func (b *bitmap) set(id uint) {
+	if id >= denseIDs {
+		if b.sparse == nil {
+			b.sparse = make(map[uint]struct{})
+		}
+
+		b.sparse[id] = struct{}{}
+
+		return
+	}
+
+	w := id / wordBits
+
+	if w >= uint(len(b.words)) {
+		grown := make([]uint64, w+1)
+		copy(grown, b.words)
+		b.words = grown
+	}
+
+	b.words[w] |= 1 << (id % wordBits)
+}
+
+func (b bitmap) clear(o bitmap) {
+	for i := 0; i < len(b.words) && i < len(o.words); i++ {
+		b.words[i] &^= o.words[i]
+	}
+
+	for id := range o.sparse {
+		delete(b.sparse, id)
+	}
+}
+
+// empty returns whether no ID was ever set.
+func (b bitmap) empty() bool {
+	return len(b.words) == 0 && len(b.sparse) == 0
+}
+
+func (b bitmap) count() (n int) {
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+
+	return n + len(b.sparse)
+}
+
+func (b *bitmap) reset() {
+	for i := range b.words {
+		b.words[i] = 0
+	}
+
+	for id := range b.sparse {
+		delete(b.sparse, id)
+	}
+}
+
+func (b bitmap) clone() bitmap {
+	cloned := bitmap{words: append([]uint64(nil), b.words...)}
+
+	if b.sparse != nil {
+		cloned.sparse = make(map[uint]struct{}, len(b.sparse))
+
+		for id := range b.sparse {
+			cloned.sparse[id] = struct{}{}
+		}
+	}
+
+	return cloned
+}
+
+// ids returns the pattern IDs of the patterns.
+func (p Patterns) ids() (ids bitmap) {
+	for _, pat := range p {
+		if pat.Id >= 0 {
+			ids.set(uint(pat.Id))
+		}
+	}
+
+	return
+}
@@ -0,0 +1,193 @@
+package hyperscan_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestScanOptions(t *testing.T) {
+	Convey("Given a block database with a few patterns", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: "foo", Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: "bar", Flags: hyperscan.SomLeftMost, Id: 2},
+				{Expression: "baz", Flags: hyperscan.SomLeftMost, Id: 3},
+			},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+		So(db, ShouldNotBeNil)
+
+		defer db.Close()
+
+		data := []byte("foo bar foo baz bar")
+
+		scan := func(db hyperscan.Database) (ids []uint, err error) {
+			err = db.(hyperscan.BlockScanner).Scan(data, nil,
+				func(id uint, from, to uint64, flags uint, context interface{}) error {
+					ids = append(ids, id)
+
+					return nil
+				}, nil)
+
+			return
+		}
+
+		Convey("When scan without options", func() {
+			ids, err := scan(db)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{1, 2, 1, 3, 2})
+		})
+
+		Convey("When report each ID at most once", func() {
+			v, err := hyperscan.WithScanOptions(db, hyperscan.ReportOnce())
+			So(err, ShouldBeNil)
+
+			ids, err := scan(v)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{1, 2, 3})
+
+			Convey("Then the original database is not affected", func() {
+				ids, err := scan(db)
+
+				So(err, ShouldBeNil)
+				So(ids, ShouldResemble, []uint{1, 2, 1, 3, 2})
+			})
+		})
+
+		Convey("When restrict to an allowlist", func() {
+			v, err := hyperscan.WithScanOptions(db, hyperscan.OnlyIDs(2, 3))
+			So(err, ShouldBeNil)
+
+			ids, err := scan(v)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{2, 3, 2})
+		})
+
+		Convey("When restrict to an allowlist with a large ID", func() {
+			var before, after runtime.MemStats
+
+			runtime.ReadMemStats(&before)
+
+			v, err := hyperscan.WithScanOptions(db, hyperscan.OnlyIDs(1<<32-1, 2), hyperscan.StopWhenAllMatched())
+			So(err, ShouldBeNil)
+
+			ids, err := scan(v)
+
+			runtime.ReadMemStats(&after)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{2, 2})
+			So(after.TotalAlloc-before.TotalAlloc, ShouldBeLessThan, 1<<20)
+		})
+
+		Convey("When restrict with a denylist", func() {
+			v, err := hyperscan.WithScanOptions(db, hyperscan.ExcludeIDs(1))
+			So(err, ShouldBeNil)
+
+			ids, err := scan(v)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{2, 3, 2})
+		})
+
+		Convey("When stop once all the requested IDs matched", func() {
+			v, err := hyperscan.WithScanOptions(db, hyperscan.OnlyIDs(1, 2), hyperscan.StopWhenAllMatched())
+			So(err, ShouldBeNil)
+
+			ids, err := scan(v)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{1, 2})
+		})
+
+		Convey("When stop once all the IDs of database matched", func() {
+			v, err := hyperscan.WithScanOptions(db, hyperscan.ReportOnce(), hyperscan.StopWhenAllMatched())
+			So(err, ShouldBeNil)
+
+			ids, err := scan(v)
+
+			So(err, ShouldBeNil)
+			So(ids, ShouldResemble, []uint{1, 2, 3})
+		})
+
+		Convey("When use the `Find*` helpers", func() {
+			v, err := hyperscan.WithScanOptions(db, hyperscan.ExcludeIDs(1, 3))
+			So(err, ShouldBeNil)
+
+			bdb, ok := v.(hyperscan.BlockDatabase)
+			So(ok, ShouldBeTrue)
+
+			So(bdb.FindAllIndex(data, -1), ShouldResemble, [][]int{{4, 7}, {16, 19}})
+		})
+	})
+
+	Convey("Given a streaming database with a few patterns", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: "foo", Id: 1},
+				{Expression: "bar", Id: 2},
+			},
+			Mode: hyperscan.StreamMode,
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		v, err := hyperscan.WithScanOptions(db, hyperscan.ReportOnce())
+		So(err, ShouldBeNil)
+
+		sdb, ok := v.(hyperscan.StreamDatabase)
+		So(ok, ShouldBeTrue)
+
+		var ids []uint
+
+		handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+			ids = append(ids, id)
+
+			return nil
+		}
+
+		Convey("When scan a stream across blocks", func() {
+			s, err := sdb.Open(0, nil, handler, nil)
+			So(err, ShouldBeNil)
+
+			So(s.Scan([]byte("fo")), ShouldBeNil)
+			So(s.Scan([]byte("o bar f")), ShouldBeNil)
+			So(s.Scan([]byte("oo bar")), ShouldBeNil)
+
+			So(ids, ShouldResemble, []uint{1, 2})
+
+			Convey("Then the filter is reset with the stream", func() {
+				So(s.Reset(), ShouldBeNil)
+				So(s.Scan([]byte("foo")), ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+
+				So(ids, ShouldResemble, []uint{1, 2, 1})
+			})
+		})
+
+		Convey("When scan a reader", func() {
+			So(sdb.Scan(strings.NewReader("bar foo bar"), nil, handler, nil), ShouldBeNil)
+			So(ids, ShouldResemble, []uint{2, 1})
+		})
+	})
+
+	Convey("Given an unsupported database", t, func() {
+		_, err := hyperscan.WithScanOptions(nil, hyperscan.ReportOnce())
+
+		So(err, ShouldNotBeNil)
+	})
+}
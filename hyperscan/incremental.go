@@ -0,0 +1,164 @@
+package hyperscan
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sync"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// IncrementalBuilder builds sharded databases, and recompiles only the shards whose patterns changed since the last build.
+//
+// Each shard is identified by a content hash of its patterns, which covers the expression, flags, ID
+// and extended parameters of each pattern, the mode and the target platform.
+// The shards compiled by the last build are kept serialized, and the next build reuses the shards with the same hash,
+// so the database matches exactly as the one fully rebuilt by DatabaseBuilder with the same partition.
+//
+// The patterns are assigned to the shards by PartitionByID if Partition is nil,
+// so adding, removing or changing a pattern only recompiles its own shard.
+type IncrementalBuilder struct {
+	DatabaseBuilder
+
+	lock  sync.Mutex
+	cache map[contentHash][]byte
+	stats BuildStats
+}
+
+// BuildStats are the statistics of the last build of IncrementalBuilder.
+type BuildStats struct {
+	Shards   int // The number of shards of the database.
+	Compiled int // The number of recompiled shards.
+	Reused   int // The number of shards reused from the last build.
+}
+
+// Build a database base on the expressions and platform, reusing the unchanged shards of the last build.
+func (b *IncrementalBuilder) Build() (Database, error) {
+	if b.Patterns == nil {
+		return nil, ErrInvalid
+	}
+
+	builder := b.DatabaseBuilder
+	if builder.Partition == nil {
+		builder.Partition = PartitionByID
+	}
+
+	mode := builder.mode()
+	platform, _ := builder.Platform.(*hs.PlatformInfo)
+
+	shards, err := builder.partition()
+	if err != nil {
+		return nil, err
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	hashes := make([]contentHash, len(shards))
+	blobs := make([][]byte, len(shards))
+	stats := BuildStats{Shards: len(shards)}
+
+	for i, shard := range shards {
+		hashes[i] = hashPatterns(shard, mode, builder.Platform)
+
+		if blobs[i] = b.cache[hashes[i]]; blobs[i] != nil {
+			stats.Reused++
+		} else {
+			stats.Compiled++
+		}
+	}
+
+	dbs, err := builder.compileShards(shards, func(i int, shard Patterns) (hs.Database, error) {
+		if blobs[i] != nil {
+			return hs.DeserializeDatabase(blobs[i]) //nolint: wrapcheck
+		}
+
+		db, err := hs.CompileMulti(shard, mode, platform)
+		if err != nil {
+			return nil, err //nolint: wrapcheck
+		}
+
+		if blobs[i], err = hs.SerializeDatabase(db); err != nil {
+			_ = hs.FreeDatabase(db)
+
+			return nil, err //nolint: wrapcheck
+		}
+
+		return db, nil
+	})
+	if err != nil {
+		return nil, builder.compileError(err)
+	}
+
+	db, err := builder.newDatabase(mode, dbs)
+	if err != nil {
+		return nil, err
+	}
+
+	b.cache = make(map[contentHash][]byte, len(shards))
+
+	for i, h := range hashes {
+		b.cache[h] = blobs[i]
+	}
+
+	b.stats = stats
+
+	return db, nil
+}
+
+// Stats returns the statistics of the last build.
+func (b *IncrementalBuilder) Stats() BuildStats {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.stats
+}
+
+// Reset drops the shards of the last build, so the next build recompiles all the shards.
+func (b *IncrementalBuilder) Reset() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.cache = nil
+	b.stats = BuildStats{}
+}
+
+type contentHash [sha256.Size]byte
+
+// hashPatterns returns the content hash of the patterns compiled in the mode for the platform.
+func hashPatterns(patterns Patterns, mode ModeFlag, platform Platform) (sum contentHash) {
+	h := sha256.New()
+
+	hashValues(h, uint64(mode))
+
+	if platform != nil {
+		hashValues(h, true, int64(platform.Tune()), int64(platform.CpuFeatures()))
+	} else {
+		hashValues(h, false)
+	}
+
+	hashValues(h, uint64(len(patterns)))
+
+	for _, p := range patterns {
+		hashValues(h, uint64(len(p.Expression)))
+		_, _ = h.Write([]byte(p.Expression))
+		hashValues(h, uint64(p.Flags), int64(p.Id))
+
+		if p.ext != nil {
+			hashValues(h, true, *p.ext)
+		} else {
+			hashValues(h, false)
+		}
+	}
+
+	copy(sum[:], h.Sum(nil))
+
+	return sum
+}
+
+func hashValues(h hash.Hash, values ...interface{}) {
+	for _, v := range values {
+		_ = binary.Write(h, binary.LittleEndian, v)
+	}
+}
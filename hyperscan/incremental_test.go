@@ -0,0 +1,142 @@
+package hyperscan_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestIncrementalBuilder(t *testing.T) {
+	Convey("Given an incremental builder", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+			{Expression: `bar`, Flags: hyperscan.SomLeftMost, Id: 2},
+			{Expression: `ba[rz]`, Flags: hyperscan.SomLeftMost, Id: 3},
+			{Expression: `\d+`, Flags: hyperscan.SomLeftMost, Id: 4},
+			{Expression: `o\s`, Flags: hyperscan.SomLeftMost, Id: 5},
+			{Expression: `qu+x`, Flags: hyperscan.SomLeftMost, Id: 6},
+		}
+
+		data := "foo bar baz 123 quux"
+
+		b := &hyperscan.IncrementalBuilder{
+			DatabaseBuilder: hyperscan.DatabaseBuilder{Patterns: patterns, Shards: 4},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+
+		stats := b.Stats()
+		So(stats.Compiled, ShouldEqual, stats.Shards)
+		So(stats.Reused, ShouldEqual, 0)
+
+		// rebuild checks the incremental build matches as a full rebuild of the same partition.
+		rebuild := func() (hyperscan.BuildStats, [][]int) {
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			full := hyperscan.DatabaseBuilder{Patterns: b.Patterns, Shards: 4, Partition: hyperscan.PartitionByID}
+
+			expected, err := full.Build()
+			So(err, ShouldBeNil)
+
+			defer expected.Close()
+
+			So(db.(hyperscan.MultiDatabase).Shards(), ShouldEqual, expected.(hyperscan.MultiDatabase).Shards())
+
+			matches := db.(hyperscan.BlockDatabase).FindAllStringIndex(data, -1)
+			So(matches, ShouldResemble, expected.(hyperscan.BlockDatabase).FindAllStringIndex(data, -1))
+
+			return b.Stats(), matches
+		}
+
+		Convey("When nothing changed", func() {
+			stats, _ := rebuild()
+
+			Convey("Then all the shards are reused", func() {
+				So(stats.Compiled, ShouldEqual, 0)
+				So(stats.Reused, ShouldEqual, stats.Shards)
+			})
+		})
+
+		Convey("When a pattern is changed", func() {
+			b.Patterns = append(hyperscan.Patterns{}, patterns...)
+			b.Patterns[3] = &hyperscan.Pattern{Expression: `\d{2}`, Flags: hyperscan.SomLeftMost, Id: 4}
+
+			stats, matches := rebuild()
+
+			Convey("Then only its shard is recompiled", func() {
+				So(stats.Compiled, ShouldEqual, 1)
+				So(stats.Reused, ShouldEqual, stats.Shards-1)
+				So(matches, ShouldContain, []int{12, 14})
+			})
+		})
+
+		Convey("When a pattern is added", func() {
+			b.Patterns = append(append(hyperscan.Patterns{}, patterns...),
+				&hyperscan.Pattern{Expression: `z\s`, Flags: hyperscan.SomLeftMost, Id: 7})
+
+			stats, matches := rebuild()
+
+			Convey("Then only its shard is recompiled", func() {
+				So(stats.Compiled, ShouldEqual, 1)
+				So(matches, ShouldContain, []int{10, 12})
+			})
+		})
+
+		Convey("When a pattern is removed", func() {
+			b.Patterns = append(hyperscan.Patterns{}, patterns[1:]...)
+
+			stats, matches := rebuild()
+
+			Convey("Then only its shard is recompiled", func() {
+				So(stats.Compiled, ShouldBeLessThanOrEqualTo, 1)
+				So(matches, ShouldNotContain, []int{0, 3})
+			})
+		})
+
+		Convey("When the flags or the platform are changed", func() {
+			b.Patterns = append(hyperscan.Patterns{}, patterns...)
+			b.Patterns[0] = &hyperscan.Pattern{Expression: `foo`, Flags: hyperscan.SomLeftMost | hyperscan.Caseless, Id: 1}
+			b.Platform = hyperscan.PopulatePlatform()
+
+			stats, _ := rebuild()
+
+			Convey("Then all the shards are recompiled", func() {
+				So(stats.Compiled, ShouldEqual, stats.Shards)
+			})
+		})
+
+		Convey("When the builder is reset", func() {
+			b.Reset()
+
+			stats, _ := rebuild()
+
+			Convey("Then all the shards are recompiled", func() {
+				So(stats.Compiled, ShouldEqual, stats.Shards)
+			})
+		})
+
+		Convey("When a changed pattern is broken", func() {
+			b.Patterns = append(hyperscan.Patterns{}, patterns...)
+			b.Patterns[2] = &hyperscan.Pattern{Expression: `a(`, Id: 3}
+
+			_, err := b.Build()
+
+			Convey("Then the shards of the last build are kept", func() {
+				So(err, ShouldNotBeNil)
+
+				b.Patterns = patterns
+
+				stats, _ := rebuild()
+				So(stats.Compiled, ShouldEqual, 0)
+			})
+		})
+	})
+}
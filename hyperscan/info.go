@@ -0,0 +1,148 @@
+package hyperscan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LibraryVersion is the version number of Hyperscan.
+type LibraryVersion struct {
+	Major, Minor, Release int
+}
+
+func (v LibraryVersion) String() string { return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Release) }
+
+// ParseLibraryVersion parses a version number like `5.4.2`, which may be followed by the date of the build.
+func ParseLibraryVersion(s string) (v LibraryVersion, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return v, fmt.Errorf("version `%s`, %w", s, ErrInvalid)
+	}
+
+	parts := strings.Split(fields[0], ".")
+	if len(parts) != 3 { //nolint: gomnd
+		return v, fmt.Errorf("version `%s`, %w", s, ErrInvalid)
+	}
+
+	for i, n := range []*int{&v.Major, &v.Minor, &v.Release} {
+		if *n, err = strconv.Atoi(parts[i]); err != nil || *n < 0 {
+			return LibraryVersion{}, fmt.Errorf("version `%s`, %w", s, ErrInvalid)
+		}
+	}
+
+	return v, nil
+}
+
+// cpuFeatures are the names of the CPU features in the database info.
+var cpuFeatures = map[string]CpuFeature{
+	"AVX2":   AVX2,
+	"AVX512": AVX512,
+}
+
+// featureNames returns the names of the CPU features.
+func featureNames(features CpuFeature) string {
+	var names []string
+
+	for name, feature := range cpuFeatures {
+		if features&feature == feature {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return "no CPU feature"
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, " ")
+}
+
+// DatabaseInfo is the structured version and platform information of a database.
+type DatabaseInfo struct {
+	// The version of Hyperscan which compiled the database.
+	Version LibraryVersion
+
+	// The CPU features required by the database, AVX512 implies AVX2.
+	Features CpuFeature
+
+	// The scanning mode of the database.
+	Mode ModeFlag
+
+	// The tune family of the database, which Hyperscan doesn't record in the database info, so it is always Generic.
+	Tune TuneFlag
+}
+
+// DatabaseInfo parses the version and platform information.
+func (i DbInfo) DatabaseInfo() (*DatabaseInfo, error) {
+	version, features, mode, err := i.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DatabaseInfo{Tune: Generic}
+
+	if info.Version, err = ParseLibraryVersion(version); err != nil {
+		return nil, err
+	}
+
+	for _, name := range strings.Fields(features) {
+		feature, ok := cpuFeatures[strings.ToUpper(name)]
+		if !ok {
+			return nil, fmt.Errorf("database info `%s`, CPU feature %s, %w", i, name, ErrInvalid)
+		}
+
+		info.Features |= feature
+	}
+
+	if info.Features&AVX512 != 0 {
+		info.Features |= AVX2
+	}
+
+	if info.Mode, err = ParseModeFlag(mode); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// CheckCompatible checks that a database could be used with this version of Hyperscan on the platform,
+// which is the current host if nil.
+//
+// It returns ErrDatabaseVersionError if the database was compiled by another version of Hyperscan,
+// or ErrDatabasePlatformError if the database requires the CPU features the platform doesn't support.
+func (i *DatabaseInfo) CheckCompatible(platform Platform) error {
+	current, err := ParseLibraryVersion(Version())
+	if err != nil {
+		return err
+	}
+
+	if i.Version != current {
+		return fmt.Errorf("database compiled by Hyperscan %s, running Hyperscan %s, %w",
+			i.Version, current, ErrDatabaseVersionError)
+	}
+
+	if platform == nil {
+		platform = PopulatePlatform()
+	}
+
+	if missing := i.Features &^ platform.CpuFeatures(); missing != 0 {
+		return fmt.Errorf("database requires %s, platform supports %s, missing %s, %w",
+			featureNames(i.Features), featureNames(platform.CpuFeatures()), featureNames(missing), ErrDatabasePlatformError)
+	}
+
+	return nil
+}
+
+// CheckCompatible checks that a database of the info, given by Database.Info or SerializedDatabaseInfo,
+// could be used with this version of Hyperscan on the platform, which is the current host if nil.
+func CheckCompatible(info DbInfo, platform Platform) error {
+	i, err := info.DatabaseInfo()
+	if err != nil {
+		return err
+	}
+
+	return i.CheckCompatible(platform)
+}
@@ -0,0 +1,88 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+func TestDatabaseInfo(t *testing.T) {
+	Convey("Given the version of Hyperscan", t, func() {
+		current, err := hyperscan.ParseLibraryVersion(hyperscan.Version())
+		So(err, ShouldBeNil)
+		So(hyperscan.Version(), ShouldStartWith, current.String())
+
+		Convey("When parse a database info", func() {
+			info, err := hyperscan.DbInfo(fmt.Sprintf("Version: %s Features: AVX512 Mode: STREAM", current)).DatabaseInfo()
+
+			So(err, ShouldBeNil)
+			So(info, ShouldResemble, &hyperscan.DatabaseInfo{
+				Version:  current,
+				Features: hyperscan.AVX2 | hyperscan.AVX512,
+				Mode:     hyperscan.StreamMode,
+				Tune:     hyperscan.Generic,
+			})
+		})
+
+		Convey("When parse a database info without CPU feature", func() {
+			info, err := hyperscan.DbInfo("Version: 5.2.1 Features:  Mode: BLOCK").DatabaseInfo()
+
+			So(err, ShouldBeNil)
+			So(info.Version, ShouldResemble, hyperscan.LibraryVersion{Major: 5, Minor: 2, Release: 1})
+			So(info.Features, ShouldEqual, 0)
+			So(info.Mode, ShouldEqual, hyperscan.BlockMode)
+		})
+
+		Convey("When parse a broken database info", func() {
+			_, err := hyperscan.DbInfo("Version: 5.2.1 Features: SSE9 Mode: BLOCK").DatabaseInfo()
+			So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+
+			_, err = hyperscan.DbInfo("garbage").DatabaseInfo()
+			So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+		})
+
+		Convey("When check the database compiled by another version", func() {
+			other := hyperscan.LibraryVersion{Major: current.Major, Minor: current.Minor, Release: current.Release + 1}
+			err := hyperscan.CheckCompatible(hyperscan.DbInfo(fmt.Sprintf("Version: %s Features:  Mode: BLOCK", other)), nil)
+
+			So(errors.Is(err, hyperscan.ErrDatabaseVersionError), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, other.String())
+		})
+
+		Convey("When check the database requiring a missing CPU feature", func() {
+			info := hyperscan.DbInfo(fmt.Sprintf("Version: %s Features: AVX512 Mode: BLOCK", current))
+			err := hyperscan.CheckCompatible(info, hyperscan.NewPlatform(hyperscan.Haswell, hyperscan.AVX2))
+
+			So(errors.Is(err, hyperscan.ErrDatabasePlatformError), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "missing AVX512")
+
+			So(hyperscan.CheckCompatible(info, hyperscan.NewPlatform(hyperscan.SkylakeServer, hyperscan.AVX2|hyperscan.AVX512)),
+				ShouldBeNil)
+		})
+
+		Convey("When check a database compiled on the host", func() {
+			db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo`, 0))
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			info, err := db.Info()
+			So(err, ShouldBeNil)
+
+			data, err := db.Marshal()
+			So(err, ShouldBeNil)
+
+			serialized, err := hyperscan.SerializedDatabaseInfo(data)
+			So(err, ShouldBeNil)
+
+			Convey("Then it is compatible with the host", func() {
+				So(hyperscan.CheckCompatible(info, hyperscan.PopulatePlatform()), ShouldBeNil)
+				So(hyperscan.CheckCompatible(serialized, nil), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package hyperscan
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/flier/gohs/internal/guard"
+)
+
+// LeakCheckEnv is the environment variable which enables the leak detector if set to a non-empty value.
+//
+// The leak detector is also enabled if the package is built with the `hyperscan_leakcheck` build tag.
+const LeakCheckEnv = "GOHS_LEAKCHECK"
+
+// leakTracker tracks the live databases, scratch spaces and streams with the stack traces where they were created.
+type leakTracker struct {
+	enabled int32
+
+	lock sync.Mutex
+	seq  uint64
+	live map[*leakRecord]struct{}
+}
+
+var leaks = newLeakTracker(leakCheckTag || os.Getenv(LeakCheckEnv) != "")
+
+func newLeakTracker(enabled bool) *leakTracker {
+	t := &leakTracker{live: make(map[*leakRecord]struct{})}
+
+	t.set(enabled)
+
+	return t
+}
+
+func (t *leakTracker) set(enabled bool) bool {
+	var v int32
+
+	if enabled {
+		v = 1
+	}
+
+	return atomic.SwapInt32(&t.enabled, v) != 0
+}
+
+func (t *leakTracker) isEnabled() bool { return atomic.LoadInt32(&t.enabled) != 0 }
+
+// SetLeakCheck enables or disables the leak detector, and returns whether it was enabled.
+//
+// Only the objects created while the leak detector is enabled are tracked.
+func SetLeakCheck(enabled bool) bool { return leaks.set(enabled) }
+
+// LeakCheckEnabled returns whether the leak detector is enabled.
+func LeakCheckEnabled() bool { return leaks.isEnabled() }
+
+// LiveObject is a database, scratch space or stream that was created but not closed yet.
+type LiveObject struct {
+	Kind  string // The kind of the object, "database", "scratch" or "stream".
+	Stack string // The stack trace where the object was created.
+}
+
+func (o LiveObject) String() string { return fmt.Sprintf("%s created at\n%s", o.Kind, o.Stack) }
+
+// LiveObjects returns the tracked objects which were not closed yet, in the order of creation.
+func LiveObjects() []LiveObject {
+	leaks.lock.Lock()
+
+	records := make([]*leakRecord, 0, len(leaks.live))
+
+	for r := range leaks.live {
+		records = append(records, r)
+	}
+
+	leaks.lock.Unlock()
+
+	sort.Slice(records, func(i, j int) bool { return records[i].seq < records[j].seq })
+
+	objects := make([]LiveObject, len(records))
+
+	for i, r := range records {
+		objects[i] = LiveObject{r.kind, guard.FormatStack(r.created)}
+	}
+
+	return objects
+}
+
+// TestingT is the subset of testing.TB used by VerifyNone.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// VerifyNone reports an error for each tracked object which was not closed yet.
+//
+// It checks nothing unless the leak detector is enabled,
+// and should not be used while the other tests running in parallel hold their objects.
+func VerifyNone(t TestingT) {
+	t.Helper()
+
+	for _, o := range LiveObjects() {
+		t.Errorf("leaked %s", o)
+	}
+}
+
+// leakRecord is the record of a tracked object, which is nil if the object isn't tracked.
+type leakRecord struct {
+	kind    string
+	seq     uint64
+	created []uintptr
+	closed  []uintptr
+}
+
+// trackObject starts to track a new object if the leak detector is enabled.
+func trackObject(kind string) *leakRecord {
+	if !leaks.isEnabled() {
+		return nil
+	}
+
+	r := &leakRecord{kind: kind, created: guard.Callers()}
+
+	leaks.lock.Lock()
+	defer leaks.lock.Unlock()
+
+	leaks.seq++
+	r.seq = leaks.seq
+	leaks.live[r] = struct{}{}
+
+	return r
+}
+
+// use returns an error if the object was closed.
+func (r *leakRecord) use(op string) error {
+	if r == nil {
+		return nil
+	}
+
+	leaks.lock.Lock()
+	closed := r.closed
+	leaks.lock.Unlock()
+
+	if closed == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%s closed %s, closed at\n%screated at\n%s%w", op, r.kind, guard.FormatStack(closed),
+		guard.FormatStack(r.created), ErrInvalid)
+}
+
+// release marks the object as closed, or returns an error if it was closed already.
+func (r *leakRecord) release(op string) error {
+	if r == nil {
+		return nil
+	}
+
+	pcs := guard.Callers()
+
+	leaks.lock.Lock()
+	defer leaks.lock.Unlock()
+
+	if r.closed != nil {
+		return fmt.Errorf("%s %s already closed at\n%screated at\n%s%w", op, r.kind, guard.FormatStack(r.closed),
+			guard.FormatStack(r.created), ErrInvalid)
+	}
+
+	r.closed = pcs
+	delete(leaks.live, r)
+
+	return nil
+}
+
+// leakOf returns the record of the database.
+func leakOf(db Database) *leakRecord {
+	if d, ok := db.(interface{ base() *baseDatabase }); ok {
+		return d.base().leak
+	}
+
+	return nil
+}
+
+// checkLive returns an error if the database or the scratch space was closed.
+func checkLive(op string, db *leakRecord, s *Scratch) error {
+	if err := db.use(op); err != nil {
+		return err
+	}
+
+	if s != nil {
+		return s.leak.use(op)
+	}
+
+	return nil
+}
@@ -0,0 +1,5 @@
+//go:build !hyperscan_leakcheck
+
+package hyperscan
+
+const leakCheckTag = false
@@ -0,0 +1,144 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+//nolint:funlen
+func TestLeakCheck(t *testing.T) {
+	Convey("Given the leak detector", t, func() {
+		enabled := hyperscan.SetLeakCheck(true)
+
+		Reset(func() {
+			hyperscan.SetLeakCheck(enabled)
+		})
+
+		So(hyperscan.LeakCheckEnabled(), ShouldBeTrue)
+
+		live := len(hyperscan.LiveObjects())
+
+		db, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+		So(err, ShouldBeNil)
+
+		s, err := hyperscan.NewScratch(db)
+		So(err, ShouldBeNil)
+
+		st, err := db.Open(0, s, nil, nil)
+		So(err, ShouldBeNil)
+
+		Convey("Then the live objects are tracked with their stacks", func() {
+			objects := hyperscan.LiveObjects()[live:]
+
+			So(objects, ShouldHaveLength, 3)
+			So(objects[0].Kind, ShouldEqual, "database")
+			So(objects[1].Kind, ShouldEqual, "scratch")
+			So(objects[2].Kind, ShouldEqual, "stream")
+			So(objects[2].Stack, ShouldContainSubstring, "TestLeakCheck")
+
+			Convey("When they are leaked", func() {
+				r := &recorder{}
+				hyperscan.VerifyNone(r)
+
+				Convey("Then VerifyNone reports them", func() {
+					So(len(r.errors), ShouldBeGreaterThanOrEqualTo, 3)
+					So(strings.Join(r.errors, "\n"), ShouldContainSubstring, "leaked stream created at")
+				})
+			})
+
+			So(st.Close(), ShouldBeNil)
+			So(s.Free(), ShouldBeNil)
+			So(db.Close(), ShouldBeNil)
+		})
+
+		Convey("When they are closed", func() {
+			So(st.Close(), ShouldBeNil)
+			So(s.Free(), ShouldBeNil)
+			So(db.Close(), ShouldBeNil)
+
+			Convey("Then they are not live anymore", func() {
+				So(hyperscan.LiveObjects(), ShouldHaveLength, live)
+			})
+
+			Convey("Then the double free is detected", func() {
+				err := s.Free()
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "free scratch already closed at")
+
+				err = db.Close()
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "close database already closed at")
+
+				err = st.Close()
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "close stream already closed at")
+			})
+
+			Convey("Then the use after close is detected", func() {
+				err := st.Scan([]byte("foo1"))
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "scan closed stream")
+
+				_, err = db.Open(0, nil, nil, nil)
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "open stream of closed database")
+
+				_, err = s.Size()
+				So(err.Error(), ShouldContainSubstring, "size of closed scratch")
+
+				_, err = db.Info()
+				So(err.Error(), ShouldContainSubstring, "info of closed database")
+			})
+		})
+
+		Convey("When the database is closed before the stream", func() {
+			So(db.Close(), ShouldBeNil)
+
+			Convey("Then scanning the stream is detected", func() {
+				err := st.Scan([]byte("foo1"))
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "scan stream of closed database")
+				So(st.Close(), ShouldNotBeNil)
+			})
+
+			So(s.Free(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a block database scanned with a freed scratch", t, func() {
+		enabled := hyperscan.SetLeakCheck(true)
+
+		Reset(func() {
+			hyperscan.SetLeakCheck(enabled)
+		})
+
+		db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		s, err := hyperscan.NewScratch(db)
+		So(err, ShouldBeNil)
+		So(s.Free(), ShouldBeNil)
+
+		err = db.Scan([]byte("foo1"), s, nil, nil)
+		So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+		So(err.Error(), ShouldContainSubstring, "scan closed scratch")
+	})
+}
@@ -0,0 +1,454 @@
+package hyperscan
+
+import (
+	"errors"
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// Severity is the severity of a lint finding.
+type Severity int
+
+const (
+	// SeverityInfo reports a pattern which could be simplified.
+	SeverityInfo Severity = iota
+	// SeverityWarning reports a pattern which compiles but may not behave as expected.
+	SeverityWarning
+	// SeverityError reports a pattern which fails to compile.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// LintCheck is the name of the check which reported a finding.
+type LintCheck string
+
+const (
+	LintInvalid        LintCheck = "invalid"         // The pattern fails to compile.
+	LintEmptyMatch     LintCheck = "empty-match"     // The pattern matches the empty data.
+	LintEndOfData      LintCheck = "end-of-data"     // The pattern matches at the end of data.
+	LintUnboundedWidth LintCheck = "unbounded-width" // The pattern has no maximum width in streaming mode.
+	LintUnordered      LintCheck = "unordered"       // The pattern returns the matches out of order.
+	LintRedundantFlag  LintCheck = "redundant-flag"  // A flag has no effect on the pattern.
+	LintDuplicate      LintCheck = "duplicate"       // The pattern is the same as another one.
+	LintSubsumed       LintCheck = "subsumed"        // Every match of the pattern is a match of another one.
+	LintDuplicateID    LintCheck = "duplicate-id"    // The ID of the pattern is used by another one.
+	LintRiskyConstruct LintCheck = "risky-construct" // The pattern uses a construct which is slow or large to compile.
+)
+
+// LintFinding is a problem found in a pattern.
+type LintFinding struct {
+	*Pattern
+
+	// The index of the pattern in the pattern set.
+	Index int
+
+	// The check which reported the finding.
+	Check LintCheck
+
+	// The severity of the finding.
+	Severity Severity
+
+	// The description of the problem.
+	Message string
+
+	// The suggested fix.
+	Fix string
+}
+
+func (f *LintFinding) String() string {
+	msg := fmt.Sprintf("%s: pattern %d `%s` at index %d, %s [%s]",
+		f.Severity, f.Id, f.Expression, f.Index, f.Message, f.Check)
+
+	if f.Fix != "" {
+		msg += "; " + f.Fix
+	}
+
+	if f.Pos.IsValid() {
+		msg = f.Pos.String() + ": " + msg
+	}
+
+	return msg
+}
+
+// Lint checks the patterns for the block mode, and returns the findings ordered by the index of the pattern.
+func Lint(patterns Patterns) []*LintFinding {
+	b := DatabaseBuilder{Patterns: patterns}
+
+	return b.Lint()
+}
+
+// Lint checks the patterns with the mode and platform of the builder,
+// and returns the findings ordered by the index of the pattern.
+//
+// Each pattern is analyzed with its ExprInfo, compiled individually and parsed as a regular expression;
+// the checks of the regular expression are approximate and skipped if the regexp package fails to parse it.
+// The pattern set is checked for the duplicate or subsumed patterns and the duplicate IDs.
+func (b *DatabaseBuilder) Lint() []*LintFinding {
+	mode := b.mode()
+	platform, _ := b.Platform.(*hs.PlatformInfo)
+
+	errs := b.validateEach(func(patterns Patterns) error {
+		db, err := hs.CompileMulti(patterns, mode, platform)
+		if err != nil {
+			return err //nolint: wrapcheck
+		}
+
+		return hs.FreeDatabase(db) //nolint: wrapcheck
+	})
+
+	var findings []*LintFinding
+
+	for i, p := range b.Patterns {
+		l := &linter{p, i, nil}
+
+		l.info(mode, errs[i])
+		l.syntax()
+
+		findings = append(findings, l.findings...)
+	}
+
+	findings = append(findings, lintSet(b.Patterns)...)
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Index < findings[j].Index })
+
+	return findings
+}
+
+// linter collects the findings of a pattern.
+type linter struct {
+	*Pattern
+	index    int
+	findings []*LintFinding
+}
+
+func (l *linter) report(check LintCheck, severity Severity, fix, format string, args ...interface{}) {
+	l.findings = append(l.findings, &LintFinding{l.Pattern, l.index, check, severity, fmt.Sprintf(format, args...), fix})
+}
+
+// info checks the ExprInfo and the compile error of the pattern.
+func (l *linter) info(mode ModeFlag, compileErr *PatternError) {
+	info, err := hs.ExpressionInfo(l.Expression, l.Flags)
+	if err != nil {
+		if _, withEmpty := hs.ExpressionInfo(l.Expression, l.Flags|AllowEmpty); withEmpty == nil {
+			l.report(LintEmptyMatch, SeverityError,
+				fmt.Sprintf("require at least one byte, e.g. `+` instead of `*`, or set the `%s` flag", AllowEmpty),
+				"matches the empty data, which is only valid with AllowEmpty")
+		} else {
+			l.report(LintInvalid, SeverityError, "", "%s", err)
+		}
+
+		return
+	}
+
+	if compileErr != nil {
+		l.report(LintInvalid, SeverityError, "", "%s", compileErr.Err)
+	}
+
+	if info.MinWidth == 0 {
+		l.report(LintEmptyMatch, SeverityWarning, "require at least one byte, or set MinLength",
+			"matches the empty data, which is reported at every offset")
+	}
+
+	if info.OnlyAtEndOfData {
+		l.report(LintEndOfData, SeverityWarning, "remove the end anchor if the matches in the middle are expected",
+			"can only match at the end of data, which is reported once the whole data is scanned")
+	} else if info.AtEndOfData && mode&StreamMode == StreamMode {
+		l.report(LintEndOfData, SeverityInfo, "close the stream with a match handler",
+			"can match at the end of data, which is reported when the stream is closed")
+	}
+
+	if info.MaxWidth == hs.UnboundedMaxWidth && mode&StreamMode == StreamMode &&
+		(l.ext == nil || l.ext.Flags&ExtMaxOffset == 0) {
+		if l.Flags&SomLeftMost == SomLeftMost {
+			l.report(LintUnboundedWidth, SeverityWarning, "bound the repeats, or use a larger SOM horizon",
+				"has an unbounded width, the start of match is lost beyond the SOM horizon")
+		} else {
+			l.report(LintUnboundedWidth, SeverityInfo, "bound the repeats, or set MaxOffset",
+				"has an unbounded width, a match may span the whole stream")
+		}
+	}
+
+	if info.ReturnUnordered {
+		l.report(LintUnordered, SeverityWarning, "don't rely on the order of the matches, or set MatchSemantics",
+			"may return the matches out of order")
+	}
+}
+
+// syntax checks the flags and the constructs of the pattern parsed as a regular expression.
+func (l *linter) syntax() {
+	re, err := syntax.Parse(l.Expression, syntax.Perl)
+	if err != nil {
+		var syntaxErr *syntax.Error
+
+		if errors.As(err, &syntaxErr) && syntaxErr.Code == syntax.ErrInvalidRepeatSize {
+			l.report(LintRiskyConstruct, SeverityWarning, "use a smaller repeat, or set MinLength or MaxOffset",
+				"repeats more than 1000 times, which grows the database and the stream state")
+		}
+
+		return
+	}
+
+	if l.Flags&Caseless == Caseless && !hasOp(re, isFoldable) {
+		l.report(LintRedundantFlag, SeverityInfo, fmt.Sprintf("remove the `%s` flag", Caseless),
+			"Caseless has no effect, the expression has no letter")
+	}
+
+	if l.Flags&DotAll == DotAll && !hasOp(re, func(re *syntax.Regexp) bool { return re.Op == syntax.OpAnyCharNotNL }) {
+		l.report(LintRedundantFlag, SeverityInfo, fmt.Sprintf("remove the `%s` flag", DotAll),
+			"DotAll has no effect, the expression has no `.`")
+	}
+
+	if l.Flags&MultiLine == MultiLine && !hasOp(re, func(re *syntax.Regexp) bool {
+		return re.Op == syntax.OpBeginText || re.Op == syntax.OpEndText
+	}) {
+		l.report(LintRedundantFlag, SeverityInfo, fmt.Sprintf("remove the `%s` flag", MultiLine),
+			"MultiLine has no effect, the expression has no `^` or `$`")
+	}
+
+	if l.Flags&UnicodeProperty == UnicodeProperty && l.Flags&Utf8Mode == 0 {
+		l.report(LintRedundantFlag, SeverityWarning, fmt.Sprintf("set the `%s` flag", Utf8Mode),
+			"UnicodeProperty has no effect without Utf8Mode")
+	}
+
+	if hasOp(re, isNestedRepeat) {
+		l.report(LintRiskyConstruct, SeverityWarning, "rewrite the group without the inner repeat",
+			"has nested unbounded repeats, which are slow to compile and grow the database")
+	}
+
+	if l.Flags&SomLeftMost == 0 && re.Op == syntax.OpConcat && len(re.Sub) > 1 && isAnyStar(re.Sub[0]) {
+		l.report(LintRiskyConstruct, SeverityInfo, "remove the leading `.*`",
+			"the leading `.*` is redundant, the matches are reported at the same end offsets without it")
+	}
+}
+
+func hasOp(re *syntax.Regexp, f func(*syntax.Regexp) bool) bool {
+	if f(re) {
+		return true
+	}
+
+	for _, sub := range re.Sub {
+		if hasOp(sub, f) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const maxFoldableRange = 256
+
+// isFoldable reports whether the literal or the character class has a rune affected by the case folding.
+func isFoldable(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if unicode.SimpleFold(r) != r {
+				return true
+			}
+		}
+
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i+1]-re.Rune[i] > maxFoldableRange {
+				return true
+			}
+
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if unicode.SimpleFold(r) != r {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func isUnboundedRepeat(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar || re.Op == syntax.OpPlus || (re.Op == syntax.OpRepeat && re.Max < 0)
+}
+
+// isNestedRepeat reports whether the unbounded repeat contains another unbounded repeat.
+func isNestedRepeat(re *syntax.Regexp) bool {
+	if !isUnboundedRepeat(re) {
+		return false
+	}
+
+	for _, sub := range re.Sub {
+		if hasOp(sub, isUnboundedRepeat) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAnyStar(re *syntax.Regexp) bool {
+	return re.Op == syntax.OpStar && (re.Sub[0].Op == syntax.OpAnyChar || re.Sub[0].Op == syntax.OpAnyCharNotNL)
+}
+
+// lintSet checks the pattern set for the duplicate or subsumed patterns and the duplicate IDs.
+func lintSet(patterns Patterns) (findings []*LintFinding) {
+	exprs := make(map[string]int, len(patterns))
+	ids := make(map[int]int, len(patterns))
+
+	for i, p := range patterns {
+		expr := formatPattern(0, p.Expression, p.Flags, p.ext)
+
+		if j, ok := exprs[expr]; ok {
+			findings = append(findings, &LintFinding{p, i, LintDuplicate, SeverityWarning,
+				fmt.Sprintf("is the same as pattern %d at index %d", patterns[j].Id, j),
+				"remove the pattern, or merge both under the same ID"})
+		} else {
+			exprs[expr] = i
+
+			if j, ok := ids[p.Id]; ok {
+				findings = append(findings, &LintFinding{p, i, LintDuplicateID, SeverityWarning,
+					fmt.Sprintf("has the same ID as pattern `%s` at index %d, their matches are indistinguishable",
+						patterns[j].Expression, j),
+					"use a unique ID"})
+			}
+		}
+
+		if _, ok := ids[p.Id]; !ok {
+			ids[p.Id] = i
+		}
+	}
+
+	return append(findings, lintSubsumed(patterns)...)
+}
+
+// lintSubsumed finds the literal patterns which contain another literal pattern with the same flags.
+func lintSubsumed(patterns Patterns) (findings []*LintFinding) {
+	literals := make([]string, len(patterns))
+	indexes := make(map[CompileFlag]*[2]literalIndex)
+
+	for i, p := range patterns {
+		if literals[i], _ = literalOf(p); literals[i] == "" {
+			continue
+		}
+
+		x, ok := indexes[p.Flags&^Caseless]
+		if !ok {
+			x = new([2]literalIndex)
+			indexes[p.Flags&^Caseless] = x
+		}
+
+		if p.Flags&Caseless == Caseless {
+			x[1].add(strings.ToLower(literals[i]), i)
+		} else {
+			x[0].add(literals[i], i)
+		}
+	}
+
+	for i, p := range patterns {
+		if literals[i] == "" {
+			continue
+		}
+
+		x := indexes[p.Flags&^Caseless]
+
+		subsumed := func(j int) bool {
+			return j != i && (literals[i] != literals[j] || p.Flags != patterns[j].Flags)
+		}
+
+		j := x[1].find(strings.ToLower(literals[i]), subsumed)
+
+		if p.Flags&Caseless == 0 {
+			if k := x[0].find(literals[i], subsumed); k >= 0 && (j < 0 || k < j) {
+				j = k
+			}
+		}
+
+		if j < 0 {
+			continue
+		}
+
+		q := patterns[j]
+
+		fix := fmt.Sprintf("remove the pattern if the ID %d is not needed", p.Id)
+		if p.Id == q.Id {
+			fix = "remove the pattern"
+		}
+
+		findings = append(findings, &LintFinding{p, i, LintSubsumed, SeverityInfo,
+			fmt.Sprintf("every match is also a match of pattern %d `%s` at index %d", q.Id, q.Expression, j), fix})
+	}
+
+	return findings
+}
+
+// literalIndex indexes the literals by their value, to find the literals contained in a string
+// with a lookup per distinct length instead of comparing every pair of literals.
+type literalIndex struct {
+	at      map[string][]int
+	lengths map[int]bool
+}
+
+func (x *literalIndex) add(lit string, i int) {
+	if x.at == nil {
+		x.at, x.lengths = make(map[string][]int), make(map[int]bool)
+	}
+
+	x.at[lit] = append(x.at[lit], i)
+	x.lengths[len(lit)] = true
+}
+
+// find returns the smallest index of the literals contained in s and accepted by ok, or -1 if none.
+func (x *literalIndex) find(s string, ok func(i int) bool) int {
+	found := -1
+
+	for n := range x.lengths {
+		for off := 0; off+n <= len(s); off++ {
+			// the indexes of a literal are added in ascending order.
+			for _, i := range x.at[s[off:off+n]] {
+				if found >= 0 && i >= found {
+					break
+				}
+
+				if ok(i) {
+					found = i
+
+					break
+				}
+			}
+		}
+	}
+
+	return found
+}
+
+// literalOf returns the literal matched by the pattern without the extended parameters.
+func literalOf(p *Pattern) (string, bool) {
+	if p.ext != nil && p.ext.Flags != 0 {
+		return "", false
+	}
+
+	re, err := syntax.Parse(p.Expression, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	if re = re.Simplify(); re.Op != syntax.OpLiteral || re.Flags&syntax.FoldCase != 0 {
+		return "", false
+	}
+
+	return string(re.Rune), true
+}
@@ -0,0 +1,172 @@
+package hyperscan_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+func findingsOf(findings []*hyperscan.LintFinding, index int) map[hyperscan.LintCheck]hyperscan.Severity {
+	checks := make(map[hyperscan.LintCheck]hyperscan.Severity)
+
+	for _, f := range findings {
+		if f.Index == index {
+			checks[f.Check] = f.Severity
+		}
+	}
+
+	return checks
+}
+
+//nolint:funlen
+func TestLint(t *testing.T) {
+	Convey("Given some troublesome patterns", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `a*`, Id: 1},
+			{Expression: `b*`, Flags: hyperscan.AllowEmpty, Id: 2},
+			{Expression: `foo$`, Id: 3},
+			{Expression: `a(`, Id: 4},
+			{Expression: `\d+`, Flags: hyperscan.Caseless | hyperscan.DotAll | hyperscan.MultiLine, Id: 5},
+			{Expression: `(a+)+b`, Id: 6},
+			{Expression: `.*xyz`, Id: 7},
+			{Expression: `\w`, Flags: hyperscan.UnicodeProperty, Id: 8},
+		}
+
+		Convey("When lint the patterns", func() {
+			findings := hyperscan.Lint(patterns)
+
+			So(findingsOf(findings, 0), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintEmptyMatch: hyperscan.SeverityError,
+			})
+			So(findingsOf(findings, 1), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintEmptyMatch: hyperscan.SeverityWarning,
+			})
+			So(findingsOf(findings, 2), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintEndOfData: hyperscan.SeverityWarning,
+			})
+			So(findingsOf(findings, 3), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintInvalid: hyperscan.SeverityError,
+			})
+			So(findingsOf(findings, 4), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintRedundantFlag: hyperscan.SeverityInfo,
+			})
+			So(findingsOf(findings, 5), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintRiskyConstruct: hyperscan.SeverityWarning,
+			})
+			So(findingsOf(findings, 6), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintRiskyConstruct: hyperscan.SeverityInfo,
+			})
+			So(findingsOf(findings, 7), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintRedundantFlag: hyperscan.SeverityWarning,
+			})
+
+			Convey("Then each redundant flag is reported with a fix", func() {
+				var fixes []string
+
+				for _, f := range findings {
+					if f.Index == 4 {
+						fixes = append(fixes, f.Fix)
+					}
+				}
+
+				So(fixes, ShouldResemble, []string{"remove the `i` flag", "remove the `s` flag", "remove the `m` flag"})
+			})
+
+			Convey("Then the findings are ordered by the index", func() {
+				for i := 1; i < len(findings); i++ {
+					So(findings[i].Index, ShouldBeGreaterThanOrEqualTo, findings[i-1].Index)
+				}
+			})
+		})
+	})
+
+	Convey("Given a pattern set with duplicates", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Id: 1},
+			{Expression: `foobar`, Id: 2},
+			{Expression: `foo`, Id: 3},
+			{Expression: `bar`, Id: 1, Pos: hyperscan.Position{Filename: "rules.txt", Line: 4, Column: 1}},
+			{Expression: `xFOOy`, Flags: hyperscan.Caseless, Id: 5},
+		}
+
+		Convey("When lint the patterns", func() {
+			findings := hyperscan.Lint(patterns)
+
+			So(findingsOf(findings, 0), ShouldBeEmpty)
+			So(findingsOf(findings, 1), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintSubsumed: hyperscan.SeverityInfo,
+			})
+			So(findingsOf(findings, 2), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintDuplicate: hyperscan.SeverityWarning,
+			})
+			So(findingsOf(findings, 3), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintDuplicateID: hyperscan.SeverityWarning,
+			})
+			So(findingsOf(findings, 4), ShouldBeEmpty)
+
+			Convey("Then the finding carries the position of the pattern", func() {
+				for _, f := range findings {
+					if f.Index == 3 {
+						So(f.String(), ShouldStartWith, "rules.txt:4:1: warning: pattern 1 `bar` at index 3, ")
+						So(f.String(), ShouldEndWith, "[duplicate-id]; use a unique ID")
+					}
+				}
+			})
+		})
+	})
+
+	Convey("Given the literals contained in each other", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `abc`, Id: 1},
+			{Expression: `ABC`, Flags: hyperscan.Caseless, Id: 2},
+			{Expression: `xabcx`, Id: 3},
+			{Expression: `XABCX`, Flags: hyperscan.Caseless, Id: 4},
+			{Expression: `zzabc`, Flags: hyperscan.SingleMatch, Id: 5},
+		}
+
+		Convey("When lint the patterns", func() {
+			messages := make(map[int]string)
+
+			for _, f := range hyperscan.Lint(patterns) {
+				if f.Check == hyperscan.LintSubsumed {
+					messages[f.Index] = f.Message
+				}
+			}
+
+			Convey("The first subsuming pattern of the same flags is reported", func() {
+				So(messages, ShouldResemble, map[int]string{
+					0: "every match is also a match of pattern 2 `ABC` at index 1",
+					2: "every match is also a match of pattern 1 `abc` at index 0",
+					3: "every match is also a match of pattern 2 `ABC` at index 1",
+				})
+			})
+		})
+	})
+
+	Convey("Given the patterns with an unbounded width", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `a.+b`, Id: 1},
+			{Expression: `a.+b`, Flags: hyperscan.SomLeftMost, Id: 2},
+			{Expression: `a.{1,5}b`, Id: 3},
+		}
+
+		Convey("When lint the patterns for the block mode", func() {
+			So(hyperscan.Lint(patterns), ShouldBeEmpty)
+		})
+
+		Convey("When lint the patterns for the streaming mode", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode}
+			findings := b.Lint()
+
+			So(findingsOf(findings, 0), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintUnboundedWidth: hyperscan.SeverityInfo,
+			})
+			So(findingsOf(findings, 1), ShouldResemble, map[hyperscan.LintCheck]hyperscan.Severity{
+				hyperscan.LintUnboundedWidth: hyperscan.SeverityWarning,
+			})
+			So(findingsOf(findings, 2), ShouldBeEmpty)
+		})
+	})
+}
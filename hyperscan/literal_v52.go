@@ -5,7 +5,6 @@ package hyperscan
 
 import (
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/flier/gohs/internal/hs"
@@ -23,6 +22,7 @@ type Literal struct {
 	Flags      CompileFlag // Flags which modify the behaviour of the expression.
 	Id         int         // The ID number to be associated with the corresponding pattern
 	*ExprInfo
+	ext *ExprExt
 }
 
 // NewLiteral returns a new Literal base on expression and compile flags.
@@ -55,22 +55,33 @@ func (lit *Literal) Info() (*ExprInfo, error) {
 	return lit.ExprInfo, nil
 }
 
-func (lit *Literal) String() string {
-	var b strings.Builder
-
-	if lit.Id > 0 {
-		fmt.Fprintf(&b, "%d:", lit.Id)
+// WithExt is used to set the additional parameters related to the literal.
+//
+// The literal API doesn't support the extended parameters,
+// so the literals with them are compiled as the equivalent regular expressions.
+func (lit *Literal) WithExt(exts ...Ext) *Literal {
+	if lit.ext == nil {
+		lit.ext = new(ExprExt)
 	}
 
-	fmt.Fprintf(&b, "/%s/%s", lit.Expression, lit.Flags)
+	lit.ext.With(exts...)
 
-	return b.String()
+	return lit
+}
+
+// Ext provides the additional parameters related to the literal, or nil if none.
+func (lit *Literal) Ext() *ExprExt { return lit.ext }
+
+// String returns the literal in the format parsed by ParseLiteral, ParseLiteral(lit.String()) returns the same literal.
+func (lit *Literal) String() string {
+	return formatPattern(lit.Id, lit.Expression, lit.Flags, lit.ext)
 }
 
 /*
 Parse literal from a formated string
 
-	<integer id>:/<expression>/<flags>
+	<integer id>:/<expression>/<flags>{<extensions>}
+	<integer id>:m<delimiter><expression><delimiter><flags>{<extensions>}
 
 For example, the following literal will match `test` in the caseless and multi-lines mode
 
@@ -79,28 +90,22 @@ For example, the following literal will match `test` in the caseless and multi-l
 func ParseLiteral(s string) (*Literal, error) {
 	var lit Literal
 
-	i := strings.Index(s, ":/")
-	j := strings.LastIndex(s, "/")
-	if i > 0 && j > i+1 {
-		id, err := strconv.Atoi(s[:i])
-		if err != nil {
-			return nil, fmt.Errorf("invalid pattern id `%s`, %w", s[:i], ErrInvalid)
-		}
-		lit.Id = id
-		s = s[i+1:]
+	id, expr, flags, ext, err := splitPattern(s)
+	if err != nil {
+		return nil, err
 	}
 
-	if n := strings.LastIndex(s, "/"); n > 1 && strings.HasPrefix(s, "/") {
-		lit.Expression = s[1:n]
-		s = s[n+1:]
+	lit.Id = id
+	lit.Expression = expr
 
-		flags, err := ParseCompileFlag(s)
-		if err != nil {
-			return nil, fmt.Errorf("invalid pattern flags `%s`, %w", s, err)
+	if ext != "" {
+		if lit.ext, err = ParseExprExt(ext); err != nil {
+			return nil, fmt.Errorf("invalid literal extensions `%s`, %w", ext, err)
 		}
-		lit.Flags = flags
-	} else {
-		lit.Expression = s
+	}
+
+	if lit.Flags, err = ParseCompileFlag(flags); err != nil {
+		return nil, fmt.Errorf("invalid pattern flags `%s`, %w", flags, err)
 	}
 
 	info, err := hs.ExpressionInfo(lit.Expression, lit.Flags)
@@ -130,6 +135,10 @@ func (lit *Literal) Build(mode ModeFlag) (Database, error) {
 }
 
 func (lit *Literal) ForPlatform(mode ModeFlag, platform Platform) (Database, error) {
+	if lit.ext != nil {
+		return Literals{lit}.ForPlatform(mode, platform)
+	}
+
 	if mode == 0 {
 		mode = BlockMode
 	} else if mode == StreamMode {
@@ -174,6 +183,12 @@ func (literals Literals) Build(mode ModeFlag) (Database, error) {
 }
 
 func (literals Literals) ForPlatform(mode ModeFlag, platform Platform) (Database, error) {
+	for _, lit := range literals {
+		if lit.ext != nil {
+			return literals.patterns().ForPlatform(mode, platform)
+		}
+	}
+
 	if mode == 0 {
 		mode = BlockMode
 	} else if mode == StreamMode {
@@ -208,3 +223,29 @@ func (literals Literals) ForPlatform(mode ModeFlag, platform Platform) (Database
 
 	return nil, fmt.Errorf("mode %d, %w", mode, ErrInvalid)
 }
+
+// patterns returns the regular expressions matching the literals.
+func (literals Literals) patterns() Patterns {
+	patterns := make(Patterns, len(literals))
+
+	for i, lit := range literals {
+		patterns[i] = &Pattern{Expression: quoteLiteral(lit.Expression), Flags: lit.Flags, Id: lit.Id, ext: lit.ext}
+	}
+
+	return patterns
+}
+
+// quoteLiteral escapes all the bytes of the literal except the letters and digits.
+func quoteLiteral(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "\\x%02x", c)
+		}
+	}
+
+	return b.String()
+}
@@ -4,6 +4,7 @@
 package hyperscan_test
 
 import (
+	"reflect"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -96,3 +97,37 @@ func TestLiteral(t *testing.T) {
 		})
 	})
 }
+
+func FuzzParseLiteral(f *testing.F) {
+	f.Add("test", "im", 0, uint8(0), uint32(0))
+	f.Add(`te\/st`, "", 3, uint8(1), uint32(4))
+	f.Add("a/i", "s", -1, uint8(6), uint32(8))
+
+	f.Fuzz(func(t *testing.T, expr, flags string, id int, exts uint8, n uint32) {
+		compileFlags, err := hyperscan.ParseCompileFlag(flags)
+		if err != nil {
+			t.Skip()
+		}
+
+		// ParseLiteral validates the literal as an expression.
+		if !hyperscan.NewPattern(expr, compileFlags).IsValid() {
+			t.Skip()
+		}
+
+		lit := &hyperscan.Literal{Expression: expr, Flags: compileFlags, Id: id}
+
+		if exts := fuzzExts(exts, n); len(exts) > 0 {
+			lit.WithExt(exts...)
+		}
+
+		q, err := hyperscan.ParseLiteral(lit.String())
+		if err != nil {
+			t.Fatalf("parse `%s`, %v", lit, err)
+		}
+
+		if q.Expression != lit.Expression || q.Flags != lit.Flags || q.Id != lit.Id || q.String() != lit.String() ||
+			!reflect.DeepEqual(q.Ext(), lit.Ext()) {
+			t.Fatalf("`%s` parsed as `%s`", lit, q)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package hyperscan
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// Placement is the memory where LoadDatabase places the deserialized database.
+type Placement int
+
+const (
+	// PlaceHeap places the database in an 8-byte aligned buffer of the Go heap.
+	PlaceHeap Placement = iota
+	// PlaceAnonymous places the database in a private anonymous memory mapping.
+	PlaceAnonymous
+	// PlaceShared places the database in a shared memory mapping of LoadOptions.SharedFile,
+	// so the processes loading the same database with the same file share its pages.
+	PlaceShared
+)
+
+// LoadOptions are the options of LoadDatabase.
+type LoadOptions struct {
+	// The memory where the database is placed.
+	Placement Placement
+
+	// The file mapped by PlaceShared, which holds the deserialized database followed by the checksum of the
+	// serialized one. The file is created if it doesn't exist or holds another database, then reused as is.
+	SharedFile string
+}
+
+// LoadDatabase reads a serialized database, and deserializes it in the memory of the placement.
+//
+// The database is a block, streaming or vectored database according to its mode.
+func LoadDatabase(r io.Reader, opts *LoadOptions) (Database, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read database, %w", err)
+	}
+
+	return loadDatabase(data, opts)
+}
+
+// LoadDatabaseFile reads a serialized database from the file, and deserializes it in the memory of the placement.
+func LoadDatabaseFile(path string, opts *LoadOptions) (Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read database, %w", err)
+	}
+
+	return loadDatabase(data, opts)
+}
+
+func loadDatabase(data []byte, opts *LoadOptions) (Database, error) {
+	if opts == nil {
+		opts = &LoadOptions{}
+	}
+
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty database, %w", ErrInvalid)
+	}
+
+	info, err := SerializedDatabaseInfo(data)
+	if err != nil {
+		return nil, fmt.Errorf("database info, %w", err)
+	}
+
+	mode, err := info.Mode()
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := hs.SerializedDatabaseSize(data)
+	if err != nil {
+		return nil, fmt.Errorf("database size, %w", err)
+	}
+
+	if size <= 0 {
+		return nil, fmt.Errorf("database size %d, %w", size, ErrInvalid)
+	}
+
+	deserialize := func(mem []byte) error {
+		if err := hs.DeserializeDatabaseAt(data, hs.DatabaseAt(mem)); err != nil {
+			return fmt.Errorf("deserialize database of %d bytes, truncated or corrupted, %w", len(data), err)
+		}
+
+		return nil
+	}
+
+	var (
+		mem     []byte
+		release func() error
+	)
+
+	switch opts.Placement {
+	case PlaceHeap:
+		mem, release = alignedBuffer(size)
+		err = deserialize(mem)
+
+	case PlaceAnonymous:
+		if mem, release, err = mapAnonymous(size); err == nil {
+			if err = deserialize(mem); err != nil {
+				_ = release()
+			}
+		}
+
+	case PlaceShared:
+		if opts.SharedFile == "" {
+			return nil, fmt.Errorf("shared placement without file, %w", ErrInvalid)
+		}
+
+		mem, release, err = mapShared(opts.SharedFile, size, sha256.Sum256(data), info, deserialize)
+
+	default:
+		return nil, fmt.Errorf("placement %d, %w", opts.Placement, ErrInvalid)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	db := placedDatabase(hs.DatabaseAt(mem), mode)
+	db.(interface{ base() *baseDatabase }).base().release = release
+
+	return db, nil
+}
+
+// placedDatabase creates a database of the mode from the deserialized database.
+func placedDatabase(db hs.Database, mode ModeFlag) Database {
+	switch mode & hs.ModeMask {
+	case StreamMode:
+		return newStreamDatabase(db)
+	case VectoredMode:
+		return newVectoredDatabase(db)
+	default:
+		return newBlockDatabase(db)
+	}
+}
+
+// alignedBuffer allocates an 8-byte aligned buffer, which is kept alive until released.
+func alignedBuffer(size int) ([]byte, func() error) {
+	words := make([]uint64, (size+7)/8) //nolint: gomnd
+
+	return unsafe.Slice((*byte)(unsafe.Pointer(&words[0])), size), func() error {
+		runtime.KeepAlive(words)
+
+		return nil
+	}
+}
+
+// checkPlaced checks that the memory holds a placed database of the info, followed by the checksum.
+func checkPlaced(mem []byte, size int, sum [sha256.Size]byte, info DbInfo) bool {
+	if len(mem) != size+sha256.Size || string(mem[size:]) != string(sum[:]) {
+		return false
+	}
+
+	placed, err := hs.DatabaseInfo(hs.DatabaseAt(mem[:size]))
+
+	return err == nil && DbInfo(placed) == info
+}
@@ -0,0 +1,114 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestLoadDatabase(t *testing.T) {
+	Convey("Given a serialized database", t, func() {
+		db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo\d+`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		data, err := db.Marshal()
+		So(err, ShouldBeNil)
+		So(db.Close(), ShouldBeNil)
+
+		dir := t.TempDir()
+
+		Convey("When load it from a reader", func() {
+			loaded, err := hyperscan.LoadDatabase(bytes.NewReader(data), nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then it is placed in the Go heap", func() {
+				So(loaded, ShouldImplement, (*hyperscan.BlockDatabase)(nil))
+				So(loaded.(hyperscan.BlockDatabase).FindAllString("foo1 foo23", -1), ShouldResemble, []string{"foo1", "foo23"})
+				So(loaded.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("When load it from a file in an anonymous mapping", func() {
+			path := filepath.Join(dir, "foo.db")
+			So(os.WriteFile(path, data, 0o600), ShouldBeNil)
+
+			loaded, err := hyperscan.LoadDatabaseFile(path, &hyperscan.LoadOptions{Placement: hyperscan.PlaceAnonymous})
+			So(err, ShouldBeNil)
+
+			Convey("Then it could be scanned", func() {
+				So(loaded.(hyperscan.BlockDatabase).MatchString("foo42"), ShouldBeTrue)
+				So(loaded.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("When load it twice in a shared mapping", func() {
+			shared := filepath.Join(dir, "foo.shm")
+			opts := &hyperscan.LoadOptions{Placement: hyperscan.PlaceShared, SharedFile: shared}
+
+			first, err := hyperscan.LoadDatabase(bytes.NewReader(data), opts)
+			So(err, ShouldBeNil)
+
+			defer first.Close()
+
+			created, err := os.Stat(shared)
+			So(err, ShouldBeNil)
+
+			second, err := hyperscan.LoadDatabase(bytes.NewReader(data), opts)
+			So(err, ShouldBeNil)
+
+			defer second.Close()
+
+			Convey("Then the shared file is reused", func() {
+				reused, err := os.Stat(shared)
+				So(err, ShouldBeNil)
+				So(os.SameFile(created, reused), ShouldBeTrue)
+
+				So(second.(hyperscan.BlockDatabase).FindString("a foo7"), ShouldEqual, "foo7")
+			})
+
+			Convey("Then the shared file is replaced by another database", func() {
+				other, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`bar`, 0))
+				So(err, ShouldBeNil)
+
+				otherData, err := other.Marshal()
+				So(err, ShouldBeNil)
+				So(other.Close(), ShouldBeNil)
+
+				third, err := hyperscan.LoadDatabase(bytes.NewReader(otherData), opts)
+				So(err, ShouldBeNil)
+
+				defer third.Close()
+
+				replaced, err := os.Stat(shared)
+				So(err, ShouldBeNil)
+				So(os.SameFile(created, replaced), ShouldBeFalse)
+				So(third, ShouldImplement, (*hyperscan.StreamDatabase)(nil))
+			})
+		})
+
+		Convey("When load an empty database", func() {
+			_, err := hyperscan.LoadDatabase(bytes.NewReader(nil), nil)
+			So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+
+			_, err = hyperscan.UnmarshalBlockDatabase(nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When load a truncated database", func() {
+			_, err := hyperscan.LoadDatabase(bytes.NewReader(data[:16]), nil)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When load in the shared mapping without file", func() {
+			_, err := hyperscan.LoadDatabase(bytes.NewReader(data), &hyperscan.LoadOptions{Placement: hyperscan.PlaceShared})
+			So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+		})
+	})
+}
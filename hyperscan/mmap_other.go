@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package hyperscan
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+func mapAnonymous(size int) ([]byte, func() error, error) {
+	return nil, nil, fmt.Errorf("memory mapping, %w", ErrInvalid)
+}
+
+func mapShared(path string, size int, sum [sha256.Size]byte, info DbInfo,
+	deserialize func(mem []byte) error,
+) ([]byte, func() error, error) {
+	return nil, nil, fmt.Errorf("memory mapping, %w", ErrInvalid)
+}
@@ -0,0 +1,91 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package hyperscan
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// mapAnonymous maps a private anonymous memory region.
+func mapAnonymous(size int) ([]byte, func() error, error) {
+	mem, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, nil, fmt.Errorf("map anonymous memory, %w", err)
+	}
+
+	return mem, func() error { return syscall.Munmap(mem) }, nil
+}
+
+// mapShared maps the file holding the placed database, or creates it with the deserialized database.
+//
+// The file is written to a temporary file then renamed, so the concurrent loaders never map a partial database.
+func mapShared(path string, size int, sum [sha256.Size]byte, info DbInfo,
+	deserialize func(mem []byte) error,
+) ([]byte, func() error, error) {
+	if mem, err := mapFile(path, syscall.PROT_READ); err == nil {
+		if checkPlaced(mem, size, sum, info) {
+			return mem[:size], func() error { return syscall.Munmap(mem) }, nil
+		}
+
+		_ = syscall.Munmap(mem)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, nil, fmt.Errorf("create shared file, %w", err)
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err = f.Truncate(int64(size + sha256.Size)); err != nil {
+		return nil, nil, fmt.Errorf("resize shared file, %w", err)
+	}
+
+	mem, err := syscall.Mmap(int(f.Fd()), 0, size+sha256.Size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, fmt.Errorf("map shared file, %w", err)
+	}
+
+	release := func() error { return syscall.Munmap(mem) }
+
+	if err = deserialize(mem[:size]); err == nil {
+		copy(mem[size:], sum[:])
+
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = release()
+
+		return nil, nil, err
+	}
+
+	return mem[:size], release, nil
+}
+
+// mapFile maps the whole file.
+func mapFile(path string, prot int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err //nolint: wrapcheck
+	}
+
+	if fi.Size() == 0 {
+		return nil, fmt.Errorf("empty file, %w", ErrInvalid)
+	}
+
+	return syscall.Mmap(int(f.Fd()), 0, int(fi.Size()), prot, syscall.MAP_SHARED) //nolint: wrapcheck
+}
@@ -181,7 +181,8 @@ type Pattern struct {
 	Expression string      // The expression to parse.
 	Flags      CompileFlag // Flags which modify the behaviour of the expression.
 	// The ID number to be associated with the corresponding pattern
-	Id   int //nolint: revive,stylecheck
+	Id   int      //nolint: revive,stylecheck
+	Pos  Position // The position of the pattern in the source file, if any.
 	info *ExprInfo
 	ext  *ExprExt
 }
@@ -255,69 +256,43 @@ func (p *Pattern) Ext() (*ExprExt, error) {
 	return p.ext, nil
 }
 
+// String returns the pattern in the format parsed by ParsePattern, ParsePattern(p.String()) returns the same pattern.
 func (p *Pattern) String() string {
-	var b strings.Builder
-
-	if p.Id > 0 {
-		fmt.Fprintf(&b, "%d:", p.Id)
-	}
-
-	fmt.Fprintf(&b, "/%s/%s", p.Expression, p.Flags)
-
-	if p.ext != nil {
-		b.WriteString(p.ext.String())
-	}
-
-	return b.String()
+	return formatPattern(p.Id, p.Expression, p.Flags, p.ext)
 }
 
 /*
 ParsePattern parse pattern from a formated string.
 
-	<integer id>:/<expression>/<flags>
+	<integer id>:/<expression>/<flags>{<extensions>}
+	<integer id>:m<delimiter><expression><delimiter><flags>{<extensions>}
 
 For example, the following pattern will match `test` in the caseless and multi-lines mode
 
 	/test/im
+
+The closing delimiter is the last one followed by the flags and extensions,
+so the expression may contain the delimiter, escaped or not, e.g. `/https?:\/\//i` or `m#https?://#i`.
 */
 func ParsePattern(s string) (*Pattern, error) {
 	var p Pattern
 
-	i := strings.Index(s, ":/")
-	j := strings.LastIndex(s, "/")
-
-	if i > 0 && j > i+1 {
-		id, err := strconv.Atoi(s[:i])
-		if err != nil {
-			return nil, fmt.Errorf("pattern id `%s`, %w", s[:i], ErrInvalid)
-		}
-
-		p.Id = id
-		s = s[i+1:]
+	id, expr, flags, ext, err := splitPattern(s)
+	if err != nil {
+		return nil, err
 	}
 
-	if n := strings.LastIndex(s, "/"); n > 1 && strings.HasPrefix(s, "/") {
-		p.Expression = s[1:n]
-		s = s[n+1:]
-
-		if n = strings.Index(s, "{"); n > 0 && strings.HasSuffix(s, "}") {
-			ext, err := ParseExprExt(s[n:])
-			if err != nil {
-				return nil, fmt.Errorf("expression extensions `%s`, %w", s[n:], err)
-			}
+	p.Id = id
+	p.Expression = expr
 
-			p.ext = ext
-			s = s[:n]
-		}
-
-		flags, err := ParseCompileFlag(s)
-		if err != nil {
-			return nil, fmt.Errorf("pattern flags `%s`, %w", s, err)
+	if ext != "" {
+		if p.ext, err = ParseExprExt(ext); err != nil {
+			return nil, fmt.Errorf("expression extensions `%s`, %w", ext, err)
 		}
+	}
 
-		p.Flags = flags
-	} else {
-		p.Expression = s
+	if p.Flags, err = ParseCompileFlag(flags); err != nil {
+		return nil, fmt.Errorf("pattern flags `%s`, %w", flags, err)
 	}
 
 	info, err := hs.ExpressionInfo(p.Expression, p.Flags)
@@ -333,12 +308,109 @@ func ParsePattern(s string) (*Pattern, error) {
 // Patterns is a set of matching patterns.
 type Patterns []*Pattern
 
-// ParsePatterns parse lines as `Patterns`.
+// Position is the origin of a pattern in a source file.
+type Position = hs.Position
+
+// ParseError is the error of a line which failed to parse.
+type ParseError struct {
+	Position
+	Err error
+}
+
+func (e *ParseError) Error() string { return e.Position.String() + ": " + e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// ParseErrors lists the errors of all the lines which failed to parse.
+//
+// It implements `Unwrap() []error` like the error returned by errors.Join.
+type ParseErrors []*ParseError
+
+func (e ParseErrors) Error() string {
+	msgs := make([]string, len(e))
+
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the lines.
+func (e ParseErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// ParsePatterns parse lines as `Patterns`, each pattern carries its position.
+//
+// It stops at the first bad line and returns a *ParseError.
 func ParsePatterns(r io.Reader) (patterns Patterns, err error) {
+	patterns, errs, err := parsePatterns("", r, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	return patterns, nil
+}
+
+// ParsePatternsFrom parse lines of the named file as `Patterns`, each pattern carries its position.
+//
+// It continues past the bad lines, and returns the parsed patterns and ParseErrors listing all the bad lines.
+func ParsePatternsFrom(filename string, r io.Reader) (Patterns, error) {
+	patterns, errs, err := parsePatterns(filename, r, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return patterns, errs
+	}
+
+	return patterns, nil
+}
+
+func parsePatterns(filename string, r io.Reader, failFast bool) (patterns Patterns, errs ParseErrors, err error) {
+	err = scanLines(filename, r, func(pos Position, line string) bool {
+		p, err := ParsePattern(line)
+		if err != nil {
+			errs = append(errs, &ParseError{pos, err})
+
+			return !failFast
+		}
+
+		p.Pos = pos
+		patterns = append(patterns, p)
+
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return
+}
+
+// scanLines calls fn with the position and content of each line which is neither empty nor a comment,
+// until fn returns false.
+func scanLines(filename string, r io.Reader, fn func(pos Position, line string) bool) error {
 	s := bufio.NewScanner(r)
+	pos := Position{Filename: filename}
 
 	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
+		pos.Line++
+
+		text := s.Text()
+		line := strings.TrimSpace(text)
 
 		if line == "" {
 			// skip empty line
@@ -350,15 +422,18 @@ func ParsePatterns(r io.Reader) (patterns Patterns, err error) {
 			continue
 		}
 
-		p, err := ParsePattern(line)
-		if err != nil {
-			return nil, err
+		pos.Column = strings.Index(text, line) + 1
+
+		if !fn(pos, line) {
+			break
 		}
+	}
 
-		patterns = append(patterns, p)
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("read patterns, %w", err)
 	}
 
-	return
+	return nil
 }
 
 func (p Patterns) Patterns() (r []*hs.Pattern) {
@@ -1,6 +1,9 @@
 package hyperscan_test
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -30,7 +33,7 @@ func TestPattern(t *testing.T) {
 				So(p.Expression, ShouldEqual, "te/st")
 				So(p.Flags, ShouldEqual, hyperscan.Caseless|hyperscan.MultiLine)
 
-				So(p.String(), ShouldEqual, "/te/st/im")
+				So(p.String(), ShouldEqual, `/te\/st/im`)
 			})
 		})
 
@@ -57,6 +60,94 @@ func TestPattern(t *testing.T) {
 			So(p.String(), ShouldEqual, "3:/foobar/8i{min_offset=4,min_length=8}")
 		})
 
+		Convey("When parse pattern with escaped delimiters", func() {
+			p, err := hyperscan.ParsePattern(`/http:\/\/x\d\\/i`)
+
+			So(err, ShouldBeNil)
+			So(p.Expression, ShouldEqual, `http://x\d\\`)
+			So(p.Flags, ShouldEqual, hyperscan.Caseless)
+			So(p.String(), ShouldEqual, `/http:\/\/x\d\\/i`)
+		})
+
+		Convey("When format the expressions ending with the delimiter and flags", func() {
+			p := &hyperscan.Pattern{Expression: `a/i`, Flags: hyperscan.DotAll}
+
+			So(p.String(), ShouldEqual, `/a\/i/s`)
+
+			q, err := hyperscan.ParsePattern(p.String())
+
+			So(err, ShouldBeNil)
+			So(q.Expression, ShouldEqual, p.Expression)
+			So(q.Flags, ShouldEqual, p.Flags)
+		})
+
+		Convey("When format the expressions containing the escaped delimiter", func() {
+			p := &hyperscan.Pattern{Expression: `http:\/\/#x`}
+
+			So(p.String(), ShouldEqual, `m!http:\/\/#x!`)
+
+			q, err := hyperscan.ParsePattern(p.String())
+
+			So(err, ShouldBeNil)
+			So(q.Expression, ShouldEqual, p.Expression)
+		})
+
+		Convey("When parse pattern with alternate delimiters", func() {
+			p, err := hyperscan.ParsePattern(`12:m#https?://#i`)
+
+			So(err, ShouldBeNil)
+			So(p.Id, ShouldEqual, 12)
+			So(p.Expression, ShouldEqual, "https?://")
+			So(p.Flags, ShouldEqual, hyperscan.Caseless)
+			So(p.String(), ShouldEqual, `12:/https?:\/\//i`)
+
+			q, err := hyperscan.ParsePattern(p.String())
+
+			So(err, ShouldBeNil)
+			So(q, ShouldResemble, p)
+		})
+
+		Convey("When the expression looks like flags", func() {
+			p, err := hyperscan.ParsePattern(`/a/b/i`)
+
+			So(err, ShouldBeNil)
+			So(p.Expression, ShouldEqual, "a/b")
+			So(p.Flags, ShouldEqual, hyperscan.Caseless)
+		})
+
+		Convey("When the expression contains a colon", func() {
+			p, err := hyperscan.ParsePattern(`12:/a:b/`)
+
+			So(err, ShouldBeNil)
+			So(p.Id, ShouldEqual, 12)
+			So(p.Expression, ShouldEqual, "a:b")
+		})
+
+		Convey("When parse pattern with negative id", func() {
+			p, err := hyperscan.ParsePattern(`-1:/x/`)
+
+			So(err, ShouldBeNil)
+			So(p.Id, ShouldEqual, -1)
+			So(p.String(), ShouldEqual, "-1:/x/")
+		})
+
+		Convey("When parse pattern with invalid id", func() {
+			_, err := hyperscan.ParsePattern(`x:/test/`)
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("When format pattern with extensions", func() {
+			p := hyperscan.NewPattern(`a/b`, hyperscan.Caseless).WithExt(hyperscan.MaxOffset(10))
+
+			q, err := hyperscan.ParsePattern(p.String())
+
+			So(err, ShouldBeNil)
+			So(q.Expression, ShouldEqual, "a/b")
+			So(q.Flags, ShouldEqual, hyperscan.Caseless)
+			So(q.String(), ShouldEqual, p.String())
+		})
+
 		Convey("When parse with a lot of flags", func() {
 			p, err := hyperscan.ParsePattern(`/test/ismoeupf`)
 
@@ -115,3 +206,118 @@ func TestPattern(t *testing.T) {
 		})
 	})
 }
+
+func TestParsePatterns(t *testing.T) {
+	Convey("Given a rule file with bad lines", t, func() {
+		rules := `# comment
+1:/foo/i
+
+  2:a(
+3:/bar/s
+4:/baz/Q-
+`
+
+		Convey("When parse the patterns", func() {
+			_, err := hyperscan.ParsePatterns(strings.NewReader(rules))
+
+			Convey("Then it stops at the first bad line", func() {
+				var parseErr *hyperscan.ParseError
+
+				So(errors.As(err, &parseErr), ShouldBeTrue)
+				So(parseErr.Position, ShouldResemble, hyperscan.Position{Line: 4, Column: 3})
+				So(err.Error(), ShouldStartWith, "4:3: ")
+			})
+		})
+
+		Convey("When parse the patterns of a file", func() {
+			patterns, err := hyperscan.ParsePatternsFrom("rules.txt", strings.NewReader(rules))
+
+			Convey("Then the patterns carry their positions", func() {
+				So(patterns, ShouldHaveLength, 2)
+				So(patterns[0].Pos, ShouldResemble, hyperscan.Position{Filename: "rules.txt", Line: 2, Column: 1})
+				So(patterns[1].Pos.String(), ShouldEqual, "rules.txt:5:1")
+			})
+
+			Convey("Then all the bad lines are reported", func() {
+				var parseErrs hyperscan.ParseErrors
+
+				So(errors.As(err, &parseErrs), ShouldBeTrue)
+				So(parseErrs, ShouldHaveLength, 2)
+				So(parseErrs[0].String(), ShouldEqual, "rules.txt:4:3")
+				So(parseErrs[1].String(), ShouldEqual, "rules.txt:6:1")
+				So(parseErrs.Unwrap(), ShouldHaveLength, 2)
+			})
+		})
+
+		Convey("When compile the patterns with a bad pattern", func() {
+			patterns, err := hyperscan.ParsePatternsFrom("rules.txt", strings.NewReader(rules))
+			So(err, ShouldNotBeNil)
+
+			patterns = append(patterns, &hyperscan.Pattern{Expression: "(", Id: 9, Pos: hyperscan.Position{Line: 10, Column: 1}})
+
+			_, err = patterns.Build(hyperscan.BlockMode)
+
+			Convey("Then the compile error carries the position", func() {
+				var compileErr *hyperscan.CompileError
+
+				So(errors.As(err, &compileErr), ShouldBeTrue)
+				So(err.Error(), ShouldStartWith, "10:1: pattern 9 `(` at index 2, ")
+			})
+		})
+	})
+}
+
+// fuzzExts returns the extensions selected by the bits of exts, all with the value n.
+func fuzzExts(exts uint8, n uint32) (res []hyperscan.Ext) {
+	for i, ext := range []hyperscan.Ext{
+		hyperscan.MinOffset(uint64(n)), hyperscan.MaxOffset(uint64(n)), hyperscan.MinLength(uint64(n)),
+		hyperscan.EditDistance(n), hyperscan.HammingDistance(n),
+	} {
+		if exts&(1<<i) != 0 {
+			res = append(res, ext)
+		}
+	}
+
+	return
+}
+
+func FuzzParsePattern(f *testing.F) {
+	f.Add("test", "im", 0, uint8(0), uint32(0))
+	f.Add("te/st", "", 3, uint8(1), uint32(4))
+	f.Add(`http:\/\/x`, "i", 12, uint8(0), uint32(0))
+	f.Add("a:b/i", "s", -1, uint8(6), uint32(8))
+	f.Add("a/i", "", 0, uint8(31), uint32(2))
+	f.Add(`x\/#!|%~@;`, "", 0, uint8(0), uint32(0))
+
+	f.Fuzz(func(t *testing.T, expr, flags string, id int, exts uint8, n uint32) {
+		compileFlags, err := hyperscan.ParseCompileFlag(flags)
+		if err != nil {
+			t.Skip()
+		}
+
+		p := &hyperscan.Pattern{Expression: expr, Flags: compileFlags, Id: id}
+		if !p.IsValid() {
+			t.Skip()
+		}
+
+		if exts := fuzzExts(exts, n); len(exts) > 0 {
+			p.WithExt(exts...)
+		}
+
+		q, err := hyperscan.ParsePattern(p.String())
+		if err != nil {
+			t.Fatalf("parse `%s`, %v", p, err)
+		}
+
+		if q.Expression != p.Expression || q.Flags != p.Flags || q.Id != p.Id || q.String() != p.String() {
+			t.Fatalf("`%s` parsed as `%s`", p, q)
+		}
+
+		pExt, _ := p.Ext()
+		qExt, _ := q.Ext()
+
+		if !reflect.DeepEqual(pExt, qExt) {
+			t.Fatalf("`%s` parsed with the extensions %v", p, qExt)
+		}
+	})
+}
@@ -1,19 +1,21 @@
 package hyperscan
 
+// The patterns have neither anchors nor capturing groups,
+// so they could be used in any part of a pattern without shifting the submatches of the pattern.
 const (
 	// FloatNumber for matching floating point numbers.
 	FloatNumber = `(?:` +
-		`[-+]?[0-9]*\.?[0-9]+([eE][-+]?[0-9]+)?.)`
+		`[-+]?[0-9]*\.?[0-9]+(?:[eE][-+]?[0-9]+)?)`
 
 	// IPv4Address for matching IPv4 address.
 	IPv4Address = `(?:` +
-		`(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?\.){3}` +
+		`(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}` +
 		`(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?))`
 
 	// EmailAddress for matching email address.
 	EmailAddress = `(?:` +
-		`^[A-Za-z0-9](([_\.\-]?[a-zA-Z0-9]+)*)@` +
-		`([A-Za-z0-9]+)(([\.\-]?[a-zA-Z0-9]+)*)\.([A-Za-z]{2,})$)`
+		`[A-Za-z0-9](?:[_.\-]?[A-Za-z0-9]+)*@` +
+		`[A-Za-z0-9]+(?:[.\-]?[A-Za-z0-9]+)*\.[A-Za-z]{2,})`
 
 	// CreditCard for matching credit card number.
 	CreditCard = `(?:` +
@@ -1,6 +1,7 @@
 package hyperscan_test
 
 import (
+	"regexp"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -13,6 +14,13 @@ func TestFloatNumber(t *testing.T) {
 		db := hyperscan.MustCompile(hyperscan.FloatNumber)
 
 		So(db, ShouldNotBeNil)
+
+		defer db.Close()
+
+		Convey("It matches the whole number", func() {
+			So(regexp.MustCompile(hyperscan.FloatNumber).FindAllString("-1.5e3 or .25", -1), ShouldResemble,
+				[]string{"-1.5e3", ".25"})
+		})
 	})
 }
 
@@ -21,6 +29,15 @@ func TestIPv4Address(t *testing.T) {
 		db := hyperscan.MustCompile(hyperscan.IPv4Address)
 
 		So(db, ShouldNotBeNil)
+
+		defer db.Close()
+
+		Convey("It matches the dotted octets", func() {
+			So(db.(hyperscan.BlockDatabase).MatchString("from 192.168.1.1"), ShouldBeTrue)
+			So(db.(hyperscan.BlockDatabase).MatchString("from 1921.68.1"), ShouldBeFalse)
+			So(regexp.MustCompile(hyperscan.IPv4Address).FindString("from 192.168.1.254"), ShouldEqual,
+				"192.168.1.254")
+		})
 	})
 }
 
@@ -29,6 +46,14 @@ func TestEmailAddress(t *testing.T) {
 		db := hyperscan.MustCompile(hyperscan.EmailAddress)
 
 		So(db, ShouldNotBeNil)
+
+		defer db.Close()
+
+		Convey("It matches in the middle of text", func() {
+			So(db.(hyperscan.BlockDatabase).MatchString("mail to foo.bar@example.com now"), ShouldBeTrue)
+			So(regexp.MustCompile(hyperscan.EmailAddress).FindString("mail to foo.bar@example.com now"),
+				ShouldEqual, "foo.bar@example.com")
+		})
 	})
 }
 
@@ -38,4 +63,14 @@ func TestCreditCard(t *testing.T) {
 
 		So(db, ShouldNotBeNil)
 	})
+
+	Convey("Given the builtin patterns", t, func() {
+		Convey("They have no capturing groups", func() {
+			for _, expr := range []string{
+				hyperscan.FloatNumber, hyperscan.IPv4Address, hyperscan.EmailAddress, hyperscan.CreditCard,
+			} {
+				So(regexp.MustCompile(expr).NumSubexp(), ShouldEqual, 0)
+			}
+		})
+	})
 }
@@ -0,0 +1,351 @@
+package hyperscan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PatternSetVersion is the version of the pattern set format written by PatternSet.WriteTo.
+const PatternSetVersion = 1
+
+// PatternSet is a set of rules, which could be read and written in the JSON format.
+//
+//	{
+//	  "version": 1,
+//	  "rules": [
+//	    {
+//	      "id": 10042,
+//	      "name": "aws-secret-key",
+//	      "severity": "high",
+//	      "tags": ["aws", "secret"],
+//	      "expression": "aws_secret_access_key\\s*=\\s*\\S{40}",
+//	      "flags": "iL",
+//	      "ext": {"max_offset": 4096},
+//	      "tests": [{"input": "aws_secret_access_key = ...", "match": true}]
+//	    }
+//	  ]
+//	}
+//
+// The rules may share an ID, like the patterns of a database, which is reported by LintDuplicateID.
+type PatternSet struct {
+	Version int     `json:"version"`
+	Rules   []*Rule `json:"rules"`
+}
+
+// Rule is a pattern with its metadata.
+type Rule struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Severity    string       `json:"severity,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Owner       string       `json:"owner,omitempty"`
+	Expression  string       `json:"expression"`
+	Flags       CompileFlag  `json:"-"`
+	Ext         *ExprExt     `json:"ext,omitempty"`
+	Tests       []TestVector `json:"tests,omitempty"` // The test vectors run by PatternSet.RunTests.
+}
+
+// TestVector is an input which the rule should match or not.
+type TestVector struct {
+	Input string `json:"input"`
+	Match bool   `json:"match"`
+}
+
+// TestFailure is a test vector whose input is matched by the rule while it shouldn't, or the opposite.
+type TestFailure struct {
+	*Rule
+
+	// The index of the rule in the pattern set.
+	Index int
+
+	// The failed test vector.
+	Test TestVector
+}
+
+// String returns the rule and the input of the failed test vector.
+func (f *TestFailure) String() string {
+	if f.Test.Match {
+		return fmt.Sprintf("%s at index %d doesn't match `%s`", f.Rule, f.Index, f.Test.Input)
+	}
+
+	return fmt.Sprintf("%s at index %d matches `%s`", f.Rule, f.Index, f.Test.Input)
+}
+
+// jsonRule is the JSON form of Rule, which writes the flags as the string used by the line format.
+type jsonRule struct {
+	*ruleAlias
+	Flags string `json:"flags,omitempty"`
+}
+
+type ruleAlias Rule
+
+// MarshalJSON implements json.Marshaler.
+func (r *Rule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&jsonRule{(*ruleAlias)(r), r.Flags.String()}) //nolint: wrapcheck
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Rule) UnmarshalJSON(b []byte) error {
+	v := jsonRule{ruleAlias: (*ruleAlias)(r)}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	flags, err := ParseCompileFlag(v.Flags)
+	if err != nil {
+		return fmt.Errorf("rule %d flags `%s`, %w", r.ID, v.Flags, err)
+	}
+
+	r.Flags = flags
+
+	return nil
+}
+
+// Pattern returns the pattern of the rule.
+func (r *Rule) Pattern() *Pattern {
+	return &Pattern{Expression: r.Expression, Flags: r.Flags, Id: r.ID, ext: r.Ext.clone()}
+}
+
+// String returns the name and severity of the rule, or its ID if the rule has no name.
+func (r *Rule) String() string {
+	s := fmt.Sprintf("id=%d", r.ID)
+
+	if r.Name != "" {
+		s = fmt.Sprintf("rule `%s`", r.Name)
+	}
+
+	if r.Severity != "" {
+		s += " (" + r.Severity + ")"
+	}
+
+	return s
+}
+
+func (ext *ExprExt) clone() *ExprExt {
+	if ext == nil {
+		return nil
+	}
+
+	cloned := *ext
+
+	return &cloned
+}
+
+type jsonExprExt struct {
+	MinOffset       *uint64 `json:"min_offset,omitempty"`
+	MaxOffset       *uint64 `json:"max_offset,omitempty"`
+	MinLength       *uint64 `json:"min_length,omitempty"`
+	EditDistance    *uint32 `json:"edit_distance,omitempty"`
+	HammingDistance *uint32 `json:"hamming_distance,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, only the fields used by the compiler are written.
+func (ext *ExprExt) MarshalJSON() ([]byte, error) {
+	var v jsonExprExt
+
+	if (ext.Flags & ExtMinOffset) == ExtMinOffset {
+		v.MinOffset = &ext.MinOffset
+	}
+
+	if (ext.Flags & ExtMaxOffset) == ExtMaxOffset {
+		v.MaxOffset = &ext.MaxOffset
+	}
+
+	if (ext.Flags & ExtMinLength) == ExtMinLength {
+		v.MinLength = &ext.MinLength
+	}
+
+	if (ext.Flags & ExtEditDistance) == ExtEditDistance {
+		v.EditDistance = &ext.EditDistance
+	}
+
+	if (ext.Flags & ExtHammingDistance) == ExtHammingDistance {
+		v.HammingDistance = &ext.HammingDistance
+	}
+
+	return json.Marshal(&v) //nolint: wrapcheck
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the flags are set for the present fields.
+func (ext *ExprExt) UnmarshalJSON(b []byte) error {
+	var v jsonExprExt
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	*ext = ExprExt{}
+
+	if v.MinOffset != nil {
+		ext.With(MinOffset(*v.MinOffset))
+	}
+
+	if v.MaxOffset != nil {
+		ext.With(MaxOffset(*v.MaxOffset))
+	}
+
+	if v.MinLength != nil {
+		ext.With(MinLength(*v.MinLength))
+	}
+
+	if v.EditDistance != nil {
+		ext.With(EditDistance(*v.EditDistance))
+	}
+
+	if v.HammingDistance != nil {
+		ext.With(HammingDistance(*v.HammingDistance))
+	}
+
+	return nil
+}
+
+// NewPatternSet returns a pattern set of the patterns without metadata.
+func NewPatternSet(patterns Patterns) *PatternSet {
+	s := &PatternSet{Version: PatternSetVersion, Rules: make([]*Rule, len(patterns))}
+
+	for i, p := range patterns {
+		s.Rules[i] = &Rule{ID: p.Id, Expression: p.Expression, Flags: p.Flags, Ext: p.ext.clone()}
+	}
+
+	return s
+}
+
+// ReadPatternSet reads a pattern set in the JSON format.
+func ReadPatternSet(r io.Reader) (*PatternSet, error) {
+	var s PatternSet
+
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("decode pattern set, %w", err)
+	}
+
+	if s.Version > PatternSetVersion {
+		return nil, fmt.Errorf("pattern set version %d, %w", s.Version, ErrInvalid)
+	}
+
+	return &s, nil
+}
+
+// WriteTo writes the pattern set in the JSON format.
+func (s *PatternSet) WriteTo(w io.Writer) (int64, error) {
+	set := *s
+
+	if set.Version == 0 {
+		set.Version = PatternSetVersion
+	}
+
+	b, err := json.MarshalIndent(&set, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("encode pattern set, %w", err)
+	}
+
+	n, err := w.Write(append(b, '\n'))
+	if err != nil {
+		return int64(n), fmt.Errorf("write pattern set, %w", err)
+	}
+
+	return int64(n), nil
+}
+
+// Patterns returns the patterns of the rules.
+func (s *PatternSet) Patterns() Patterns {
+	patterns := make(Patterns, len(s.Rules))
+
+	for i, r := range s.Rules {
+		patterns[i] = r.Pattern()
+	}
+
+	return patterns
+}
+
+// Index returns a lookup from the match ID to the rule, the first one if several rules share the ID.
+func (s *PatternSet) Index() RuleIndex {
+	index := make(RuleIndex, len(s.Rules))
+
+	for _, r := range s.Rules {
+		if _, ok := index[uint(r.ID)]; r.ID >= 0 && !ok {
+			index[uint(r.ID)] = r
+		}
+	}
+
+	return index
+}
+
+// RunTests compiles the rules in block mode, scans the input of each test vector,
+// and returns the test vectors whose input is matched by their rule while it shouldn't, or the opposite.
+//
+// Each rule is identified by its index, so a match of another rule with the same ID doesn't pass the test.
+func (s *PatternSet) RunTests() ([]*TestFailure, error) {
+	patterns := make(Patterns, 0, len(s.Rules))
+
+	for i, r := range s.Rules {
+		if len(r.Tests) > 0 {
+			p := r.Pattern()
+			p.Id = i
+			patterns = append(patterns, p)
+		}
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	b := DatabaseBuilder{Patterns: patterns}
+
+	db, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	defer db.Close()
+
+	scratch, err := NewScratch(db)
+	if err != nil {
+		return nil, err
+	}
+
+	defer scratch.Free() //nolint: errcheck
+
+	var (
+		failures []*TestFailure
+		matched  bitmap
+	)
+
+	handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+		matched.set(id)
+
+		return nil
+	}
+
+	for _, p := range patterns {
+		r := s.Rules[p.Id]
+
+		for _, test := range r.Tests {
+			matched = bitmap{}
+
+			if err := db.(BlockDatabase).Scan([]byte(test.Input), scratch, handler, nil); err != nil {
+				return nil, fmt.Errorf("scan test of rule %d, %w", r.ID, err)
+			}
+
+			if matched.has(uint(p.Id)) != test.Match {
+				failures = append(failures, &TestFailure{r, p.Id, test})
+			}
+		}
+	}
+
+	return failures, nil
+}
+
+// RuleIndex is a lookup from the match ID to the rule.
+type RuleIndex map[uint]*Rule
+
+// Describe returns the name and severity of the rule with the match ID, or the ID if the rule is unknown.
+func (idx RuleIndex) Describe(id uint) string {
+	if r, ok := idx[id]; ok {
+		return r.String()
+	}
+
+	return fmt.Sprintf("id=%d", id)
+}
@@ -0,0 +1,148 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestPatternSet(t *testing.T) {
+	Convey("Given a pattern set in the JSON format", t, func() {
+		const data = `{
+	"version": 1,
+	"rules": [
+		{
+			"id": 10042,
+			"name": "aws-secret-key",
+			"description": "AWS secret access key",
+			"severity": "high",
+			"tags": ["aws", "secret"],
+			"owner": "security",
+			"expression": "aws_secret_access_key\\s*=\\s*\\S+",
+			"flags": "iL",
+			"ext": {"max_offset": 4096, "edit_distance": 1},
+			"tests": [{"input": "AWS_SECRET_ACCESS_KEY = abc", "match": true}, {"input": "nothing"}]
+		},
+		{"id": 7, "expression": "foo"}
+	]
+}`
+
+		s, err := hyperscan.ReadPatternSet(strings.NewReader(data))
+		So(err, ShouldBeNil)
+		So(s.Rules, ShouldHaveLength, 2)
+
+		Convey("Then the rules carry their metadata", func() {
+			r := s.Rules[0]
+
+			So(r.Name, ShouldEqual, "aws-secret-key")
+			So(r.Tags, ShouldResemble, []string{"aws", "secret"})
+			So(r.Flags, ShouldEqual, hyperscan.Caseless|hyperscan.SomLeftMost)
+			So(r.Ext.String(), ShouldEqual, "{max_offset=4096,edit_distance=1}")
+			So(r.Tests, ShouldResemble, []hyperscan.TestVector{{"AWS_SECRET_ACCESS_KEY = abc", true}, {"nothing", false}})
+		})
+
+		Convey("Then the patterns could be compiled", func() {
+			patterns := s.Patterns()
+
+			So(patterns[0].String(), ShouldEqual, `10042:/aws_secret_access_key\s*=\s*\S+/Li{max_offset=4096,edit_distance=1}`)
+			So(patterns[1].String(), ShouldEqual, `7:/foo/`)
+
+			db, err := patterns[1:].Build(hyperscan.BlockMode)
+			So(err, ShouldBeNil)
+			So(db.Close(), ShouldBeNil)
+		})
+
+		Convey("Then the rules could be looked up by the match ID", func() {
+			index := s.Index()
+
+			So(index.Describe(10042), ShouldEqual, "rule `aws-secret-key` (high)")
+			So(index.Describe(7), ShouldEqual, "id=7")
+			So(index.Describe(8), ShouldEqual, "id=8")
+		})
+
+		Convey("Then the test vectors of the rules pass", func() {
+			failures, err := s.RunTests()
+
+			So(err, ShouldBeNil)
+			So(failures, ShouldBeEmpty)
+		})
+
+		Convey("Then the pattern set could be written and read back", func() {
+			var buf bytes.Buffer
+
+			n, err := s.WriteTo(&buf)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, buf.Len())
+
+			read, err := hyperscan.ReadPatternSet(&buf)
+			So(err, ShouldBeNil)
+			So(read, ShouldResemble, s)
+		})
+	})
+
+	Convey("Given some patterns", t, func() {
+		patterns := hyperscan.Patterns{
+			hyperscan.NewPattern("foo", hyperscan.DotAll, hyperscan.MinLength(3)),
+			{Expression: "bar", Id: 2},
+		}
+
+		s := hyperscan.NewPatternSet(patterns)
+
+		var buf bytes.Buffer
+
+		_, err := s.WriteTo(&buf)
+		So(err, ShouldBeNil)
+
+		read, err := hyperscan.ReadPatternSet(&buf)
+		So(err, ShouldBeNil)
+
+		So(read.Patterns(), ShouldHaveLength, 2)
+		So(read.Patterns()[0].String(), ShouldEqual, patterns[0].String())
+		So(read.Patterns()[1].String(), ShouldEqual, patterns[1].String())
+	})
+
+	Convey("Given a pattern set with the rules sharing an ID", t, func() {
+		const data = `{"rules": [
+			{"id": 1, "name": "foo", "expression": "foo", "tests": [{"input": "foo", "match": true}, {"input": "bar"}]},
+			{"id": 1, "name": "bar", "expression": "bar", "tests": [{"input": "foo", "match": true}]},
+			{"id": 2, "expression": "baz"}
+		]}`
+
+		s, err := hyperscan.ReadPatternSet(strings.NewReader(data))
+		So(err, ShouldBeNil)
+		So(s.Rules, ShouldHaveLength, 3)
+
+		Convey("Then the first rule of the ID is looked up", func() {
+			So(s.Index().Describe(1), ShouldEqual, "rule `foo`")
+		})
+
+		Convey("When run the test vectors", func() {
+			failures, err := s.RunTests()
+			So(err, ShouldBeNil)
+
+			Convey("Then the failed test vectors are reported with their rule", func() {
+				So(failures, ShouldHaveLength, 1)
+				So(failures[0].Rule, ShouldEqual, s.Rules[1])
+				So(failures[0].Index, ShouldEqual, 1)
+				So(failures[0].String(), ShouldEqual, "rule `bar` at index 1 doesn't match `foo`")
+			})
+		})
+	})
+
+	Convey("Given a bad pattern set", t, func() {
+		for _, data := range []string{
+			`{"version": 2, "rules": []}`,
+			`{"rules": [{"id": 1, "expression": "foo", "flags": "Z"}]}`,
+			`{"rules": [`,
+		} {
+			_, err := hyperscan.ReadPatternSet(strings.NewReader(data))
+
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -17,3 +17,7 @@ const (
 	// IcelakeServer indicates that the compiled database should be tuned for the Icelake Server microarchitecture.
 	IcelakeServer TuneFlag = hs.IcelakeServer
 )
+
+func init() {
+	cpuFeatures["AVX512VBMI"] = AVX512VBMI
+}
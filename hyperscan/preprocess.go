@@ -0,0 +1,240 @@
+package hyperscan
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// BuiltinMacros are the named building blocks available to all the preprocessors.
+var BuiltinMacros = map[string]string{
+	"FLOAT":       FloatNumber,
+	"IPV4":        IPv4Address,
+	"EMAIL":       EmailAddress,
+	"CREDIT_CARD": CreditCard,
+}
+
+var (
+	macroRef  = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	macroName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+/*
+Preprocessor parses the pattern files with the directives, which are expanded before parsing each line as a pattern.
+
+	%include <path>			include the patterns of another file, relative to the including file
+	%define <name> <expression>	define a named building block for the following lines
+
+A `${NAME}` reference in a line is expanded to the expression of the macro, wrapped in a non-capturing group.
+The definitions are scoped to the parsed file and the files it includes after them, they don't change Macros.
+For example,
+
+	%define PORT \d{1,5}
+	1:/${IPV4}:${PORT}/
+
+The patterns carry the position of their original line, in the including or included file.
+*/
+type Preprocessor struct {
+	// Macros are the named building blocks, which are seeded with BuiltinMacros.
+	Macros map[string]string
+
+	// Open opens an included file, os.Open is used if nil.
+	Open func(name string) (io.ReadCloser, error)
+}
+
+// NewPreprocessor returns a preprocessor with the builtin macros.
+func NewPreprocessor() *Preprocessor {
+	pp := &Preprocessor{Macros: make(map[string]string, len(BuiltinMacros))}
+
+	for name, expr := range BuiltinMacros {
+		pp.Macros[name] = expr
+	}
+
+	return pp
+}
+
+// ParseFile parses the patterns of the named file and the included ones.
+//
+// It continues past the bad lines, and returns the parsed patterns and ParseErrors listing all the bad lines.
+func (pp *Preprocessor) ParseFile(filename string) (Patterns, error) {
+	f, err := pp.open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return pp.ParsePatterns(filename, f)
+}
+
+// ParsePatterns parses the patterns of the named file read from r and the included ones.
+//
+// It continues past the bad lines, and returns the parsed patterns and ParseErrors listing all the bad lines.
+func (pp *Preprocessor) ParsePatterns(filename string, r io.Reader) (Patterns, error) {
+	patterns, errs, err := pp.parse(filename, r, nil, pp.Macros)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return patterns, errs
+	}
+
+	return patterns, nil
+}
+
+func (pp *Preprocessor) open(name string) (io.ReadCloser, error) {
+	if pp.Open != nil {
+		return pp.Open(name)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open pattern file, %w", err)
+	}
+
+	return f, nil
+}
+
+// parse parses the lines of the file, the stack holds the including files to detect the cycles.
+//
+// The file defines its macros in a copy of the macros of the including file.
+func (pp *Preprocessor) parse(filename string, r io.Reader, stack []string,
+	inherited map[string]string,
+) (patterns Patterns, errs ParseErrors, err error) {
+	macros := make(macroScope, len(inherited))
+
+	for name, expr := range inherited {
+		macros[name] = expr
+	}
+
+	stack = append(stack, filepath.Clean(filename))
+
+	err = scanLines(filename, r, func(pos Position, line string) bool {
+		switch directive, arg := splitDirective(line); directive {
+		case "%include":
+			included, includedErrs, err := pp.include(pos, arg, stack, macros)
+			if err != nil {
+				errs = append(errs, &ParseError{pos, err})
+			}
+
+			patterns = append(patterns, included...)
+			errs = append(errs, includedErrs...)
+
+		case "%define":
+			if err := macros.define(arg); err != nil {
+				errs = append(errs, &ParseError{pos, err})
+			}
+
+		case "":
+			line, err := macros.expand(line)
+			if err != nil {
+				errs = append(errs, &ParseError{pos, err})
+
+				break
+			}
+
+			p, err := ParsePattern(line)
+			if err != nil {
+				errs = append(errs, &ParseError{pos, err})
+
+				break
+			}
+
+			p.Pos = pos
+			patterns = append(patterns, p)
+
+		default:
+			errs = append(errs, &ParseError{pos, fmt.Errorf("directive `%s`, %w", directive, ErrInvalid)})
+		}
+
+		return true
+	})
+
+	return
+}
+
+// splitDirective splits a directive line into the directive and its argument.
+func splitDirective(line string) (directive, arg string) {
+	if !strings.HasPrefix(line, "%") {
+		return "", line
+	}
+
+	if i := strings.IndexAny(line, " \t"); i > 0 {
+		return line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	return line, ""
+}
+
+func (pp *Preprocessor) include(pos Position, name string, stack []string,
+	macros macroScope,
+) (Patterns, ParseErrors, error) {
+	if name == "" {
+		return nil, nil, fmt.Errorf("include without file, %w", ErrInvalid)
+	}
+
+	if !filepath.IsAbs(name) && pos.Filename != "" {
+		name = filepath.Join(filepath.Dir(pos.Filename), name)
+	}
+
+	for _, including := range stack {
+		if including == filepath.Clean(name) {
+			return nil, nil, fmt.Errorf("include cycle `%s`, %w", strings.Join(append(stack, name), " -> "), ErrInvalid)
+		}
+	}
+
+	f, err := pp.open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer f.Close()
+
+	return pp.parse(name, f, stack, macros)
+}
+
+// macroScope holds the macros defined for a file.
+type macroScope map[string]string
+
+func (macros macroScope) define(arg string) error {
+	name, expr := arg, ""
+
+	if i := strings.IndexAny(arg, " \t"); i > 0 {
+		name, expr = arg[:i], strings.TrimSpace(arg[i+1:])
+	}
+
+	if !macroName.MatchString(name) || expr == "" {
+		return fmt.Errorf("macro definition `%s`, %w", arg, ErrInvalid)
+	}
+
+	expr, err := macros.expand(expr)
+	if err != nil {
+		return err
+	}
+
+	macros[name] = expr
+
+	return nil
+}
+
+// expand expands the macro references in s, each wrapped in a non-capturing group.
+func (macros macroScope) expand(s string) (string, error) {
+	var err error
+
+	expanded := macroRef.ReplaceAllStringFunc(s, func(ref string) string {
+		name := ref[2 : len(ref)-1]
+
+		expr, ok := macros[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined macro `%s`, %w", name, ErrInvalid)
+		}
+
+		return "(?:" + expr + ")"
+	})
+
+	return expanded, err
+}
@@ -0,0 +1,149 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestPreprocessor(t *testing.T) {
+	Convey("Given a preprocessor with some pattern files", t, func() {
+		files := map[string]string{
+			"rules/main.txt": `# main rules
+%define PORT \d{1,5}
+1:/${IPV4}:${PORT}/
+%include common.txt
+2:/bar/i
+`,
+			"rules/common.txt": `%define HOST [a-z]+\.example\.com
+3:/${HOST}:${PORT}/
+`,
+			"rules/leak.txt": `%define PORT \d+
+%include common.txt
+6:/${HOST}/
+`,
+			"rules/cycle.txt": `%include cycle.txt
+`,
+			"rules/bad.txt": `%define
+%unknown
+4:/${MISSING}/
+%include missing.txt
+5:/bar/
+`,
+		}
+
+		pp := hyperscan.NewPreprocessor()
+		pp.Open = func(name string) (io.ReadCloser, error) {
+			if s, ok := files[filepath.ToSlash(name)]; ok {
+				return io.NopCloser(strings.NewReader(s)), nil
+			}
+
+			return nil, os.ErrNotExist
+		}
+
+		Convey("When parse a file with includes and macros", func() {
+			patterns, err := pp.ParseFile("rules/main.txt")
+			So(err, ShouldBeNil)
+			So(patterns, ShouldHaveLength, 3)
+
+			Convey("Then the macros are expanded", func() {
+				So(patterns[0].Expression, ShouldEqual, "(?:"+hyperscan.BuiltinMacros["IPV4"]+`):(?:\d{1,5})`)
+				So(patterns[1].Expression, ShouldEqual, `(?:[a-z]+\.example\.com):(?:\d{1,5})`)
+			})
+
+			Convey("Then the definitions don't change the macros of the preprocessor", func() {
+				So(pp.Macros, ShouldNotContainKey, "PORT")
+				So(pp.Macros, ShouldNotContainKey, "HOST")
+			})
+
+			Convey("Then the patterns are traceable to the original lines", func() {
+				So(patterns[0].Pos.String(), ShouldEqual, "rules/main.txt:3:1")
+				So(patterns[1].Pos.String(), ShouldEqual, filepath.Join("rules", "common.txt")+":2:1")
+				So(patterns[2].Pos.String(), ShouldEqual, "rules/main.txt:5:1")
+			})
+
+			Convey("Then the patterns could be compiled", func() {
+				db, err := patterns.Build(hyperscan.BlockMode)
+				So(err, ShouldBeNil)
+
+				defer db.Close()
+
+				So(db.(hyperscan.BlockDatabase).MatchString("connect to 192.168.1.1:8080"), ShouldBeTrue)
+			})
+		})
+
+		Convey("When parse a file using a macro defined by an included file", func() {
+			patterns, err := pp.ParseFile("rules/leak.txt")
+
+			So(patterns, ShouldHaveLength, 1)
+
+			var errs hyperscan.ParseErrors
+
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(errs, ShouldHaveLength, 1)
+			So(errs[0].Line, ShouldEqual, 3)
+			So(errs[0].Error(), ShouldContainSubstring, "undefined macro `HOST`")
+		})
+
+		Convey("When parse a file including itself", func() {
+			_, err := pp.ParseFile("rules/cycle.txt")
+
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "include cycle")
+		})
+
+		Convey("When parse a file with bad lines", func() {
+			patterns, err := pp.ParseFile("rules/bad.txt")
+
+			So(patterns, ShouldHaveLength, 1)
+
+			var errs hyperscan.ParseErrors
+
+			So(errors.As(err, &errs), ShouldBeTrue)
+			So(errs, ShouldHaveLength, 4)
+
+			for i, e := range errs {
+				So(e.Line, ShouldEqual, i+1)
+				So(errors.Is(e, hyperscan.ErrInvalid) || errors.Is(e, os.ErrNotExist), ShouldBeTrue)
+			}
+		})
+	})
+
+	Convey("Given the builtin macros", t, func() {
+		Convey("They have neither anchors nor capturing groups", func() {
+			for name, expr := range hyperscan.BuiltinMacros {
+				So(regexp.MustCompile(expr).NumSubexp(), ShouldEqual, 0)
+				So(expr, ShouldNotContainSubstring, "^")
+				So(expr, ShouldNotEndWith, "$")
+				So(name, ShouldNotBeEmpty)
+			}
+		})
+
+		Convey("When use them in the middle of a pattern", func() {
+			pp := hyperscan.NewPreprocessor()
+			pp.Open = func(name string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader("1:/from (${EMAIL}) at ${IPV4}, ${FLOAT}s/\n")), nil
+			}
+
+			patterns, err := pp.ParseFile("rules.txt")
+			So(err, ShouldBeNil)
+
+			re := regexp.MustCompile(patterns[0].Expression)
+
+			Convey("Then the submatches of the pattern are kept", func() {
+				So(re.FindStringSubmatch("mail from a.b@example.com at 10.0.0.255, 1.5s"), ShouldResemble,
+					[]string{"from a.b@example.com at 10.0.0.255, 1.5s", "a.b@example.com"})
+				So(re.MatchString("from a@b.com at 256.1.1.1, 1s"), ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,383 @@
+package hyperscan
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// ProfileMetric is the metric used to rank the patterns of a profile.
+type ProfileMetric int
+
+const (
+	// RankBySize ranks the patterns by the size of the database.
+	RankBySize ProfileMetric = iota
+	// RankByStreamSize ranks the patterns by the size of the stream state.
+	RankByStreamSize
+	// RankByScratchSize ranks the patterns by the size of the scratch space.
+	RankByScratchSize
+	// RankByScanTime ranks the patterns by the time to scan the corpus.
+	RankByScanTime
+)
+
+// ProfileOptions are the options of Patterns.Profile.
+type ProfileOptions struct {
+	// The mode of the compiled databases, BlockMode if zero.
+	Mode ModeFlag
+
+	// The target platform of the compiled databases.
+	Platform Platform
+
+	// The corpus scanned to measure the throughput.
+	//
+	// Each item is scanned as a block in block mode, as a stream in streaming mode,
+	// and the whole corpus is scanned as a vector in vectored mode.
+	Corpus [][]byte
+
+	// The number of times the corpus is scanned, 1 if zero.
+	Repeat int
+
+	// The number of patterns left out together in the leave-one-out compiles,
+	// the square root of the number of patterns if zero, so the set is compiled about 2√N times instead of N times.
+	// The patterns of a group share the saved cost, set it to 1 to measure each pattern alone.
+	// No leave-one-out compile is done if negative.
+	GroupSize int
+
+	// The metric used to rank the patterns.
+	RankBy ProfileMetric
+}
+
+// Cost is the cost of a database measured by Patterns.Profile.
+type Cost struct {
+	Size        int           // The size of the database in bytes.
+	StreamSize  int           // The size of the stream state in bytes, in streaming mode.
+	ScratchSize int           // The size of the scratch space in bytes.
+	ScanTime    time.Duration // The time to scan the corpus once.
+	Scanned     int           // The number of bytes of the corpus.
+	Matches     int           // The number of matches in the corpus.
+}
+
+// Throughput returns the scanned bytes per second, or 0 if the corpus is empty.
+func (c *Cost) Throughput() float64 {
+	if c.ScanTime <= 0 {
+		return 0
+	}
+
+	return float64(c.Scanned) / c.ScanTime.Seconds()
+}
+
+func (c *Cost) metric(m ProfileMetric) int64 {
+	switch m {
+	case RankByStreamSize:
+		return int64(c.StreamSize)
+	case RankByScratchSize:
+		return int64(c.ScratchSize)
+	case RankByScanTime:
+		return int64(c.ScanTime)
+	default:
+		return int64(c.Size)
+	}
+}
+
+func (c *Cost) sub(other *Cost) Cost {
+	return Cost{
+		Size:        c.Size - other.Size,
+		StreamSize:  c.StreamSize - other.StreamSize,
+		ScratchSize: c.ScratchSize - other.ScratchSize,
+		ScanTime:    c.ScanTime - other.ScanTime,
+		Scanned:     c.Scanned,
+		Matches:     c.Matches - other.Matches,
+	}
+}
+
+// PatternCost is the cost of a pattern measured by Patterns.Profile.
+type PatternCost struct {
+	*Pattern
+
+	// The index of the pattern in the pattern set.
+	Index int
+
+	// The cost of the pattern compiled in isolation.
+	Isolated Cost
+
+	// The cost saved by leaving out the group of the pattern, which may be negative for the noisy scan time.
+	Saving Cost
+
+	// The error of the pattern compiled in isolation.
+	Err error
+}
+
+// ProfileReport lists the costs of the patterns, the most expensive first.
+type ProfileReport struct {
+	// The cost of the whole pattern set.
+	Total Cost
+
+	// The costs of the patterns, the most expensive first.
+	Patterns []*PatternCost
+}
+
+// Top returns the n most expensive patterns.
+func (r *ProfileReport) Top(n int) []*PatternCost {
+	if n < 0 || n > len(r.Patterns) {
+		n = len(r.Patterns)
+	}
+
+	return r.Patterns[:n]
+}
+
+// WriteTo writes the report as a table.
+func (r *ProfileReport) WriteTo(w io.Writer) (int64, error) {
+	cw := &countWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	fmt.Fprintln(tw, "rank\tid\tsize\tstream\tscratch\tscan\tMB/s\tsaved size\tsaved stream\tsaved scan\t")
+
+	for i, p := range r.Patterns {
+		if p.Err != nil {
+			fmt.Fprintf(tw, "%d\t%d\t-\t-\t-\t-\t-\t-\t-\t-\t %s, %s\n", i+1, p.Id, p, p.Err)
+
+			continue
+		}
+
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\t%.1f\t%d\t%d\t%s\t %s\n", i+1, p.Id,
+			p.Isolated.Size, p.Isolated.StreamSize, p.Isolated.ScratchSize, p.Isolated.ScanTime,
+			p.Isolated.Throughput()/(1<<20), p.Saving.Size, p.Saving.StreamSize, p.Saving.ScanTime, p)
+	}
+
+	fmt.Fprintf(tw, "\ttotal\t%d\t%d\t%d\t%s\t%.1f\t\t\t\t\n",
+		r.Total.Size, r.Total.StreamSize, r.Total.ScratchSize, r.Total.ScanTime, r.Total.Throughput()/(1<<20))
+
+	if err := tw.Flush(); err != nil {
+		return cw.n, fmt.Errorf("write profile, %w", err)
+	}
+
+	return cw.n, nil
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+
+	return n, err //nolint: wrapcheck
+}
+
+// Profile measures the cost of each pattern compiled in isolation,
+// and the cost saved by leaving out each group of patterns from the whole set,
+// then ranks the patterns by the saved cost, or by the isolated cost if no leave-one-out compile is done.
+//
+// A pattern failing to compile in isolation is reported with its error and ranked first,
+// the total and the saved costs are measured with the other patterns.
+//
+// The patterns are compiled and scanned sequentially, so the scan time isn't disturbed by the other compiles.
+func (p Patterns) Profile(opts *ProfileOptions) (*ProfileReport, error) {
+	if len(p) == 0 {
+		return nil, ErrInvalid
+	}
+
+	if opts == nil {
+		opts = &ProfileOptions{}
+	}
+
+	report := &ProfileReport{Patterns: make([]*PatternCost, len(p))}
+
+	var compiled []*PatternCost
+
+	for i, pattern := range p {
+		cost := &PatternCost{Pattern: pattern, Index: i}
+		cost.Isolated, cost.Err = opts.measure(Patterns{pattern})
+		report.Patterns[i] = cost
+
+		if cost.Err == nil {
+			compiled = append(compiled, cost)
+		}
+	}
+
+	if len(compiled) > 0 {
+		total, err := opts.measure(patternsOf(compiled))
+		if err != nil {
+			return nil, err
+		}
+
+		report.Total = total
+
+		if opts.GroupSize >= 0 {
+			if err := opts.leaveOut(compiled, total); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	rank := func(c *PatternCost) (int64, int64) {
+		if opts.GroupSize >= 0 {
+			return c.Saving.metric(opts.RankBy), c.Isolated.metric(opts.RankBy)
+		}
+
+		return c.Isolated.metric(opts.RankBy), 0
+	}
+
+	sort.SliceStable(report.Patterns, func(i, j int) bool {
+		a, b := report.Patterns[i], report.Patterns[j]
+
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err != nil
+		}
+
+		a1, a2 := rank(a)
+		b1, b2 := rank(b)
+
+		return a1 > b1 || (a1 == b1 && a2 > b2)
+	})
+
+	return report, nil
+}
+
+// leaveOut measures the cost saved by leaving out each group of the compiled patterns from the whole set.
+func (opts *ProfileOptions) leaveOut(compiled []*PatternCost, total Cost) error {
+	size := opts.GroupSize
+	if size == 0 {
+		size = int(math.Ceil(math.Sqrt(float64(len(compiled)))))
+	}
+
+	for start := 0; start < len(compiled); start += size {
+		end := start + size
+		if end > len(compiled) {
+			end = len(compiled)
+		}
+
+		saving := total
+
+		rest := append(append([]*PatternCost(nil), compiled[:start]...), compiled[end:]...)
+
+		if len(rest) > 0 {
+			cost, err := opts.measure(patternsOf(rest))
+			if err != nil {
+				return fmt.Errorf("leave out patterns %d to %d, %w", compiled[start].Index, compiled[end-1].Index, err)
+			}
+
+			saving = total.sub(&cost)
+		}
+
+		for _, c := range compiled[start:end] {
+			c.Saving = saving
+		}
+	}
+
+	return nil
+}
+
+func patternsOf(costs []*PatternCost) Patterns {
+	p := make(Patterns, len(costs))
+
+	for i, c := range costs {
+		p[i] = c.Pattern
+	}
+
+	return p
+}
+
+// measure compiles the patterns and scans the corpus.
+func (opts *ProfileOptions) measure(p Patterns) (cost Cost, err error) {
+	b := DatabaseBuilder{Patterns: p, Mode: opts.Mode, Platform: opts.Platform}
+
+	db, err := b.Build()
+	if err != nil {
+		return cost, err
+	}
+
+	defer db.Close()
+
+	if cost.Size, err = db.Size(); err != nil {
+		return cost, err //nolint: wrapcheck
+	}
+
+	if sdb, ok := db.(StreamDatabase); ok {
+		if cost.StreamSize, err = sdb.StreamSize(); err != nil {
+			return cost, err //nolint: wrapcheck
+		}
+	}
+
+	s, err := NewScratch(db)
+	if err != nil {
+		return cost, err
+	}
+
+	defer s.Free() //nolint: errcheck
+
+	if cost.ScratchSize, err = s.Size(); err != nil {
+		return cost, err //nolint: wrapcheck
+	}
+
+	if len(opts.Corpus) == 0 {
+		return cost, nil
+	}
+
+	repeat := opts.Repeat
+	if repeat <= 0 {
+		repeat = 1
+	}
+
+	handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+		cost.Matches++
+
+		return nil
+	}
+
+	start := time.Now()
+
+	for i := 0; i < repeat; i++ {
+		if err = scanCorpus(db, opts.Corpus, s, handler); err != nil {
+			return cost, err
+		}
+	}
+
+	cost.ScanTime = time.Since(start) / time.Duration(repeat)
+	cost.Matches /= repeat
+
+	for _, data := range opts.Corpus {
+		cost.Scanned += len(data)
+	}
+
+	return cost, nil
+}
+
+func scanCorpus(db Database, corpus [][]byte, s *Scratch, handler MatchHandler) error {
+	switch db := db.(type) {
+	case StreamDatabase:
+		for _, data := range corpus {
+			st, err := db.Open(0, s, handler, nil)
+			if err != nil {
+				return err //nolint: wrapcheck
+			}
+
+			if err = st.Scan(data); err != nil {
+				_ = st.Close()
+
+				return err //nolint: wrapcheck
+			}
+
+			if err = st.Close(); err != nil {
+				return err //nolint: wrapcheck
+			}
+		}
+
+	case VectoredDatabase:
+		return db.Scan(corpus, s, handler, nil) //nolint: wrapcheck
+
+	case BlockDatabase:
+		for _, data := range corpus {
+			if err := db.Scan(data, s, handler, nil); err != nil {
+				return err //nolint: wrapcheck
+			}
+		}
+	}
+
+	return nil
+}
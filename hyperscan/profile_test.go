@@ -0,0 +1,147 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+func TestProfile(t *testing.T) {
+	Convey("Given a pattern set and a corpus", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Id: 1},
+			{Expression: `ba[rz]`, Id: 2},
+			{Expression: `\d+`, Id: 3},
+		}
+		corpus := [][]byte{[]byte("foo bar 123"), []byte("baz 45")}
+
+		Convey("When profile the patterns", func() {
+			report, err := patterns.Profile(&hyperscan.ProfileOptions{Corpus: corpus, Repeat: 2, GroupSize: 1})
+
+			So(err, ShouldBeNil)
+			So(report.Total.Size, ShouldBeGreaterThan, 0)
+			So(report.Total.ScratchSize, ShouldBeGreaterThan, 0)
+			So(report.Total.Scanned, ShouldEqual, 17)
+			So(report.Patterns, ShouldHaveLength, 3)
+			So(report.Top(1), ShouldHaveLength, 1)
+
+			Convey("Then each pattern is measured in isolation", func() {
+				for _, p := range report.Patterns {
+					So(p.Err, ShouldBeNil)
+					So(p.Isolated.Size, ShouldBeGreaterThan, 0)
+					So(p.Isolated.Size, ShouldBeLessThan, report.Total.Size)
+					So(p.Isolated.Scanned, ShouldEqual, 17)
+					So(p.Saving.Size, ShouldBeGreaterThan, 0)
+				}
+			})
+
+			Convey("Then the matches are counted", func() {
+				matches := 0
+
+				for _, p := range report.Patterns {
+					matches += p.Isolated.Matches
+
+					So(p.Saving.Matches, ShouldEqual, p.Isolated.Matches)
+				}
+
+				So(report.Total.Matches, ShouldEqual, matches)
+			})
+
+			Convey("Then the patterns are ranked by the saved size", func() {
+				for i := 1; i < len(report.Patterns); i++ {
+					So(report.Patterns[i].Saving.Size, ShouldBeLessThanOrEqualTo, report.Patterns[i-1].Saving.Size)
+				}
+			})
+
+			Convey("Then the report is written as a table", func() {
+				var buf bytes.Buffer
+
+				n, err := report.WriteTo(&buf)
+
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, buf.Len())
+				So(buf.String(), ShouldContainSubstring, "saved size")
+				So(buf.String(), ShouldContainSubstring, "total")
+				So(buf.String(), ShouldContainSubstring, "2:/ba[rz]/")
+			})
+		})
+
+		Convey("When profile the patterns with the default group size", func() {
+			report, err := patterns.Profile(nil)
+
+			So(err, ShouldBeNil)
+
+			savings := make(map[int]hyperscan.Cost)
+
+			for _, p := range report.Patterns {
+				savings[p.Index] = p.Saving
+			}
+
+			Convey("Then the patterns are left out in groups of the square root of their number", func() {
+				So(savings[0], ShouldResemble, savings[1])
+				So(savings[2], ShouldNotResemble, savings[0])
+			})
+		})
+
+		Convey("When profile the patterns in groups for the streaming mode", func() {
+			report, err := patterns.Profile(&hyperscan.ProfileOptions{
+				Mode: hyperscan.StreamMode, Corpus: corpus, GroupSize: 2, RankBy: hyperscan.RankByStreamSize,
+			})
+
+			So(err, ShouldBeNil)
+			So(report.Total.StreamSize, ShouldBeGreaterThan, 0)
+
+			savings := make(map[int]hyperscan.Cost)
+
+			for _, p := range report.Patterns {
+				savings[p.Index] = p.Saving
+			}
+
+			So(savings[0], ShouldResemble, savings[1])
+		})
+
+		Convey("When profile the patterns without leave-one-out compiles", func() {
+			report, err := patterns.Profile(&hyperscan.ProfileOptions{GroupSize: -1})
+
+			So(err, ShouldBeNil)
+
+			for _, p := range report.Patterns {
+				So(p.Saving, ShouldResemble, hyperscan.Cost{})
+				So(p.Isolated.ScanTime, ShouldEqual, 0)
+			}
+		})
+
+		Convey("When profile the patterns with a broken one", func() {
+			broken := append(append(hyperscan.Patterns(nil), patterns...), &hyperscan.Pattern{Expression: `a(`, Id: 42})
+
+			report, err := broken.Profile(nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then the broken pattern is reported with its error", func() {
+				So(report.Patterns[0].Id, ShouldEqual, 42)
+				So(report.Patterns[0].Err, ShouldNotBeNil)
+
+				var buf bytes.Buffer
+
+				_, err := report.WriteTo(&buf)
+				So(err, ShouldBeNil)
+				So(buf.String(), ShouldContainSubstring, "a(")
+			})
+
+			Convey("Then the other patterns are measured without it", func() {
+				expected, err := patterns.Profile(&hyperscan.ProfileOptions{GroupSize: -1})
+				So(err, ShouldBeNil)
+
+				So(report.Total.Size, ShouldEqual, expected.Total.Size)
+
+				for _, p := range report.Patterns[1:] {
+					So(p.Err, ShouldBeNil)
+					So(p.Saving.Size, ShouldBeGreaterThan, 0)
+				}
+			})
+		})
+	})
+}
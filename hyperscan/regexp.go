@@ -0,0 +1,506 @@
+package hyperscan
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// Engine is the regular expression engine used by a Regexp.
+type Engine int
+
+const (
+	// HyperscanEngine matches with Hyperscan, the matches are confirmed with regexp when it is ambiguous.
+	HyperscanEngine Engine = iota
+	// PrefilterEngine matches with Hyperscan in the prefilter mode, and verifies the matches with regexp.
+	PrefilterEngine
+	// StdEngine matches with the regexp package.
+	StdEngine
+)
+
+func (e Engine) String() string {
+	switch e {
+	case HyperscanEngine:
+		return "hyperscan"
+	case PrefilterEngine:
+		return "prefilter"
+	case StdEngine:
+		return "regexp"
+	default:
+		return fmt.Sprintf("Engine(%d)", int(e))
+	}
+}
+
+// Regexp is the representation of a compiled regular expression, with the method set of regexp.Regexp.
+//
+// The expression is compiled with Hyperscan when possible. Otherwise, it falls back to Hyperscan in the prefilter mode
+// with the matches verified by the regexp package, and finally to the regexp package alone.
+// The matches follow the leftmost-first semantics of regexp, as long as the regexp package supports the expression.
+//
+// A Regexp is safe for concurrent use by multiple goroutines, except for configuration methods, such as Longest.
+// The scratch spaces of Hyperscan are pooled by the Regexp, and freed once they are dropped from the pool.
+//
+// With PrefilterEngine, the regexp package only confirms the regions ending at the candidate matches
+// reported by Hyperscan, as long as the largest width of a match, if the expression has no empty match
+// and no assertion such as ^, $ or \b. Otherwise, it confirms the input up to the last candidate match,
+// or the whole input if the expression has an assertion.
+type Regexp struct {
+	expr    string
+	engine  Engine
+	scanner *blockScanner
+	std     *regexp.Regexp
+	longest bool
+
+	scratches sync.Pool
+
+	// The largest width of a match in bytes, or -1 if unbounded, and whether the regions of the candidate matches
+	// could be confirmed without the context, if compiled with PrefilterEngine.
+	width    int
+	regional bool
+}
+
+// CompileRegexp parses a regular expression and returns, if successful, a Regexp object that can be used to match against text.
+func CompileRegexp(expr string) (*Regexp, error) {
+	std, stdErr := regexp.Compile(expr)
+
+	re := &Regexp{expr: expr, std: std}
+
+	b := DatabaseBuilder{Patterns: Patterns{NewPattern(expr, SomLeftMost|AllowEmpty)}}
+
+	db, err := b.Build()
+	if err == nil {
+		re.scanner = db.(*blockDatabase).blockScanner
+
+		return re, nil
+	}
+
+	if stdErr != nil {
+		return nil, fmt.Errorf("compile regexp, %w", err)
+	}
+
+	b = DatabaseBuilder{Patterns: Patterns{NewPattern(expr, PrefilterMode|AllowEmpty)}}
+
+	if db, err = b.Build(); err == nil {
+		re.engine = PrefilterEngine
+		re.scanner = db.(*blockDatabase).blockScanner
+		re.width, re.regional = prefilterRegions(expr, std)
+	} else {
+		re.engine = StdEngine
+	}
+
+	return re, nil
+}
+
+// prefilterRegions returns the largest width of a match of the expression in bytes, or -1 if unbounded,
+// and whether its matches are independent of the context, which has no empty match and no assertion.
+func prefilterRegions(expr string, std *regexp.Regexp) (int, bool) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return -1, false
+	}
+
+	re = re.Simplify()
+
+	return maxWidth(re), !std.MatchString("") && !hasAssertion(re)
+}
+
+func hasAssertion(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	default:
+		for _, sub := range re.Sub {
+			if hasAssertion(sub) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// matcher returns a matcher with a pooled scratch, and the function returning the scratch to the pool.
+func (re *Regexp) matcher() (*blockMatcher, func()) {
+	m := newBlockMatcher(re.scanner)
+
+	s, ok := re.scratches.Get().(*Scratch)
+	if !ok {
+		var err error
+
+		if s, err = newScratch(re.scanner); err != nil {
+			return m, func() {}
+		}
+
+		runtime.SetFinalizer(s, func(s *Scratch) { _ = s.Free() })
+	}
+
+	m.scratch = s
+
+	return m, func() { re.scratches.Put(s) }
+}
+
+// candidates returns the end of the candidate matches reported by Hyperscan in the prefilter mode.
+func (re *Regexp) candidates(b []byte) []int {
+	m, put := re.matcher()
+	defer put()
+
+	var ends []int
+
+	// the candidates all start at 0 without SomLeftMost, which MatchRecorder would merge into the last one.
+	err := m.blockScanner.Scan(b, m.scratch, func(id uint, from, to uint64, flags uint, context interface{}) error {
+		ends = append(ends, int(to))
+
+		return nil
+	}, nil)
+	if err != nil {
+		return nil
+	}
+
+	sort.Ints(ends)
+
+	return ends
+}
+
+// confirm finds at most n matches with the regexp package in the regions of the candidate matches.
+func (re *Regexp) confirm(b []byte, n int) (locs [][]int) {
+	ends := re.candidates(b)
+	if len(ends) == 0 {
+		return nil
+	}
+
+	if !re.regional {
+		return re.std.FindAllIndex(b, n)
+	}
+
+	if re.width < 0 {
+		return re.std.FindAllIndex(b[:ends[len(ends)-1]], n)
+	}
+
+	// the regions of the matches ending at the candidates, merged if they overlap.
+	for i := 0; i < len(ends); {
+		from, to := ends[i]-re.width, ends[i]
+
+		if from < 0 {
+			from = 0
+		}
+
+		for i++; i < len(ends) && ends[i]-re.width <= to; i++ {
+			to = ends[i]
+		}
+
+		for _, loc := range re.std.FindAllIndex(b[from:to], -1) {
+			if n >= 0 && len(locs) == n {
+				return
+			}
+
+			locs = append(locs, []int{from + loc[0], from + loc[1]})
+		}
+	}
+
+	return
+}
+
+// MustCompileRegexp is like CompileRegexp but panics if the expression cannot be parsed.
+// It simplifies safe initialization of global variables holding compiled regular expressions.
+func MustCompileRegexp(expr string) *Regexp {
+	re, err := CompileRegexp(expr)
+	if err != nil {
+		panic(`CompileRegexp(` + Quote(expr) + `): ` + err.Error())
+	}
+
+	return re
+}
+
+// String returns the source text used to compile the regular expression.
+func (re *Regexp) String() string { return re.expr }
+
+// Engine returns the regular expression engine in use.
+func (re *Regexp) Engine() Engine { return re.engine }
+
+// Longest makes future searches prefer leftmost-longest matches.
+// This method modifies the Regexp and may not be called concurrently with any other methods.
+func (re *Regexp) Longest() {
+	re.longest = true
+
+	if re.std != nil {
+		re.std.Longest()
+	}
+}
+
+// Close releases the Hyperscan database of the Regexp, if any.
+func (re *Regexp) Close() error {
+	if re.scanner == nil {
+		return nil
+	}
+
+	return re.scanner.Close()
+}
+
+// Match reports whether the byte slice b contains any match of the regular expression re.
+func (re *Regexp) Match(b []byte) bool {
+	switch re.engine {
+	case HyperscanEngine:
+		m, put := re.matcher()
+		defer put()
+
+		// the assertions like `$` don't always agree, the match is confirmed with regexp if possible.
+		return m.Match(b) && (re.std == nil || re.std.Match(b))
+	case PrefilterEngine:
+		return re.confirm(b, 1) != nil
+	default:
+		return re.std.Match(b)
+	}
+}
+
+// MatchString reports whether the string s contains any match of the regular expression re.
+func (re *Regexp) MatchString(s string) bool {
+	return re.Match([]byte(s))
+}
+
+// Find returns a slice holding the text of the leftmost match in b of the regular expression.
+// A return value of nil indicates no match.
+func (re *Regexp) Find(b []byte) []byte {
+	if loc := re.FindIndex(b); loc != nil {
+		return b[loc[0]:loc[1]:loc[1]]
+	}
+
+	return nil
+}
+
+// FindIndex returns a two-element slice of integers defining the location of the leftmost match in b
+// of the regular expression. The match itself is at b[loc[0]:loc[1]]. A return value of nil indicates no match.
+func (re *Regexp) FindIndex(b []byte) []int {
+	if locs := re.FindAllIndex(b, 1); len(locs) == 1 {
+		return locs[0]
+	}
+
+	return nil
+}
+
+// FindString returns a string holding the text of the leftmost match in s of the regular expression.
+// If there is no match, the return value is an empty string.
+func (re *Regexp) FindString(s string) string {
+	if loc := re.FindStringIndex(s); loc != nil {
+		return s[loc[0]:loc[1]]
+	}
+
+	return ""
+}
+
+// FindStringIndex returns a two-element slice of integers defining the location of the leftmost match in s
+// of the regular expression. The match itself is at s[loc[0]:loc[1]]. A return value of nil indicates no match.
+func (re *Regexp) FindStringIndex(s string) []int {
+	return re.FindIndex([]byte(s))
+}
+
+// FindAll is the 'All' version of Find; it returns a slice of all successive matches of the expression.
+// A return value of nil indicates no match.
+func (re *Regexp) FindAll(b []byte, n int) (matches [][]byte) {
+	for _, loc := range re.FindAllIndex(b, n) {
+		matches = append(matches, b[loc[0]:loc[1]:loc[1]])
+	}
+
+	return
+}
+
+// FindAllString is the 'All' version of FindString; it returns a slice of all successive matches of the expression.
+// A return value of nil indicates no match.
+func (re *Regexp) FindAllString(s string, n int) (matches []string) {
+	for _, loc := range re.FindAllStringIndex(s, n) {
+		matches = append(matches, s[loc[0]:loc[1]])
+	}
+
+	return
+}
+
+// FindAllStringIndex is the 'All' version of FindStringIndex;
+// it returns a slice of all successive matches of the expression. A return value of nil indicates no match.
+func (re *Regexp) FindAllStringIndex(s string, n int) [][]int {
+	return re.FindAllIndex([]byte(s), n)
+}
+
+// FindAllIndex is the 'All' version of FindIndex; it returns a slice of all successive matches of the expression.
+// A return value of nil indicates no match.
+func (re *Regexp) FindAllIndex(b []byte, n int) (locs [][]int) {
+	if n == 0 {
+		return nil
+	}
+
+	switch re.engine {
+	case PrefilterEngine:
+		return re.confirm(b, n)
+
+	case StdEngine:
+		return re.std.FindAllIndex(b, n)
+
+	case HyperscanEngine:
+	}
+
+	m, put := re.matcher()
+	defer put()
+
+	m.n = -1
+
+	if err := m.scan(b); err != nil && !errors.Is(err, ErrScanTerminated) {
+		return nil
+	}
+
+	sem := LeftmostFirst
+	if re.longest {
+		sem = LeftmostLongest
+	}
+
+	matches := sem.selectMatches(m.Events, m.regexps, b)
+
+	if re.std != nil && ambiguous(m.Events, matches) {
+		return re.std.FindAllIndex(b, n)
+	}
+
+	for _, e := range matches {
+		if n >= 0 && len(locs) == n {
+			break
+		}
+
+		locs = append(locs, []int{int(e.From), int(e.To)})
+	}
+
+	return
+}
+
+// ambiguous reports whether a match event crosses the end of a selected match,
+// since a match starting after the selected one could be hidden by the event.
+func ambiguous(events, matches []hs.MatchEvent) bool {
+	for _, e := range events {
+		// the first selected match which ends after the start of event.
+		i := sort.Search(len(matches), func(i int) bool { return matches[i].To > e.From })
+
+		if i < len(matches) && matches[i].To < e.To {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReplaceAll returns a copy of src, replacing matches of the Regexp with the replacement text repl.
+// Inside repl, $ signs are interpreted as in regexp.Regexp.Expand.
+func (re *Regexp) ReplaceAll(src, repl []byte) []byte {
+	if re.std == nil {
+		return re.replaceAll(src, func(dst []byte, loc []int) []byte {
+			return expander.Expand(dst, repl, src, loc)
+		})
+	}
+
+	if !re.Match(src) {
+		return append([]byte(nil), src...)
+	}
+
+	return re.std.ReplaceAll(src, repl)
+}
+
+// ReplaceAllString returns a copy of src, replacing matches of the Regexp with the replacement string repl.
+// Inside repl, $ signs are interpreted as in regexp.Regexp.Expand.
+func (re *Regexp) ReplaceAllString(src, repl string) string {
+	if re.std == nil {
+		return string(re.replaceAll([]byte(src), func(dst []byte, loc []int) []byte {
+			return expander.ExpandString(dst, repl, src, loc)
+		}))
+	}
+
+	if !re.MatchString(src) {
+		return src
+	}
+
+	return re.std.ReplaceAllString(src, repl)
+}
+
+// ReplaceAllLiteral returns a copy of src, replacing matches of the Regexp with the replacement bytes repl.
+// The replacement repl is substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllLiteral(src, repl []byte) []byte {
+	return re.replaceAll(src, func(dst []byte, loc []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+// ReplaceAllLiteralString returns a copy of src, replacing matches of the Regexp with the replacement string repl.
+// The replacement repl is substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllLiteralString(src, repl string) string {
+	return string(re.ReplaceAllLiteral([]byte(src), []byte(repl)))
+}
+
+// ReplaceAllFunc returns a copy of src in which all matches of the Regexp have been replaced
+// by the return value of function repl applied to the matched byte slice.
+// The replacement returned by repl is substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllFunc(src []byte, repl func([]byte) []byte) []byte {
+	return re.replaceAll(src, func(dst []byte, loc []int) []byte {
+		return append(dst, repl(src[loc[0]:loc[1]])...)
+	})
+}
+
+// ReplaceAllStringFunc returns a copy of src in which all matches of the Regexp have been replaced
+// by the return value of function repl applied to the matched substring.
+// The replacement returned by repl is substituted directly, without using Expand.
+func (re *Regexp) ReplaceAllStringFunc(src string, repl func(string) string) string {
+	return string(re.replaceAll([]byte(src), func(dst []byte, loc []int) []byte {
+		return append(dst, repl(src[loc[0]:loc[1]])...)
+	}))
+}
+
+func (re *Regexp) replaceAll(src []byte, repl func(dst []byte, loc []int) []byte) []byte {
+	var dst []byte
+
+	last := 0
+
+	for _, loc := range re.FindAllIndex(src, -1) {
+		dst = append(dst, src[last:loc[0]]...)
+		dst = repl(dst, loc)
+		last = loc[1]
+	}
+
+	return append(dst, src[last:]...)
+}
+
+// Split slices s into substrings separated by the expression and returns a slice of
+// the substrings between those expression matches, like regexp.Regexp.Split.
+func (re *Regexp) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+
+	if len(re.expr) > 0 && len(s) == 0 {
+		return []string{""}
+	}
+
+	matches := re.FindAllStringIndex(s, n)
+	strings := make([]string, 0, len(matches))
+
+	beg := 0
+	end := 0
+
+	for _, match := range matches {
+		if n > 0 && len(strings) == n-1 {
+			break
+		}
+
+		end = match[0]
+
+		if match[1] != 0 {
+			strings = append(strings, s[beg:end])
+		}
+
+		beg = match[1]
+	}
+
+	if end != len(s) {
+		strings = append(strings, s[beg:])
+	}
+
+	return strings
+}
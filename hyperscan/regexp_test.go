@@ -0,0 +1,132 @@
+package hyperscan_test
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+var regexpCases = []struct {
+	expr string
+	data string
+}{
+	{`\d+`, "abc123def456"},
+	{`foo|foobar`, "foobar foo"},
+	{`a+?`, "aaa"},
+	{`a|aa`, "aaa"},
+	{`a*`, "baaab"},
+	{`[a-z]+@[a-z]+\.com`, "mail foo@bar.com or bar@foo.com"},
+	{`(?i)hello`, "Hello HELLO"},
+	{`(?U)a+`, "aaa"},
+	{`^\w+`, "hello world"},
+	{`\w+$`, "hello world"},
+	{`x`, "nothing"},
+	{`[a-z]{2,4}\d`, "ab1 abcdef2 x3 yz4yz5"},
+	{`a\w*b`, "a1b xx ab a22b ccc"},
+	{`(?s)b.{0,3}c|cd`, "abxc cdd bbbbbc"},
+	{`a$`, "a\n"},
+	{`\bfoo\b`, "foobar xfoo foo"},
+	{`\bfoo`, "xfoo"},
+	{`(?m)^\w+$`, "ab cd\nef\n"},
+	{`(?m)^b`, "ab\nb"},
+}
+
+//nolint:funlen
+func TestRegexp(t *testing.T) {
+	Convey("Given the regexp cases", t, func() {
+		for _, c := range regexpCases {
+			c := c
+
+			Convey("When compile "+c.expr, func() {
+				re, err := hyperscan.CompileRegexp(c.expr)
+				So(err, ShouldBeNil)
+
+				defer re.Close()
+
+				std := regexp.MustCompile(c.expr)
+
+				So(re.String(), ShouldEqual, c.expr)
+
+				Convey("It agrees with regexp", func() {
+					So(re.MatchString(c.data), ShouldEqual, std.MatchString(c.data))
+					So(re.FindStringIndex(c.data), ShouldResemble, std.FindStringIndex(c.data))
+					So(re.FindString(c.data), ShouldEqual, std.FindString(c.data))
+					So(re.FindAllString(c.data, -1), ShouldResemble, std.FindAllString(c.data, -1))
+					So(re.FindAllStringIndex(c.data, 1), ShouldResemble, std.FindAllStringIndex(c.data, 1))
+					So(re.ReplaceAllString(c.data, "<$0>"), ShouldEqual, std.ReplaceAllString(c.data, "<$0>"))
+					So(re.ReplaceAllLiteralString(c.data, "$"), ShouldEqual, std.ReplaceAllLiteralString(c.data, "$"))
+					So(re.ReplaceAllStringFunc(c.data, strings.ToUpper), ShouldEqual,
+						std.ReplaceAllStringFunc(c.data, strings.ToUpper))
+					So(re.Split(c.data, -1), ShouldResemble, std.Split(c.data, -1))
+				})
+
+				Convey("It agrees with regexp after Longest", func() {
+					re.Longest()
+					std.Longest()
+
+					So(re.FindAllStringIndex(c.data, -1), ShouldResemble, std.FindAllStringIndex(c.data, -1))
+				})
+			})
+		}
+	})
+
+	Convey("Given a regexp used by many goroutines", t, func() {
+		re := hyperscan.MustCompileRegexp(`\d+`)
+		defer re.Close()
+
+		var wg sync.WaitGroup
+
+		errs := make(chan []string, 8)
+
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 100; j++ {
+					if found := re.FindAllString("a1b22c333", -1); len(found) != 3 {
+						errs <- found
+
+						return
+					}
+				}
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		Convey("The pooled scratches are not shared", func() {
+			So(<-errs, ShouldBeNil)
+		})
+	})
+
+	Convey("Given the expressions supported by Hyperscan", t, func() {
+		re := hyperscan.MustCompileRegexp(`\d+`)
+		defer re.Close()
+
+		So(re.Engine(), ShouldEqual, hyperscan.HyperscanEngine)
+		So(re.Engine().String(), ShouldEqual, "hyperscan")
+	})
+
+	Convey("Given the expressions only supported by regexp", t, func() {
+		re := hyperscan.MustCompileRegexp(`(?U)a+`)
+		defer re.Close()
+
+		So(re.Engine(), ShouldNotEqual, hyperscan.HyperscanEngine)
+		So(re.FindAllString("aaa", -1), ShouldResemble, []string{"a", "a", "a"})
+	})
+
+	Convey("Given the expressions not supported by any engine", t, func() {
+		_, err := hyperscan.CompileRegexp(`(a)\1`)
+		So(err, ShouldNotBeNil)
+
+		So(func() { hyperscan.MustCompileRegexp(`(a)\1`) }, ShouldPanic)
+	})
+}
@@ -0,0 +1,250 @@
+package hyperscan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// expander expands the replacement templates, which only refer to the whole match.
+var expander = regexp.MustCompile("")
+
+var _ BlockReplacer = (*blockDatabase)(nil)
+
+func (m *blockMatcher) replaceAll(src []byte, repl func(dst []byte, id uint, loc []int) []byte) []byte {
+	if m.checkSom(m.opts) != nil {
+		return append([]byte(nil), src...)
+	}
+
+	var dst []byte
+
+	last := 0
+
+	for _, e := range m.findAll(src, -1, m.opts.replaceSemantics()) {
+		dst = append(dst, src[last:e.From]...)
+		dst = repl(dst, e.ID, []int{int(e.From), int(e.To)})
+		last = int(e.To)
+	}
+
+	return append(dst, src[last:]...)
+}
+
+func (m *blockMatcher) ReplaceAll(src, repl []byte) []byte {
+	return m.replaceAll(src, func(dst []byte, id uint, loc []int) []byte {
+		return expander.Expand(dst, repl, src, loc)
+	})
+}
+
+func (m *blockMatcher) ReplaceAllString(src, repl string) string {
+	b := m.replaceAll([]byte(src), func(dst []byte, id uint, loc []int) []byte {
+		return expander.ExpandString(dst, repl, src, loc)
+	})
+
+	return string(b)
+}
+
+func (m *blockMatcher) ReplaceAllLiteral(src, repl []byte) []byte {
+	return m.replaceAll(src, func(dst []byte, id uint, loc []int) []byte {
+		return append(dst, repl...)
+	})
+}
+
+func (m *blockMatcher) ReplaceAllFunc(src []byte, repl func(id uint, match []byte) []byte) []byte {
+	return m.replaceAll(src, func(dst []byte, id uint, loc []int) []byte {
+		return append(dst, repl(id, src[loc[0]:loc[1]])...)
+	})
+}
+
+func (m *blockMatcher) Split(s string, n int) []string {
+	if n == 0 {
+		return nil
+	}
+
+	if len(s) == 0 || m.checkSom(m.opts) != nil {
+		return []string{s}
+	}
+
+	matches := m.findAll([]byte(s), -1, m.opts.replaceSemantics())
+	strings := make([]string, 0, len(matches))
+
+	beg := 0
+	end := 0
+
+	for _, e := range matches {
+		if n > 0 && len(strings) == n-1 {
+			break
+		}
+
+		end = int(e.From)
+
+		if e.To != 0 {
+			strings = append(strings, s[beg:end])
+		}
+
+		beg = int(e.To)
+	}
+
+	if end != len(s) {
+		strings = append(strings, s[beg:])
+	}
+
+	return strings
+}
+
+var _ StreamReplacer = (*streamDatabase)(nil)
+
+// historySize is the size of data buffered to resolve or verify the matches of a stream,
+// which is the precision of SomHorizonSmallMode.
+const historySize = 1 << 16
+
+// streamReplacer replaces the non-overlapping matches of a stream with a bounded buffer.
+type streamReplacer struct {
+	w       io.Writer
+	repl    func(id uint, match []byte) []byte
+	sem     Semantics
+	regexps *stdRegexps
+	buf     []byte // the data not yet written.
+	offset  uint64 // the offset of buffered data in the stream.
+	events  []hs.MatchEvent
+}
+
+func (r *streamReplacer) Handle(id uint, from, to uint64, flags uint, context interface{}) error {
+	if from >= r.offset {
+		r.events = append(r.events, hs.MatchEvent{ID: id, From: from, To: to, ScanFlag: hs.ScanFlag(flags)})
+	}
+
+	return nil
+}
+
+// flush writes the data and replacements before the window, or all of them at the end of stream.
+func (r *streamReplacer) flush(final bool) error {
+	end := r.offset + uint64(len(r.buf))
+	cut := end
+
+	if !final {
+		if len(r.buf) < 2*historySize {
+			return nil
+		}
+
+		cut = end - historySize
+	}
+
+	pos := r.offset
+
+	for _, m := range r.sem.selectMatches(r.events, r.regexps, nil) {
+		if m.To > cut {
+			if m.From < cut {
+				cut = m.From
+			}
+
+			break
+		}
+
+		if err := r.write(r.buf[pos-r.offset : m.From-r.offset]); err != nil {
+			return err
+		}
+
+		if err := r.write(r.repl(m.ID, r.buf[m.From-r.offset:m.To-r.offset])); err != nil {
+			return err
+		}
+
+		pos = m.To
+	}
+
+	if pos < cut {
+		if err := r.write(r.buf[pos-r.offset : cut-r.offset]); err != nil {
+			return err
+		}
+	}
+
+	events := r.events[:0]
+
+	for _, e := range r.events {
+		if e.From >= cut {
+			events = append(events, e)
+		}
+	}
+
+	r.events = events
+	r.buf = append(r.buf[:0], r.buf[cut-r.offset:]...)
+	r.offset = cut
+
+	return nil
+}
+
+func (r *streamReplacer) write(b []byte) error {
+	if _, err := r.w.Write(b); err != nil {
+		return fmt.Errorf("write data, %w", err)
+	}
+
+	return nil
+}
+
+// checkSom returns an error if a pattern doesn't report the start of its matches.
+func (d *baseDatabase) checkSom(opts *scanOptions) error {
+	verified := opts != nil && opts.verifier != nil
+
+	for _, p := range d.patterns {
+		if p.Flags&SomLeftMost == SomLeftMost || (p.Flags&PrefilterMode == PrefilterMode && verified) {
+			continue
+		}
+
+		return fmt.Errorf("replace the matches of pattern %d without SomLeftMost, %w", p.Id, ErrInvalid)
+	}
+
+	return nil
+}
+
+func (m *streamMatcher) ReplaceAllFunc(dst io.Writer, src io.Reader, repl func(id uint, match []byte) []byte) error {
+	if err := m.checkSom(m.opts); err != nil {
+		return err
+	}
+
+	r := &streamReplacer{w: dst, repl: repl, sem: m.opts.replaceSemantics(), regexps: m.regexps}
+
+	stream, err := m.streamScanner.Open(0, nil, r.Handle, nil)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, bufSize)
+
+	for {
+		n, err := src.Read(buf)
+
+		if n > 0 {
+			r.buf = append(r.buf, buf[:n]...)
+
+			if err := stream.Scan(buf[:n]); err != nil {
+				_ = stream.Close()
+
+				return fmt.Errorf("scan stream, %w", err)
+			}
+
+			if err := r.flush(false); err != nil {
+				_ = stream.Close()
+
+				return err
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			_ = stream.Close()
+
+			return fmt.Errorf("read stream, %w", err)
+		}
+	}
+
+	if err := stream.Close(); err != nil {
+		return fmt.Errorf("close stream, %w", err)
+	}
+
+	return r.flush(true)
+}
@@ -0,0 +1,170 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestBlockReplacer(t *testing.T) {
+	Convey("Given a block database with a few patterns", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `\d+`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `[a-z]+@[a-z]+\.com`, Flags: hyperscan.SomLeftMost, Id: 2},
+			},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		bdb, ok := db.(hyperscan.BlockDatabase)
+		So(ok, ShouldBeTrue)
+
+		src := "call 555 1234 or mail foo@bar.com"
+
+		Convey("When replace all the matches", func() {
+			So(string(bdb.ReplaceAll([]byte(src), []byte("<$0>"))), ShouldEqual,
+				"call <555> <1234> or mail <foo@bar.com>")
+			So(bdb.ReplaceAllString(src, "${0}$$"), ShouldEqual, "call 555$ 1234$ or mail foo@bar.com$")
+		})
+
+		Convey("When replace all the matches with literal", func() {
+			So(string(bdb.ReplaceAllLiteral([]byte(src), []byte("$0"))), ShouldEqual, "call $0 $0 or mail $0")
+		})
+
+		Convey("When replace all the matches with function", func() {
+			masked := bdb.ReplaceAllFunc([]byte(src), func(id uint, match []byte) []byte {
+				if id == 1 {
+					return bytes.Repeat([]byte("#"), len(match))
+				}
+
+				return []byte("<email>")
+			})
+
+			So(string(masked), ShouldEqual, "call ### #### or mail <email>")
+		})
+
+		Convey("When nothing matched", func() {
+			So(bdb.ReplaceAllString("nothing", "x"), ShouldEqual, "nothing")
+		})
+
+		Convey("When split the data", func() {
+			seps, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`[,;]\s*`, hyperscan.SomLeftMost))
+			So(err, ShouldBeNil)
+
+			defer seps.Close()
+
+			s := "a, b;c,,d"
+
+			So(seps.Split(s, -1), ShouldResemble, []string{"a", "b", "c", "", "d"})
+			So(seps.Split(s, 2), ShouldResemble, []string{"a", "b;c,,d"})
+			So(seps.Split(s, 0), ShouldBeNil)
+			So(seps.Split("", -1), ShouldResemble, []string{""})
+			So(seps.Split(",a,", -1), ShouldResemble, []string{"", "a", ""})
+		})
+	})
+
+	Convey("Given a block database with overlapping matches", t, func() {
+		db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`aa`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		So(db.FindAllStringIndex("aaaaa", -1), ShouldResemble, [][]int{{0, 2}, {1, 3}, {2, 4}, {3, 5}})
+
+		Convey("The replacement uses the non-overlapping matches", func() {
+			So(db.ReplaceAllString("aaaaa", "b"), ShouldEqual, "bba")
+		})
+	})
+	Convey("Given a block database with a pattern without SomLeftMost", t, func() {
+		db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`secret`, 0))
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("Then nothing is replaced or split", func() {
+			So(db.ReplaceAllString("the secret is 42", "x"), ShouldEqual, "the secret is 42")
+			So(db.Split("the secret is 42", -1), ShouldResemble, []string{"the secret is 42"})
+		})
+	})
+}
+
+func TestStreamReplacer(t *testing.T) {
+	Convey("Given a streaming database with a few patterns", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `\d+`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `secret`, Flags: hyperscan.SomLeftMost, Id: 2},
+			},
+			Mode: hyperscan.StreamMode,
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		sdb, ok := db.(hyperscan.StreamDatabase)
+		So(ok, ShouldBeTrue)
+
+		repl := func(id uint, match []byte) []byte {
+			return []byte(fmt.Sprintf("<%d:%s>", id, match))
+		}
+
+		Convey("When replace a short stream", func() {
+			var buf bytes.Buffer
+
+			So(sdb.ReplaceAllFunc(&buf, strings.NewReader("the secret is 42"), repl), ShouldBeNil)
+			So(buf.String(), ShouldEqual, "the <2:secret> is <1:42>")
+		})
+
+		Convey("When replace a stream larger than the window", func() {
+			chunk := strings.Repeat("x", 1000) + "secret 12345 "
+			src := strings.Repeat(chunk, 200)
+
+			var buf bytes.Buffer
+
+			So(sdb.ReplaceAllFunc(&buf, strings.NewReader(src), repl), ShouldBeNil)
+			So(buf.String(), ShouldEqual, strings.ReplaceAll(
+				strings.ReplaceAll(src, "secret", "<2:secret>"), "12345", "<1:12345>"))
+		})
+	})
+
+	Convey("Given a streaming database with a pattern without SomLeftMost", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `\d+`, Flags: hyperscan.SomLeftMost, Id: 1},
+				{Expression: `secret`, Id: 2},
+			},
+			Mode: hyperscan.StreamMode,
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		Convey("When replace a stream", func() {
+			var buf bytes.Buffer
+
+			err := db.(hyperscan.StreamDatabase).ReplaceAllFunc(&buf, strings.NewReader("the secret is 42"),
+				func(id uint, match []byte) []byte { return nil })
+
+			Convey("Then the replacement is rejected", func() {
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "pattern 2 without SomLeftMost")
+				So(buf.Len(), ShouldBeZeroValue)
+			})
+		})
+	})
+}
@@ -1,26 +1,100 @@
 package hyperscan
 
 import (
+	"os"
 	"runtime"
 
+	"github.com/flier/gohs/internal/guard"
 	"github.com/flier/gohs/internal/hs"
 )
 
+// ScratchCheckEnv is the environment variable which enables the checked scratch mode if set to a non-empty value.
+const ScratchCheckEnv = "GOHS_SCRATCHCHECK"
+
+var scratchCheck = newScratchCheck()
+
+func newScratchCheck() *guard.Checker {
+	c := &guard.Checker{InUse: ErrScratchInUse, Invalid: ErrInvalid}
+
+	c.Set(os.Getenv(ScratchCheckEnv) != "")
+
+	return c
+}
+
+// SetScratchCheck enables or disables the checked scratch mode, and returns whether it was enabled.
+//
+// A scratch space allocated in the checked scratch mode records the databases it was allocated for,
+// and the goroutine which holds it with the stack where it was acquired.
+// Scanning a database with a scratch space allocated for other databases fails with ErrInvalid,
+// and using a scratch space held by another caller fails with ErrScratchInUse,
+// both naming the call sites in the error.
+func SetScratchCheck(enabled bool) bool { return scratchCheck.Set(enabled) }
+
+// ScratchCheckEnabled returns whether the checked scratch mode is enabled.
+func ScratchCheckEnabled() bool { return scratchCheck.Enabled() }
+
 // Scratch is a Hyperscan scratch space.
 type Scratch struct {
 	s hs.Scratch
+
+	// The database which the scratch space was last allocated for.
+	db hs.Database
+
+	// The scratch spaces of the other shards, if allocated for a database compiled in shards.
+	shards []hs.Scratch
+
+	// The record of the leak detector, if the scratch space is tracked.
+	leak *leakRecord
+
+	// The guard of the scratch space, if allocated in the checked scratch mode.
+	guard *guard.Guard
 }
 
 // NewScratch allocate a "scratch" space for use by Hyperscan.
 // This is required for runtime use, and one scratch space per thread,
 // or concurrent caller, is required.
 func NewScratch(db Database) (*Scratch, error) {
+	s, err := newScratch(db)
+	if err != nil {
+		return nil, err
+	}
+
+	s.leak = trackObject("scratch")
+
+	return s, nil
+}
+
+// newScratch allocates a scratch space which isn't tracked by the leak detector.
+func newScratch(db Database) (*Scratch, error) {
+	if a, ok := db.(pinner); ok {
+		pinned, release, err := a.pin()
+		if err != nil {
+			return nil, err
+		}
+
+		defer release()
+
+		return newScratch(pinned)
+	}
+
+	if err := leakOf(db).use("allocate scratch for"); err != nil {
+		return nil, err
+	}
+
 	s, err := hs.AllocScratch(db.(database).c())
 	if err != nil {
 		return nil, err //nolint: wrapcheck
 	}
 
-	return &Scratch{s}, nil
+	scratch := &Scratch{s: s, db: db.(database).c(), guard: scratchCheck.New(db.(database).c())}
+
+	if err = scratch.allocShards(shardsOf(db)); err != nil {
+		_ = scratch.Free()
+
+		return nil, err
+	}
+
+	return scratch, nil
 }
 
 // NewManagedScratch is a wrapper for NewScratch that sets
@@ -38,25 +112,142 @@ func NewManagedScratch(db Database) (*Scratch, error) {
 	return s, nil
 }
 
-// Size provides the size of the given scratch space.
-func (s *Scratch) Size() (int, error) { return hs.ScratchSize(s.s) } //nolint: wrapcheck
+// Size provides the size of the given scratch space, including the scratch spaces of the shards.
+func (s *Scratch) Size() (int, error) {
+	if err := s.leak.use("size of"); err != nil {
+		return 0, err
+	}
+
+	size, err := hs.ScratchSize(s.s)
+	if err != nil {
+		return 0, err //nolint: wrapcheck
+	}
+
+	for _, shard := range s.shards {
+		n, err := hs.ScratchSize(shard)
+		if err != nil {
+			return 0, err //nolint: wrapcheck
+		}
+
+		size += n
+	}
+
+	return size, nil
+}
 
 // Realloc reallocate the scratch for another database.
 func (s *Scratch) Realloc(db Database) error {
+	if a, ok := db.(pinner); ok {
+		pinned, release, err := a.pin()
+		if err != nil {
+			return err
+		}
+
+		defer release()
+
+		return s.Realloc(pinned)
+	}
+
+	if err := checkLive("realloc", leakOf(db), s); err != nil {
+		return err
+	}
+
 	r, _ := db.(database)
 
-	return hs.ReallocScratch(r.c(), &s.s) //nolint: wrapcheck
+	if err := hs.ReallocScratch(r.c(), &s.s); err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	s.db = r.c()
+	s.guard.Realloc(r.c())
+
+	shards := shardsOf(db)
+
+	for i := range s.shards {
+		if i < len(shards) {
+			if err := hs.ReallocScratch(shards[i], &s.shards[i]); err != nil {
+				return err //nolint: wrapcheck
+			}
+		}
+	}
+
+	if len(s.shards) < len(shards) {
+		return s.allocShards(shards[len(s.shards):])
+	}
+
+	return nil
 }
 
 // Clone allocate a scratch space that is a clone of an existing scratch space.
 func (s *Scratch) Clone() (*Scratch, error) {
+	if err := s.leak.use("clone"); err != nil {
+		return nil, err
+	}
+
 	cloned, err := hs.CloneScratch(s.s)
 	if err != nil {
 		return nil, err //nolint: wrapcheck
 	}
 
-	return &Scratch{cloned}, nil
+	scratch := &Scratch{s: cloned, db: s.db, leak: trackObject("scratch"), guard: s.guard.Clone()}
+
+	for _, shard := range s.shards {
+		cloned, err := hs.CloneScratch(shard)
+		if err != nil {
+			_ = scratch.Free()
+
+			return nil, err //nolint: wrapcheck
+		}
+
+		scratch.shards = append(scratch.shards, cloned)
+	}
+
+	return scratch, nil
 }
 
 // Free a scratch block previously allocated.
-func (s *Scratch) Free() error { return hs.FreeScratch(s.s) } //nolint: wrapcheck
+func (s *Scratch) Free() error {
+	if err := s.leak.release("free"); err != nil {
+		return err
+	}
+
+	for _, shard := range s.shards {
+		_ = hs.FreeScratch(shard)
+	}
+
+	s.shards = nil
+
+	return hs.FreeScratch(s.s) //nolint: wrapcheck
+}
+
+// allocShards allocates the scratch spaces of the shards.
+func (s *Scratch) allocShards(shards []hs.Database) error {
+	for _, db := range shards {
+		scratch, err := hs.AllocScratch(db)
+		if err != nil {
+			return err //nolint: wrapcheck
+		}
+
+		s.shards = append(s.shards, scratch)
+	}
+
+	return nil
+}
+
+// check returns an error if the scratch space wasn't allocated for the database in the checked scratch mode.
+func (s *Scratch) check(db hs.Database, op string) error {
+	if s == nil {
+		return nil
+	}
+
+	return s.guard.Check(db, op) //nolint: wrapcheck
+}
+
+// acquire holds the scratch space for scanning the database until the returned function is called.
+func (s *Scratch) acquire(db hs.Database, op string) (func(), error) {
+	if err := s.check(db, op); err != nil {
+		return nil, err
+	}
+
+	return s.guard.Acquire(op) //nolint: wrapcheck
+}
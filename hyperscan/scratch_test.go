@@ -0,0 +1,105 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+func noMatch(id uint, from, to uint64, flags uint, context interface{}) error { return nil }
+
+//nolint:funlen
+func TestScratchCheck(t *testing.T) {
+	Convey("Given a scratch allocated in the checked scratch mode", t, func() {
+		enabled := hyperscan.SetScratchCheck(true)
+
+		Reset(func() {
+			hyperscan.SetScratchCheck(enabled)
+		})
+
+		So(hyperscan.ScratchCheckEnabled(), ShouldBeTrue)
+
+		foo, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+		So(err, ShouldBeNil)
+
+		defer foo.Close()
+
+		bar, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`bar\d+`, 0))
+		So(err, ShouldBeNil)
+
+		defer bar.Close()
+
+		s, err := hyperscan.NewScratch(foo)
+		So(err, ShouldBeNil)
+
+		defer s.Free()
+
+		Convey("When scan another database with it", func() {
+			err := bar.Scan([]byte("bar1"), s, noMatch, nil)
+
+			Convey("Then it fails with the call sites", func() {
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "scan with scratch allocated for 1 other databases")
+				So(err.Error(), ShouldContainSubstring, "TestScratchCheck")
+			})
+
+			Convey("Then it passes once reallocated", func() {
+				So(s.Realloc(bar), ShouldBeNil)
+				So(bar.Scan([]byte("bar1"), s, noMatch, nil), ShouldBeNil)
+				So(foo.Scan([]byte("foo1"), s, noMatch, nil), ShouldBeNil)
+			})
+		})
+
+		Convey("When scan with it while it is in use", func() {
+			So(s.Realloc(bar), ShouldBeNil)
+
+			var inner error
+
+			err := foo.Scan([]byte("foo1"), s, func(id uint, from, to uint64, flags uint, context interface{}) error {
+				inner = bar.Scan([]byte("bar1"), s, noMatch, nil)
+
+				return nil
+			}, nil)
+
+			Convey("Then the nested scan fails with the call sites", func() {
+				So(err, ShouldBeNil)
+				So(errors.Is(inner, hyperscan.ErrScratchInUse), ShouldBeTrue)
+				So(inner.Error(), ShouldContainSubstring, "scan with scratch in use by goroutine")
+				So(inner.Error(), ShouldContainSubstring, "acquired at")
+			})
+		})
+
+		Convey("When scan a stream with it while it is in use", func() {
+			sdb, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`foo\d+`, 0))
+			So(err, ShouldBeNil)
+
+			defer sdb.Close()
+
+			_, err = sdb.Open(0, s, noMatch, nil)
+			So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+
+			So(s.Realloc(sdb), ShouldBeNil)
+
+			st, err := sdb.Open(0, s, noMatch, nil)
+			So(err, ShouldBeNil)
+
+			var inner error
+
+			err = foo.Scan([]byte("foo1"), s, func(id uint, from, to uint64, flags uint, context interface{}) error {
+				inner = st.Scan([]byte("foo2"))
+
+				return nil
+			}, nil)
+
+			Convey("Then the stream scan fails", func() {
+				So(err, ShouldBeNil)
+				So(errors.Is(inner, hyperscan.ErrScratchInUse), ShouldBeTrue)
+				So(inner.Error(), ShouldContainSubstring, "scan stream with scratch in use by goroutine")
+				So(st.Close(), ShouldBeNil)
+			})
+		})
+	})
+}
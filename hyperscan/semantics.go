@@ -0,0 +1,323 @@
+package hyperscan
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// Semantics selects how the `Find*` helpers of BlockMatcher and StreamMatcher turn
+// the match events reported by Hyperscan into matches.
+//
+// Hyperscan reports every end offset at which a pattern matches, so the raw matches may overlap,
+// and the start of match is only reported accurately for the patterns compiled with SomLeftMost.
+// The non-overlapping semantics need the accurate start of match, otherwise every match starts at 0.
+type Semantics int
+
+const (
+	// RawMatches returns the match events as reported by Hyperscan, which is the default semantics.
+	RawMatches Semantics = iota
+	// LeftmostLongest returns the non-overlapping matches, preferring the leftmost start of match
+	// and then the longest match, like a regexp.Regexp after calling Longest.
+	//
+	// The matches are confirmed like LeftmostFirst does, with the leftmost-longest semantics of regexp.
+	LeftmostLongest
+	// LeftmostFirst returns the non-overlapping matches, preferring the leftmost start of match
+	// and then the match which a backtracking engine would choose, like a regexp.Regexp.
+	//
+	// The preferred match is confirmed with the regexp package for the block databases built by DatabaseBuilder,
+	// as long as the pattern is compiled with SomLeftMost, its expression is supported by regexp
+	// and doesn't use the extended parameters. The assertions see the data preceding the start of match,
+	// and the matches which regexp doesn't confirm at their start are dropped.
+	// Otherwise, it works as LeftmostLongest and picks the first pattern for the same match.
+	LeftmostFirst
+)
+
+// MatchSemantics selects the semantics of the `Find*` helpers, it doesn't change the raw scans.
+func MatchSemantics(s Semantics) ScanOption {
+	return func(opts *scanOptions) {
+		opts.semantics = s
+	}
+}
+
+func (o *scanOptions) matchSemantics() Semantics {
+	if o == nil {
+		return RawMatches
+	}
+
+	return o.semantics
+}
+
+// replaceSemantics returns the non-overlapping semantics used to replace or split the data.
+func (o *scanOptions) replaceSemantics() Semantics {
+	if sem := o.matchSemantics(); sem != RawMatches {
+		return sem
+	}
+
+	return LeftmostLongest
+}
+
+// selectMatches returns the non-overlapping matches from the match events, in order of the start of match.
+//
+// Hyperscan only reports the leftmost start for each end of match, a match that starts inside
+// a selected match and ends where an overlapping match ends is hidden and could not be selected.
+func (s Semantics) selectMatches(events []hs.MatchEvent, regexps *stdRegexps, data []byte) (matches []hs.MatchEvent) {
+	if s == RawMatches {
+		return events
+	}
+
+	sorted := append([]hs.MatchEvent(nil), events...)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+
+		if a.From != b.From {
+			return a.From < b.From
+		}
+
+		if a.To != b.To {
+			return a.To > b.To
+		}
+
+		return regexps.order(a.ID) < regexps.order(b.ID)
+	})
+
+	var pos uint64
+
+	for i := 0; i < len(sorted); {
+		j := i + 1
+		for j < len(sorted) && sorted[j].From == sorted[i].From {
+			j++
+		}
+
+		group := sorted[i:j]
+		i = j
+
+		if group[0].From < pos {
+			continue
+		}
+
+		m, ok := regexps.pick(s, group, data)
+		if !ok {
+			continue
+		}
+
+		// An empty match abutting a preceding match is ignored, like regexp does.
+		if m.From == m.To && len(matches) > 0 && matches[len(matches)-1].To == m.From {
+			continue
+		}
+
+		matches = append(matches, m)
+
+		if pos = m.To; m.From == m.To {
+			pos++
+		}
+	}
+
+	return matches
+}
+
+// stdRegexps compiles the patterns of a database with the regexp package on demand,
+// to confirm the matches reported by Hyperscan.
+type stdRegexps struct {
+	patterns Patterns
+	once     sync.Once
+	orders   map[uint]int
+	anchored map[uint]*anchoredRegexp
+}
+
+func newStdRegexps(patterns Patterns) *stdRegexps {
+	return &stdRegexps{patterns: patterns}
+}
+
+func (r *stdRegexps) init() {
+	r.once.Do(func() {
+		r.orders = make(map[uint]int)
+		r.anchored = make(map[uint]*anchoredRegexp)
+
+		exprs := make(map[uint][]string)
+		supported := make(map[uint]bool)
+
+		for i, p := range r.patterns {
+			if p.Id < 0 {
+				continue
+			}
+
+			id := uint(p.Id)
+
+			if _, exists := r.orders[id]; !exists {
+				r.orders[id] = i
+				supported[id] = true
+			}
+
+			expr, ok := p.stdExpr()
+
+			// the matches are confirmed at their start, which is only reported with SomLeftMost.
+			exprs[id] = append(exprs[id], expr)
+			supported[id] = supported[id] && ok && p.Flags&SomLeftMost == SomLeftMost
+		}
+
+		for id, alts := range exprs {
+			if !supported[id] {
+				continue
+			}
+
+			if re, err := compileAnchored(strings.Join(alts, "|")); err == nil {
+				r.anchored[id] = re
+			}
+		}
+	})
+}
+
+// order returns the order of pattern in the database, the unknown patterns are ordered by their IDs.
+func (r *stdRegexps) order(id uint) int {
+	if r == nil {
+		return int(id)
+	}
+
+	r.init()
+
+	if i, ok := r.orders[id]; ok {
+		return i
+	}
+
+	return len(r.patterns) + int(id)
+}
+
+// pick returns the match selected by the semantics from the matches starting at the same offset,
+// or false if regexp doesn't confirm any of them at the offset.
+//
+// The matches are only confirmed when the data is available, that is, not in streaming mode.
+func (r *stdRegexps) pick(s Semantics, group []hs.MatchEvent, data []byte) (hs.MatchEvent, bool) {
+	if r == nil || (s == LeftmostLongest && data == nil) {
+		return group[0], true
+	}
+
+	r.init()
+
+	candidates := append([]hs.MatchEvent(nil), group...)
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return r.order(candidates[i].ID) < r.order(candidates[j].ID)
+	})
+
+	var best hs.MatchEvent
+
+	found := false
+
+	for i, m := range candidates {
+		if i > 0 && candidates[i-1].ID == m.ID {
+			continue
+		}
+
+		if re := r.anchored[m.ID]; re != nil && data != nil {
+			end, ok := re.matchAt(data, int(m.From), s == LeftmostLongest)
+			if !ok {
+				continue
+			}
+
+			m.To = uint64(end)
+		}
+
+		if s == LeftmostFirst {
+			return m, true
+		}
+
+		if !found || m.To > best.To {
+			best, found = m, true
+		}
+	}
+
+	return best, found
+}
+
+// anchoredRegexp matches the alternatives of a pattern at an offset of the data.
+//
+// The rune preceding the offset is kept as the context of the assertions, like `^`, `\b` or `\B`,
+// the regexps are indexed by whether they prefer the leftmost-longest match.
+type anchoredRegexp struct {
+	start   [2]*regexp.Regexp // `\A(alts)`, matches at the start of data.
+	context [2]*regexp.Regexp // `\A(?s:.)(alts)`, matches after the preceding rune.
+}
+
+func compileAnchored(expr string) (*anchoredRegexp, error) {
+	a := new(anchoredRegexp)
+
+	for i := range a.start {
+		start, err := regexp.Compile(`\A(` + expr + `)`)
+		if err != nil {
+			return nil, err //nolint: wrapcheck
+		}
+
+		context, err := regexp.Compile(`\A(?s:.)(` + expr + `)`)
+		if err != nil {
+			return nil, err //nolint: wrapcheck
+		}
+
+		if i == 1 {
+			start.Longest()
+			context.Longest()
+		}
+
+		a.start[i], a.context[i] = start, context
+	}
+
+	return a, nil
+}
+
+// matchAt returns the end of the match starting at the offset, or false if the alternatives don't match there.
+func (a *anchoredRegexp) matchAt(data []byte, from int, longest bool) (int, bool) {
+	i := 0
+	if longest {
+		i = 1
+	}
+
+	if from == 0 {
+		if loc := a.start[i].FindSubmatchIndex(data); loc != nil {
+			return loc[3], true
+		}
+
+		return 0, false
+	}
+
+	_, size := utf8.DecodeLastRune(data[:from])
+	base := from - size
+
+	// the preceding rune must end at the offset, which isn't the case in the middle of an UTF-8 sequence.
+	if loc := a.context[i].FindSubmatchIndex(data[base:]); loc != nil && loc[2] == size {
+		return base + loc[3], true
+	}
+
+	return 0, false
+}
+
+// stdExpr returns the expression in the regexp syntax, or false if the pattern can't be confirmed by regexp.
+func (p *Pattern) stdExpr() (string, bool) {
+	if p.ext != nil && p.ext.Flags != 0 {
+		return "", false
+	}
+
+	var flags string
+
+	if p.Flags&Caseless == Caseless {
+		flags += "i"
+	}
+
+	if p.Flags&DotAll == DotAll {
+		flags += "s"
+	}
+
+	if p.Flags&MultiLine == MultiLine {
+		flags += "m"
+	}
+
+	if flags == "" {
+		return "(?:" + p.Expression + ")", true
+	}
+
+	return "(?" + flags + ":" + p.Expression + ")", true
+}
@@ -0,0 +1,183 @@
+package hyperscan_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+// semanticsCase is a differential test case between the match semantics and the regexp package.
+type semanticsCase struct {
+	exprs []string
+	flags hyperscan.CompileFlag
+	data  string
+}
+
+func (c semanticsCase) String() string {
+	return strings.Join(c.exprs, ", ") + " in " + c.data
+}
+
+func (c semanticsCase) find(semantics hyperscan.Semantics) [][]int {
+	var patterns hyperscan.Patterns
+
+	for i, expr := range c.exprs {
+		patterns = append(patterns, hyperscan.NewPattern(expr, c.flags|hyperscan.SomLeftMost))
+		patterns[i].Id = i
+	}
+
+	b := hyperscan.DatabaseBuilder{Patterns: patterns}
+
+	db, err := b.Build()
+	So(err, ShouldBeNil)
+
+	defer db.Close()
+
+	v, err := hyperscan.WithScanOptions(db, hyperscan.MatchSemantics(semantics))
+	So(err, ShouldBeNil)
+
+	return v.(hyperscan.BlockMatcher).FindAllStringIndex(c.data, -1)
+}
+
+func (c semanticsCase) regexp(longest bool) [][]int {
+	expr := strings.Join(c.exprs, "|")
+
+	if c.flags&hyperscan.Caseless == hyperscan.Caseless {
+		expr = "(?i)" + expr
+	}
+
+	re := regexp.MustCompile(expr)
+
+	if longest {
+		re.Longest()
+	}
+
+	return re.FindAllStringIndex(c.data, -1)
+}
+
+// The semantics agree with the regexp package as long as no match is hidden by an overlapping match.
+//
+// Hyperscan only reports the leftmost start for each end of match, so a match which starts inside
+// a selected match, and ends where an overlapping match that started earlier ends, is never reported.
+// It happens with the lazy quantifiers and with the patterns whose matches could be chained,
+// see the `differs` cases below.
+var (
+	agreeLongest = []semanticsCase{
+		{exprs: []string{`\d+`}, data: "abc123def456"},
+		{exprs: []string{`[a-z]+`}, data: "hello world"},
+		{exprs: []string{`foo|foobar`}, data: "foobar foo"},
+		{exprs: []string{`ab|cd`}, data: "abcdab"},
+		{exprs: []string{`aa`}, data: "aaaaa"},
+		{exprs: []string{`a.c`}, data: "abcabc"},
+		{exprs: []string{`hello`}, flags: hyperscan.Caseless, data: "Hello HELLO hello"},
+		{exprs: []string{`foo`, `bar`}, data: "foobar barfoo"},
+		{exprs: []string{`foo`, `foob`}, data: "foobar foo"},
+		{exprs: []string{`a+`, `b+`}, data: "aabbaab"},
+		{exprs: []string{`\bfoo\b`}, data: "foobar foo xfoo"},
+		{exprs: []string{`\bb+`}, data: "ab bb abb"},
+		{exprs: []string{`^ab`}, data: "abab"},
+	}
+	agreeFirst = []semanticsCase{
+		{exprs: []string{`\d+`}, data: "abc123def456"},
+		{exprs: []string{`foo|foobar`}, data: "foobar foo"},
+		{exprs: []string{`ab|abcd`}, data: "abcd ab"},
+		{exprs: []string{`a+?b`}, data: "aaab ab"},
+		{exprs: []string{`foo`, `foobar`}, data: "foobar"},
+		{exprs: []string{`foobar`, `foo`}, data: "foobar"},
+		{exprs: []string{`x*y`}, data: "xxy y xy"},
+		{exprs: []string{`\bfoo\b`}, data: "foobar foo xfoo"},
+		{exprs: []string{`(?m)^\w+$`}, data: "ab\ncd e\nfg"},
+		{exprs: []string{`a$`}, data: "a\na"},
+		{exprs: []string{`^ab`, `b`}, data: "abab"},
+	}
+	differsLongest = []semanticsCase{
+		// `a` at 2 is hidden by `aa` from 1 which ends at 3.
+		{exprs: []string{`a|aa`}, data: "aaa"},
+	}
+	differsFirst = []semanticsCase{
+		// the lazy matches after the first one are hidden by the leftmost start at 0.
+		{exprs: []string{`a+?`}, data: "aaa"},
+	}
+)
+
+func TestMatchSemantics(t *testing.T) {
+	Convey("Given the leftmost-longest semantics", t, func() {
+		for _, c := range agreeLongest {
+			Convey("It agrees with regexp.Longest for "+c.String(), func() {
+				So(c.find(hyperscan.LeftmostLongest), ShouldResemble, c.regexp(true))
+			})
+		}
+
+		for _, c := range differsLongest {
+			Convey("It differs from regexp.Longest for "+c.String(), func() {
+				So(c.find(hyperscan.LeftmostLongest), ShouldNotResemble, c.regexp(true))
+			})
+		}
+	})
+
+	Convey("Given the leftmost-first semantics", t, func() {
+		for _, c := range agreeFirst {
+			Convey("It agrees with regexp for "+c.String(), func() {
+				So(c.find(hyperscan.LeftmostFirst), ShouldResemble, c.regexp(false))
+			})
+		}
+
+		for _, c := range differsFirst {
+			Convey("It differs from regexp for "+c.String(), func() {
+				So(c.find(hyperscan.LeftmostFirst), ShouldNotResemble, c.regexp(false))
+			})
+		}
+	})
+
+	Convey("Given the raw semantics", t, func() {
+		c := semanticsCase{exprs: []string{`aa`}, data: "aaaa"}
+
+		Convey("It reports the overlapping matches", func() {
+			So(c.find(hyperscan.RawMatches), ShouldResemble, [][]int{{0, 2}, {1, 3}, {2, 4}})
+			So(c.find(hyperscan.LeftmostLongest), ShouldResemble, [][]int{{0, 2}, {2, 4}})
+		})
+	})
+
+	Convey("Given a block database with the leftmost-longest semantics", t, func() {
+		db, err := hyperscan.NewBlockDatabase(hyperscan.NewPattern(`a+`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		v, err := hyperscan.WithScanOptions(db, hyperscan.MatchSemantics(hyperscan.LeftmostLongest))
+		So(err, ShouldBeNil)
+
+		bdb := v.(hyperscan.BlockDatabase)
+
+		Convey("When find the leftmost match", func() {
+			So(bdb.FindStringIndex("baaab aa"), ShouldResemble, []int{1, 4})
+			So(bdb.FindString("baaab aa"), ShouldEqual, "aaa")
+		})
+
+		Convey("When find the limited matches", func() {
+			So(bdb.FindAllString("baaab aa a", 2), ShouldResemble, []string{"aaa", "aa"})
+		})
+
+		Convey("When nothing matched", func() {
+			So(bdb.FindStringIndex("bbb"), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a streaming database with the leftmost-longest semantics", t, func() {
+		db, err := hyperscan.NewStreamDatabase(hyperscan.NewPattern(`a+`, hyperscan.SomLeftMost))
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		v, err := hyperscan.WithScanOptions(db, hyperscan.MatchSemantics(hyperscan.LeftmostLongest))
+		So(err, ShouldBeNil)
+
+		sdb := v.(hyperscan.StreamDatabase)
+
+		So(sdb.FindIndex(strings.NewReader("baaab aa")), ShouldResemble, []int{1, 4})
+		So(sdb.FindAllIndex(strings.NewReader("baaab aa"), -1), ShouldResemble, [][]int{{1, 4}, {6, 8}})
+	})
+}
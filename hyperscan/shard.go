@@ -0,0 +1,459 @@
+package hyperscan
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"regexp/syntax"
+	"sort"
+	"sync"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// MultiDatabase is a database compiled in shards, see DatabaseBuilder.Shards.
+//
+// Each shard is scanned with its own scratch space, which is allocated by NewScratch for the database,
+// and the matches of the shards are reported under the original pattern IDs in the order of the end of match.
+// The streams are composites of the streams of the shards, which can't be compressed.
+//
+// The block, streaming and vectored databases built by DatabaseBuilder implement MultiDatabase,
+// a database compiled without shards has a single shard.
+type MultiDatabase interface {
+	Database
+
+	// Shards returns the number of shards of the database.
+	Shards() int
+}
+
+func (d *baseDatabase) Shards() int { return 1 + len(d.shards) }
+
+// shardsOf returns the other shards of the database.
+func shardsOf(db Database) []hs.Database {
+	if d, ok := db.(interface{ base() *baseDatabase }); ok {
+		return d.base().shards
+	}
+
+	return nil
+}
+
+func freeDatabases(dbs []hs.Database) {
+	for _, db := range dbs {
+		if db != nil {
+			_ = hs.FreeDatabase(db)
+		}
+	}
+}
+
+// Partitioner assigns the patterns to at most n shards.
+type Partitioner func(patterns Patterns, n int) []Patterns
+
+// PartitionByCount assigns the same number of successive patterns to each shard.
+func PartitionByCount(patterns Patterns, n int) []Patterns {
+	shards := make([]Patterns, 0, n)
+
+	for i := 0; i < n; i++ {
+		shards = append(shards, patterns[i*len(patterns)/n:(i+1)*len(patterns)/n])
+	}
+
+	return shards
+}
+
+// PartitionByID assigns each pattern to the shard of the hash of its ID,
+// so adding, removing or changing a pattern doesn't move the other patterns to another shard.
+func PartitionByID(patterns Patterns, n int) []Patterns {
+	shards := make([]Patterns, n)
+
+	for _, p := range patterns {
+		h := fnv.New32a()
+		_ = binary.Write(h, binary.LittleEndian, int64(p.Id))
+
+		i := h.Sum32() % uint32(n)
+		shards[i] = append(shards[i], p)
+	}
+
+	return shards
+}
+
+// PartitionByCost assigns the patterns to the shards balancing their estimated compile cost,
+// which grows with the size of the expression and its bounded repeats.
+func PartitionByCost(patterns Patterns, n int) []Patterns {
+	costs := make([]int, len(patterns))
+
+	for i, p := range patterns {
+		costs[i] = estimateCost(p)
+	}
+
+	shards := make([]Patterns, 0, n)
+
+	for _, bin := range balance(costs, n) {
+		shard := make(Patterns, len(bin))
+
+		for i, j := range bin {
+			shard[i] = patterns[j]
+		}
+
+		shards = append(shards, shard)
+	}
+
+	return shards
+}
+
+// PartitionByKey assigns the patterns with the same key to the same shard, balancing the number of patterns.
+func PartitionByKey(key func(p *Pattern) string) Partitioner {
+	return func(patterns Patterns, n int) []Patterns {
+		var groups [][]int
+
+		keys := make(map[string]int)
+
+		for i, p := range patterns {
+			k := key(p)
+
+			g, ok := keys[k]
+			if !ok {
+				g = len(groups)
+				keys[k] = g
+				groups = append(groups, nil)
+			}
+
+			groups[g] = append(groups[g], i)
+		}
+
+		costs := make([]int, len(groups))
+
+		for i, group := range groups {
+			costs[i] = len(group)
+		}
+
+		shards := make([]Patterns, 0, n)
+
+		for _, bin := range balance(costs, n) {
+			var indexes []int
+
+			for _, g := range bin {
+				indexes = append(indexes, groups[g]...)
+			}
+
+			sort.Ints(indexes)
+
+			shard := make(Patterns, len(indexes))
+
+			for i, j := range indexes {
+				shard[i] = patterns[j]
+			}
+
+			shards = append(shards, shard)
+		}
+
+		return shards
+	}
+}
+
+// balance assigns the items to n bins, the most costly item first to the least loaded bin,
+// and returns the indexes of the items of each bin in their original order.
+func balance(costs []int, n int) [][]int {
+	order := make([]int, len(costs))
+
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool { return costs[order[i]] > costs[order[j]] })
+
+	bins := make([][]int, n)
+	loads := make([]int, n)
+
+	for _, i := range order {
+		least := 0
+
+		for b := 1; b < n; b++ {
+			if loads[b] < loads[least] {
+				least = b
+			}
+		}
+
+		bins[least] = append(bins[least], i)
+		loads[least] += costs[i]
+	}
+
+	for _, bin := range bins {
+		sort.Ints(bin)
+	}
+
+	return bins
+}
+
+const maxRepeatCost = 1000
+
+// estimateCost estimates the compile cost of the pattern from the size of its syntax tree.
+func estimateCost(p *Pattern) int {
+	re, err := syntax.Parse(p.Expression, syntax.Perl)
+	if err != nil {
+		return len(p.Expression) + 1
+	}
+
+	cost := syntaxCost(re)
+
+	if p.Flags&(Utf8Mode|UnicodeProperty) != 0 {
+		cost *= 2
+	}
+
+	return cost
+}
+
+func syntaxCost(re *syntax.Regexp) int {
+	cost := 1
+
+	for _, sub := range re.Sub {
+		cost += syntaxCost(sub)
+	}
+
+	switch re.Op {
+	case syntax.OpLiteral:
+		cost += len(re.Rune)
+
+	case syntax.OpRepeat:
+		bound := re.Max
+		if bound < 0 {
+			bound = re.Min + 1
+		}
+
+		if bound > maxRepeatCost {
+			bound = maxRepeatCost
+		}
+
+		if bound > 1 {
+			cost *= bound
+		}
+	}
+
+	return cost
+}
+
+// compile compiles the patterns in a single database, or the shards concurrently.
+//
+// The first database is the first shard, the compile error of a shard refers to the index of the pattern set.
+func (b *DatabaseBuilder) compile(mode ModeFlag, platform *hs.PlatformInfo) ([]hs.Database, error) {
+	if b.Shards < 2 {
+		db, err := hs.CompileMulti(b.Patterns, mode, platform)
+		if err != nil {
+			return nil, err //nolint: wrapcheck
+		}
+
+		return []hs.Database{db}, nil
+	}
+
+	shards, err := b.partition()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.compileShards(shards, func(i int, shard Patterns) (hs.Database, error) {
+		return hs.CompileMulti(shard, mode, platform) //nolint: wrapcheck
+	})
+}
+
+// compileShards compiles the shards concurrently, and frees the compiled shards if any of them fails.
+func (b *DatabaseBuilder) compileShards(shards []Patterns,
+	compile func(i int, shard Patterns) (hs.Database, error),
+) ([]hs.Database, error) {
+	dbs := make([]hs.Database, len(shards))
+	errs := make([]error, len(shards))
+
+	var wg sync.WaitGroup
+
+	for i, shard := range shards {
+		wg.Add(1)
+
+		go func(i int, shard Patterns) {
+			defer wg.Done()
+
+			dbs[i], errs[i] = compile(i, shard)
+		}(i, shard)
+	}
+
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			freeDatabases(dbs)
+
+			return nil, b.shardError(shards[i], err)
+		}
+	}
+
+	return dbs, nil
+}
+
+// partition assigns the patterns to the shards, and drops the empty shards.
+func (b *DatabaseBuilder) partition() ([]Patterns, error) {
+	if b.Shards < 2 {
+		return []Patterns{b.Patterns}, nil
+	}
+
+	partition := b.Partition
+	if partition == nil {
+		partition = PartitionByCount
+	}
+
+	var shards []Patterns
+
+	for _, shard := range partition(b.Patterns, b.Shards) {
+		if len(shard) > 0 {
+			shards = append(shards, shard)
+		}
+	}
+
+	if len(shards) == 0 {
+		return nil, fmt.Errorf("no pattern in %d shards, %w", b.Shards, ErrInvalid)
+	}
+
+	return shards, nil
+}
+
+// shardError rewrites the index of the failing expression of a shard as the index of the pattern set.
+func (b *DatabaseBuilder) shardError(shard Patterns, err error) error {
+	compileErr, ok := err.(*CompileError) //nolint: errorlint
+	if !ok || compileErr.Expression < 0 || compileErr.Expression >= len(shard) {
+		return err
+	}
+
+	for i, p := range b.Patterns {
+		if p == shard[compileErr.Expression] {
+			return &CompileError{Message: compileErr.Message, Expression: i}
+		}
+	}
+
+	return err
+}
+
+// collectShards runs the scan of each shard concurrently,
+// and returns their matches in the order of the end of match.
+func collectShards(n int, scan func(i int, handler MatchHandler) error) ([]hs.MatchEvent, error) {
+	events := make([][]hs.MatchEvent, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			errs[i] = scan(i, func(id uint, from, to uint64, flags uint, context interface{}) error {
+				events[i] = append(events[i], hs.MatchEvent{ID: id, From: from, To: to, ScanFlag: ScanFlag(flags)})
+
+				return nil
+			})
+		}(i)
+	}
+
+	wg.Wait()
+
+	var merged []hs.MatchEvent
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+
+		merged = append(merged, events[i]...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].To < merged[j].To })
+
+	return merged, nil
+}
+
+// scanShards scans the other shards of the database concurrently, each with its own scratch space.
+func (d *baseDatabase) scanShards(s *Scratch,
+	scan func(db hs.Database, scratch hs.Scratch, handler MatchHandler) error,
+) ([]hs.MatchEvent, error) {
+	if len(s.shards) < len(d.shards) {
+		return nil, fmt.Errorf("scratch of %d shards for %d shards, %w", len(s.shards)+1, d.Shards(), ErrInvalid)
+	}
+
+	return collectShards(len(d.shards), func(i int, handler MatchHandler) error {
+		return scan(d.shards[i], s.shards[i], handler)
+	})
+}
+
+// shardStream is the stream of a shard with its own scratch space.
+type shardStream struct {
+	stream  hs.Stream
+	scratch hs.Scratch
+}
+
+// shardStreams are the streams of the other shards of a stream.
+type shardStreams []*shardStream
+
+// openShards opens a stream for each of the other shards of the database.
+func (d *baseDatabase) openShards(flags ScanFlag, s *Scratch) (shardStreams, error) {
+	if len(s.shards) < len(d.shards) {
+		return nil, fmt.Errorf("scratch of %d shards for %d shards, %w", len(s.shards)+1, d.Shards(), ErrInvalid)
+	}
+
+	streams := make(shardStreams, 0, len(d.shards))
+
+	for i, db := range d.shards {
+		stream, err := hs.OpenStream(db, flags)
+		if err != nil {
+			streams.free()
+
+			return nil, fmt.Errorf("open stream, %w", err)
+		}
+
+		streams = append(streams, &shardStream{stream, s.shards[i]})
+	}
+
+	return streams, nil
+}
+
+// collect runs the operation on each stream concurrently, and returns their matches in the order of the end of match.
+func (ss shardStreams) collect(fn func(s *shardStream, handler MatchHandler) error) ([]hs.MatchEvent, error) {
+	return collectShards(len(ss), func(i int, handler MatchHandler) error {
+		return fn(ss[i], handler)
+	})
+}
+
+func (ss shardStreams) clone(ownedScratch bool) (shardStreams, error) {
+	cloned := make(shardStreams, 0, len(ss))
+
+	for _, s := range ss {
+		stream, err := hs.CopyStream(s.stream)
+		if err != nil {
+			cloned.free()
+
+			return nil, fmt.Errorf("copy stream, %w", err)
+		}
+
+		scratch := s.scratch
+
+		if ownedScratch {
+			if scratch, err = hs.CloneScratch(s.scratch); err != nil {
+				hs.FreeStream(stream)
+				cloned.free()
+
+				return nil, fmt.Errorf("clone scratch, %w", err)
+			}
+		}
+
+		cloned = append(cloned, &shardStream{stream, scratch})
+	}
+
+	return cloned, nil
+}
+
+// free frees the streams without reporting the matches.
+func (ss shardStreams) free() {
+	for _, s := range ss {
+		hs.FreeStream(s.stream)
+	}
+}
+
+func (ss shardStreams) freeScratch() {
+	for _, s := range ss {
+		_ = hs.FreeScratch(s.scratch)
+	}
+}
@@ -0,0 +1,221 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+//nolint:funlen
+func TestShards(t *testing.T) {
+	Convey("Given a pattern set", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 1},
+			{Expression: `bar`, Flags: hyperscan.SomLeftMost, Id: 2},
+			{Expression: `ba[rz]`, Flags: hyperscan.SomLeftMost, Id: 3},
+			{Expression: `\d+`, Flags: hyperscan.SomLeftMost, Id: 4},
+			{Expression: `o\s`, Flags: hyperscan.SomLeftMost, Id: 5},
+		}
+
+		type match struct {
+			id       uint
+			from, to uint64
+		}
+
+		var matches []match
+
+		handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+			matches = append(matches, match{id, from, to})
+
+			return nil
+		}
+
+		data := []byte("foo bar baz 123")
+
+		Convey("When build a block database in shards", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.BlockMode, Shards: 3}
+
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			So(db.(hyperscan.MultiDatabase).Shards(), ShouldEqual, 3)
+
+			Convey("Then the matches are the ones of a single database", func() {
+				single, err := patterns.Build(hyperscan.BlockMode)
+				So(err, ShouldBeNil)
+
+				defer single.Close()
+
+				So(single.(hyperscan.BlockScanner).Scan(data, nil, handler, nil), ShouldBeNil)
+
+				expected := matches
+				matches = nil
+
+				So(db.(hyperscan.BlockScanner).Scan(data, nil, handler, nil), ShouldBeNil)
+				So(matches, ShouldResemble, expected)
+
+				So(db.(hyperscan.BlockDatabase).FindAllString(string(data), -1), ShouldResemble,
+					single.(hyperscan.BlockDatabase).FindAllString(string(data), -1))
+			})
+
+			Convey("Then each shard is scanned with its own scratch", func() {
+				s, err := hyperscan.NewScratch(db)
+				So(err, ShouldBeNil)
+
+				defer s.Free()
+
+				size, err := s.Size()
+				So(err, ShouldBeNil)
+				So(size, ShouldBeGreaterThan, 0)
+
+				So(db.(hyperscan.BlockScanner).Scan(data, s, handler, nil), ShouldBeNil)
+				So(matches, ShouldNotBeEmpty)
+
+				cloned, err := s.Clone()
+				So(err, ShouldBeNil)
+				So(cloned.Free(), ShouldBeNil)
+			})
+
+			Convey("Then a scratch of a single database is rejected", func() {
+				single, err := patterns.Build(hyperscan.BlockMode)
+				So(err, ShouldBeNil)
+
+				defer single.Close()
+
+				s, err := hyperscan.NewScratch(single)
+				So(err, ShouldBeNil)
+
+				defer s.Free()
+
+				So(db.(hyperscan.BlockScanner).Scan(data, s, handler, nil), ShouldNotBeNil)
+
+				So(s.Realloc(db), ShouldBeNil)
+				So(db.(hyperscan.BlockScanner).Scan(data, s, handler, nil), ShouldBeNil)
+			})
+
+			Convey("Then it can't be marshaled", func() {
+				_, err := db.Marshal()
+
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+			})
+		})
+
+		Convey("When build a stream database in shards", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode, Shards: 2}
+
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			sdb := db.(hyperscan.StreamDatabase)
+
+			So(sdb.(hyperscan.MultiDatabase).Shards(), ShouldEqual, 2)
+
+			Convey("Then the stream is a composite of the streams of the shards", func() {
+				s, err := sdb.Open(0, nil, handler, nil)
+				So(err, ShouldBeNil)
+
+				So(s.Scan(data[:5]), ShouldBeNil)
+				So(s.Scan(data[5:]), ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+
+				// the matches of the other shards are reported first at the same offset.
+				So(matches, ShouldResemble, []match{
+					{1, 0, 3}, {5, 2, 4}, {3, 4, 7}, {2, 4, 7}, {3, 8, 11}, {4, 12, 13}, {4, 12, 14}, {4, 12, 15},
+				})
+			})
+
+			Convey("Then the stream could be cloned and reset", func() {
+				s, err := sdb.Open(0, nil, handler, nil)
+				So(err, ShouldBeNil)
+
+				So(s.Scan([]byte("fo")), ShouldBeNil)
+
+				cloned, err := s.Clone()
+				So(err, ShouldBeNil)
+				So(cloned.Scan([]byte("o ba")), ShouldBeNil)
+				So(cloned.Close(), ShouldBeNil)
+
+				So(s.Reset(), ShouldBeNil)
+				So(s.Scan([]byte("o bar")), ShouldBeNil)
+				So(s.Close(), ShouldBeNil)
+
+				So(matches, ShouldResemble, []match{{1, 0, 3}, {5, 2, 4}, {5, 0, 2}, {3, 2, 5}, {2, 2, 5}})
+			})
+
+			Convey("Then the stream size is the sum of the shards", func() {
+				size, err := sdb.StreamSize()
+				So(err, ShouldBeNil)
+				So(size, ShouldBeGreaterThan, 0)
+
+				_, err = sdb.Compress(nil)
+				So(errors.Is(err, hyperscan.ErrInvalid), ShouldBeTrue)
+			})
+		})
+
+		Convey("When build the shards with a broken pattern", func() {
+			broken := append(hyperscan.Patterns{}, patterns...)
+			broken[3] = &hyperscan.Pattern{Expression: `a(`, Id: 4}
+
+			b := hyperscan.DatabaseBuilder{Patterns: broken, Shards: 2}
+
+			_, err := b.Build()
+
+			Convey("Then the compile error refers to the index of the pattern set", func() {
+				var compileErr *hyperscan.CompileError
+
+				So(errors.As(err, &compileErr), ShouldBeTrue)
+				So(compileErr.Expression, ShouldEqual, 3)
+			})
+		})
+	})
+
+	Convey("Given the partitioners", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `a{100}`, Id: 1},
+			{Expression: `b`, Id: 2},
+			{Expression: `c`, Id: 3},
+			{Expression: `d`, Id: 4},
+		}
+
+		ids := func(shards []hyperscan.Patterns) (ids [][]int) {
+			for _, shard := range shards {
+				var shardIDs []int
+
+				for _, p := range shard {
+					shardIDs = append(shardIDs, p.Id)
+				}
+
+				ids = append(ids, shardIDs)
+			}
+
+			return
+		}
+
+		Convey("When partition by count", func() {
+			So(ids(hyperscan.PartitionByCount(patterns, 2)), ShouldResemble, [][]int{{1, 2}, {3, 4}})
+		})
+
+		Convey("When partition by cost", func() {
+			So(ids(hyperscan.PartitionByCost(patterns, 2)), ShouldResemble, [][]int{{1}, {2, 3, 4}})
+		})
+
+		Convey("When partition by key", func() {
+			partition := hyperscan.PartitionByKey(func(p *hyperscan.Pattern) string {
+				if p.Id%2 == 0 {
+					return "even"
+				}
+
+				return "odd"
+			})
+
+			So(ids(partition(patterns, 3)), ShouldResemble, [][]int{{1, 3}, {2, 4}, nil})
+		})
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/flier/gohs/internal/guard"
 	"github.com/flier/gohs/internal/hs"
 )
 
@@ -63,6 +64,20 @@ type StreamCompressor interface {
 		handler MatchHandler, context interface{}) (Stream, error)
 }
 
+// StreamReplacer implements regular expression replacement on a stream.
+type StreamReplacer interface {
+	// ReplaceAllFunc copies the data from src to dst, replacing the matches of the pattern database
+	// by the return value of function repl applied to the ID of matched pattern and the matched byte slice.
+	//
+	// The matches are selected as BlockReplacer does, but a match is only buffered within a window of 64KiB
+	// (the precision of SomHorizonSmallMode), a longer match could be split or ignored.
+	//
+	// The start of a match is needed to replace it, so the patterns must be compiled with SomLeftMost,
+	// except the ones compiled with PrefilterMode and verified by VerifyPrefilter. If the database is built
+	// by DatabaseBuilder, ReplaceAllFunc returns ErrInvalid for a pattern without SomLeftMost.
+	ReplaceAllFunc(dst io.Writer, src io.Reader, repl func(id uint, match []byte) []byte) error
+}
+
 // StreamDatabase scan the target data to be scanned is a continuous stream,
 // not all of which is available at once;
 // blocks of data are scanned in sequence and matches may span multiple blocks in a stream.
@@ -71,6 +86,7 @@ type StreamDatabase interface {
 	StreamScanner
 	StreamMatcher
 	StreamCompressor
+	StreamReplacer
 
 	StreamSize() (int, error)
 }
@@ -83,7 +99,23 @@ func newStreamDatabase(db hs.Database) *streamDatabase {
 	return &streamDatabase{newStreamMatcher(newStreamScanner(newBaseDatabase(db)))}
 }
 
-func (db *streamDatabase) StreamSize() (int, error) { return hs.StreamSize(db.db) } //nolint: wrapcheck
+func (db *streamDatabase) StreamSize() (int, error) {
+	size, err := hs.StreamSize(db.db)
+	if err != nil {
+		return 0, err //nolint: wrapcheck
+	}
+
+	for _, shard := range db.shards {
+		n, err := hs.StreamSize(shard)
+		if err != nil {
+			return 0, err //nolint: wrapcheck
+		}
+
+		size += n
+	}
+
+	return size, nil
+}
 
 const bufSize = 4096
 
@@ -94,35 +126,206 @@ type stream struct {
 	handler      hs.MatchEventHandler
 	context      interface{}
 	ownedScratch bool
+	filter       *matchFilter
+	verify       *verification
+	fallback     *fallbackStream
+	shards       shardStreams
+
+	// The records of the leak detector for the stream and its database, if they are tracked.
+	leak   *leakRecord
+	dbLeak *leakRecord
+
+	// The guard of the scratch space, if allocated in the checked scratch mode.
+	guard *guard.Guard
+}
+
+// check returns an error if the stream or its database was closed.
+func (s *stream) check(op string) error {
+	if err := s.leak.use(op); err != nil {
+		return err
+	}
+
+	return s.dbLeak.use(op + " stream of")
+}
+
+// useScratch makes the stream scan with the scratch space, which isn't owned by the stream.
+func (s *stream) useScratch(sc *Scratch) {
+	s.scratch, s.guard, s.ownedScratch = sc.s, sc.guard, false
+
+	for i, shard := range s.shards {
+		shard.scratch = sc.shards[i]
+	}
+}
+
+func (s *stream) matchHandler() hs.MatchEventHandler {
+	handler := s.handler
+
+	if s.filter != nil {
+		handler = s.filter.handler(handler)
+	}
+
+	if s.verify != nil {
+		handler = s.verify.handler(handler)
+	}
+
+	return handler
+}
+
+func (s *stream) result(err error) error {
+	if s.filter == nil {
+		return err
+	}
+
+	return s.filter.result(err)
 }
 
 func (s *stream) Scan(data []byte) error {
-	return hs.ScanStream(s.stream, data, s.flags, s.scratch, s.handler, s.context) //nolint: wrapcheck
+	if err := s.check("scan"); err != nil {
+		return err
+	}
+
+	release, err := s.guard.Acquire("scan stream")
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	defer release()
+
+	if s.filter != nil && s.filter.done {
+		return nil
+	}
+
+	if s.verify != nil {
+		s.verify.append(data)
+	}
+
+	handler := s.matchHandler()
+
+	var m *matchMerge
+
+	if s.fallback != nil {
+		m = s.fallback.merge(data)
+	}
+
+	if len(s.shards) > 0 {
+		events, err := s.shards.collect(func(shard *shardStream, handler MatchHandler) error {
+			return hs.ScanStream(shard.stream, data, s.flags, shard.scratch, handler, nil)
+		})
+		if err != nil {
+			return err
+		}
+
+		m = m.with(events)
+	}
+
+	if m != nil {
+		return s.result(m.flush(handler, s.context,
+			hs.ScanStream(s.stream, data, s.flags, s.scratch, m.handler(handler), s.context)))
+	}
+
+	return s.result(hs.ScanStream(s.stream, data, s.flags, s.scratch, handler, s.context))
 }
 
 func (s *stream) Close() error {
-	err := hs.CloseStream(s.stream, s.scratch, s.handler, s.context)
+	release, err := s.guard.Acquire("close stream")
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	defer release()
+
+	if err = s.leak.release("close"); err != nil {
+		return err
+	}
+
+	if err = s.dbLeak.use("close stream of"); err != nil {
+		return err
+	}
+
+	if len(s.shards) > 0 {
+		handler := s.matchHandler()
+
+		events, shardErr := s.shards.collect(func(shard *shardStream, handler MatchHandler) error {
+			return hs.CloseStream(shard.stream, shard.scratch, handler, nil)
+		})
+
+		m := &matchMerge{events}
+
+		if err = m.flush(handler, s.context,
+			hs.CloseStream(s.stream, s.scratch, m.handler(handler), s.context)); err == nil {
+			err = shardErr
+		}
+	} else {
+		err = hs.CloseStream(s.stream, s.scratch, s.matchHandler(), s.context)
+	}
 
 	if s.ownedScratch {
 		_ = hs.FreeScratch(s.scratch)
+
+		s.shards.freeScratch()
 	}
 
-	return err //nolint: wrapcheck
+	return s.result(err)
 }
 
 func (s *stream) Reset() error {
-	return hs.ResetStream(s.stream, s.flags, s.scratch, s.handler, s.context) //nolint: wrapcheck
+	if err := s.check("reset"); err != nil {
+		return err
+	}
+
+	release, err := s.guard.Acquire("reset stream")
+	if err != nil {
+		return err //nolint: wrapcheck
+	}
+
+	defer release()
+
+	if len(s.shards) > 0 {
+		handler := s.matchHandler()
+
+		events, shardErr := s.shards.collect(func(shard *shardStream, handler MatchHandler) error {
+			return hs.ResetStream(shard.stream, s.flags, shard.scratch, handler, nil)
+		})
+
+		m := &matchMerge{events}
+
+		if err = m.flush(handler, s.context,
+			hs.ResetStream(s.stream, s.flags, s.scratch, m.handler(handler), s.context)); err == nil {
+			err = shardErr
+		}
+	} else {
+		err = hs.ResetStream(s.stream, s.flags, s.scratch, s.matchHandler(), s.context)
+	}
+
+	if s.filter != nil {
+		s.filter.reset()
+	}
+
+	if s.verify != nil {
+		s.verify.reset()
+	}
+
+	if s.fallback != nil {
+		s.fallback.reset()
+	}
+
+	return s.result(err)
 }
 
 func (s *stream) Clone() (Stream, error) {
+	if err := s.check("clone"); err != nil {
+		return nil, err
+	}
+
 	ss, err := hs.CopyStream(s.stream)
 	if err != nil {
 		return nil, fmt.Errorf("copy stream, %w", err)
 	}
 
-	scratch := s.scratch
+	scratch, scratchGuard := s.scratch, s.guard
 
 	if s.ownedScratch {
+		scratchGuard = s.guard.Clone()
 		scratch, err = hs.CloneScratch(s.scratch)
 
 		if err != nil {
@@ -132,18 +335,72 @@ func (s *stream) Clone() (Stream, error) {
 		}
 	}
 
-	return &stream{ss, s.flags, scratch, s.handler, s.context, s.ownedScratch}, nil
+	var filter *matchFilter
+
+	if s.filter != nil {
+		filter = s.filter.clone()
+	}
+
+	var verify *verification
+
+	if s.verify != nil {
+		verify = s.verify.clone()
+	}
+
+	var fallback *fallbackStream
+
+	if s.fallback != nil {
+		fallback = s.fallback.clone()
+	}
+
+	var shards shardStreams
+
+	if len(s.shards) > 0 {
+		if shards, err = s.shards.clone(s.ownedScratch); err != nil {
+			hs.FreeStream(ss)
+
+			if s.ownedScratch {
+				_ = hs.FreeScratch(scratch)
+			}
+
+			return nil, err
+		}
+	}
+
+	return &stream{
+		ss, s.flags, scratch, s.handler, s.context, s.ownedScratch, filter, verify, fallback, shards,
+		trackObject("stream"), s.dbLeak, scratchGuard,
+	}, nil
 }
 
 type streamScanner struct {
 	*baseDatabase
+	opts *scanOptions
 }
 
 func newStreamScanner(db *baseDatabase) *streamScanner {
 	return &streamScanner{baseDatabase: db}
 }
 
+func (ss *streamScanner) newStream(s hs.Stream, flags ScanFlag, sc *Scratch,
+	handler MatchHandler, context interface{}, ownedScratch bool,
+) *stream {
+	return &stream{
+		s, flags, sc.s, handler, context, ownedScratch,
+		ss.opts.newFilter(ss.ids), ss.opts.newVerification(ss.baseDatabase, historySize), ss.fallbacks.newStream(), nil,
+		trackObject("stream"), ss.leak, sc.guard,
+	}
+}
+
 func (ss *streamScanner) Open(flags ScanFlag, sc *Scratch, handler MatchHandler, context interface{}) (Stream, error) {
+	if err := checkLive("open stream of", ss.leak, sc); err != nil {
+		return nil, err
+	}
+
+	if err := sc.check(ss.db, "open stream"); err != nil {
+		return nil, err
+	}
+
 	s, err := hs.OpenStream(ss.db, flags)
 	if err != nil {
 		return nil, fmt.Errorf("open stream, %w", err)
@@ -152,15 +409,31 @@ func (ss *streamScanner) Open(flags ScanFlag, sc *Scratch, handler MatchHandler,
 	ownedScratch := false
 
 	if sc == nil {
-		sc, err = NewScratch(ss)
+		sc, err = newScratch(ss)
 		if err != nil {
+			hs.FreeStream(s)
+
 			return nil, fmt.Errorf("create scratch, %w", err)
 		}
 
 		ownedScratch = true
 	}
 
-	return &stream{s, flags, sc.s, handler, context, ownedScratch}, nil
+	shards, err := ss.openShards(flags, sc)
+	if err != nil {
+		hs.FreeStream(s)
+
+		if ownedScratch {
+			_ = sc.Free()
+		}
+
+		return nil, err
+	}
+
+	stream := ss.newStream(s, flags, sc, handler, context, ownedScratch)
+	stream.shards = shards
+
+	return stream, nil
 }
 
 func (ss *streamScanner) Scan(reader io.Reader, sc *Scratch, handler MatchHandler, context interface{}) error {
@@ -282,6 +555,14 @@ func (m *streamMatcher) Find(reader io.ReadSeeker) []byte {
 }
 
 func (m *streamMatcher) FindIndex(reader io.Reader) []int {
+	if m.opts.matchSemantics() != RawMatches {
+		if locs := m.FindAllIndex(reader, 1); len(locs) == 1 {
+			return locs[0]
+		}
+
+		return nil
+	}
+
 	if m.Match(reader) && len(m.Events) == 1 {
 		return []int{int(m.Events[0].From), int(m.Events[0].To)}
 	}
@@ -300,6 +581,22 @@ func (m *streamMatcher) FindAll(reader io.ReadSeeker, n int) (result [][]byte) {
 }
 
 func (m *streamMatcher) FindAllIndex(reader io.Reader, n int) (locs [][]int) {
+	if sem := m.opts.matchSemantics(); sem != RawMatches {
+		m.n = -1
+
+		if err := m.scan(reader); err == nil || errors.Is(err, ErrScanTerminated) {
+			for _, e := range sem.selectMatches(m.Events, m.regexps, nil) {
+				if n >= 0 && len(locs) == n {
+					break
+				}
+
+				locs = append(locs, []int{int(e.From), int(e.To)})
+			}
+		}
+
+		return
+	}
+
 	m.n = n
 
 	if err := m.scan(reader); (err == nil || errors.Is(err, ErrScanTerminated)) && len(m.Events) > 0 {
@@ -322,6 +619,10 @@ func (m *streamMatcher) Match(reader io.Reader) bool {
 var _ StreamCompressor = (*streamDatabase)(nil)
 
 func (db *streamDatabase) Compress(s Stream) ([]byte, error) {
+	if len(db.shards) > 0 {
+		return nil, fmt.Errorf("compress stream of %d shards, %w", db.Shards(), ErrInvalid)
+	}
+
 	size, err := db.StreamSize()
 	if err != nil {
 		return nil, fmt.Errorf("stream size, %w", err)
@@ -341,6 +642,18 @@ func (db *streamDatabase) Compress(s Stream) ([]byte, error) {
 func (db *streamDatabase) Expand(buf []byte, flags ScanFlag, sc *Scratch,
 	handler MatchHandler, context interface{},
 ) (Stream, error) {
+	if len(db.shards) > 0 {
+		return nil, fmt.Errorf("expand stream of %d shards, %w", db.Shards(), ErrInvalid)
+	}
+
+	if err := checkLive("expand stream of", db.leak, sc); err != nil {
+		return nil, err
+	}
+
+	if err := sc.check(db.db, "expand stream"); err != nil {
+		return nil, err
+	}
+
 	var s hs.Stream
 
 	err := hs.ExpandStream(db.db, &s, buf)
@@ -351,7 +664,7 @@ func (db *streamDatabase) Expand(buf []byte, flags ScanFlag, sc *Scratch,
 	ownedScratch := false
 
 	if sc == nil {
-		sc, err = NewScratch(db)
+		sc, err = newScratch(db)
 		if err != nil {
 			return nil, fmt.Errorf("create scratch, %w", err)
 		}
@@ -359,23 +672,35 @@ func (db *streamDatabase) Expand(buf []byte, flags ScanFlag, sc *Scratch,
 		ownedScratch = true
 	}
 
-	return &stream{s, flags, sc.s, handler, context, ownedScratch}, nil
+	return db.newStream(s, flags, sc, handler, context, ownedScratch), nil
 }
 
 func (db *streamDatabase) ResetAndExpand(s Stream, buf []byte, flags ScanFlag, sc *Scratch,
 	handler MatchHandler, context interface{},
 ) (Stream, error) {
 	ss, ok := s.(*stream)
-	if !ok {
+	if !ok || len(db.shards) > 0 {
 		return nil, fmt.Errorf("stream %v, %w", s, ErrInvalid)
 	}
 
+	if err := checkLive("reset and expand stream of", db.leak, sc); err != nil {
+		return nil, err
+	}
+
+	if err := sc.check(db.db, "reset and expand stream"); err != nil {
+		return nil, err
+	}
+
+	if err := ss.check("reset and expand"); err != nil {
+		return nil, err
+	}
+
 	ownedScratch := false
 
 	if sc == nil {
 		var err error
 
-		sc, err = NewScratch(db)
+		sc, err = newScratch(db)
 		if err != nil {
 			return nil, fmt.Errorf("create scratch, %w", err)
 		}
@@ -388,5 +713,8 @@ func (db *streamDatabase) ResetAndExpand(s Stream, buf []byte, flags ScanFlag, s
 		return nil, fmt.Errorf("reset and expand stream, %w", err)
 	}
 
-	return &stream{ss.stream, flags, sc.s, handler, context, ownedScratch}, nil
+	// The returned stream takes over the state of the given stream.
+	_ = ss.leak.release("reset and expand")
+
+	return db.newStream(ss.stream, flags, sc, handler, context, ownedScratch), nil
 }
@@ -0,0 +1,204 @@
+package hyperscan
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+The syntax of a pattern (or a literal) is
+
+	pattern    = [ id ":" ] body
+	body       = "/" expression "/" suffix
+	           | "m" delimiter expression delimiter suffix
+	           | expression
+	suffix     = { flag } [ "{" extensions "}" ]
+	id         = [ "-" ] digit { digit }
+
+The closing delimiter is the last one followed by a valid suffix, so the expression could contain the delimiter
+with or without the escaping backslash. The escaped `\/` of the `/` form is unescaped,
+e.g. `/https?:\/\//` and `/https?:///` are the same expression `https?://`.
+The delimiter of the `m` form is any character except the letters, digits, spaces, backslash and the ones used
+by the suffix (`{`, `}`, `=`, `,` and `_`), e.g. `m#https?://#i`, and the expression is kept verbatim.
+
+An expression is formatted in the `/` form with each `/` escaped, or in the `m` form if it contains `\/`,
+which would be unescaped by the `/` form.
+
+If no `/` is followed by a valid suffix, the last one closes the expression and the suffix is reported as invalid.
+A string without delimiters is an expression without flags and extensions.
+*/
+
+var patternSuffix = regexp.MustCompile(`^[A-Za-z0-9]*(\{[^{}]*\})?$`)
+
+// splitPattern splits a formatted string into the ID, expression, flags and extensions.
+func splitPattern(s string) (id int, expr, flags, ext string, err error) {
+	body := s
+
+	if i := strings.Index(s, ":"); i > 0 {
+		if n, err := strconv.Atoi(s[:i]); err == nil && isDelimited(s[i+1:]) {
+			id, body = n, s[i+1:]
+		} else if isDelimited(s[i+1:]) && !isDelimited(s) {
+			return 0, "", "", "", fmt.Errorf("pattern id `%s`, %w", s[:i], ErrInvalid)
+		}
+	}
+
+	open, delim, ok := delimiters(body)
+	if !ok {
+		return 0, s, "", "", nil
+	}
+
+	expr, suffix, _ := cutLast(body[open:], delim)
+
+	if delim == '/' {
+		expr = unescapeSlash(expr)
+	}
+
+	if n := strings.Index(suffix, "{"); n >= 0 {
+		flags, ext = suffix[:n], suffix[n:]
+	} else {
+		flags = suffix
+	}
+
+	return id, expr, flags, ext, nil
+}
+
+// isDelimited reports whether s is a delimited body with a valid suffix.
+func isDelimited(s string) bool {
+	_, _, ok := delimiters(s)
+
+	return ok
+}
+
+// delimiters returns the offset of the expression and the closing delimiter of a delimited body.
+func delimiters(s string) (open int, delim rune, ok bool) {
+	switch {
+	case strings.HasPrefix(s, "/"):
+		open, delim = 1, '/'
+
+	case strings.HasPrefix(s, "m"):
+		r, n := utf8.DecodeRuneInString(s[1:])
+		if !isDelimiter(r) {
+			return 0, 0, false
+		}
+
+		open, delim = 1+n, r
+
+	default:
+		return 0, 0, false
+	}
+
+	_, _, ok = cutLast(s[open:], delim)
+
+	return open, delim, ok
+}
+
+func isDelimiter(r rune) bool {
+	return r != utf8.RuneError && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) &&
+		!strings.ContainsRune(`\{}=,_`, r)
+}
+
+// cutLast slices s around the last delimiter followed by a valid suffix,
+// or the last `/` if none.
+func cutLast(s string, delim rune) (expr, suffix string, ok bool) {
+	for i := strings.LastIndex(s, string(delim)); i >= 0; i = strings.LastIndex(s[:i], string(delim)) {
+		if suffix := s[i+utf8.RuneLen(delim):]; patternSuffix.MatchString(suffix) {
+			return s[:i], suffix, true
+		}
+	}
+
+	if i := strings.LastIndex(s, "/"); delim == '/' && i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+
+	return "", "", false
+}
+
+// unescapeSlash replaces the escaped `\/` with `/`, and keeps the other escaped characters.
+func unescapeSlash(s string) string {
+	if !strings.Contains(s, `\/`) {
+		return s
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			if s[i+1] != '/' {
+				b.WriteByte('\\')
+			}
+
+			i++
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// escapeSlash escapes each `/`, or returns false if s contains the escaped `\/` which would be unescaped.
+func escapeSlash(s string) (string, bool) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			if s[i+1] == '/' {
+				return "", false
+			}
+
+			b.WriteString(s[i : i+2])
+			i++
+
+		case s[i] == '/':
+			b.WriteString(`\/`)
+
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String(), true
+}
+
+// altDelimiter returns a delimiter of the `m` form which isn't contained in the expression.
+func altDelimiter(expr string) rune {
+	for _, r := range "#!|%~@;" {
+		if !strings.ContainsRune(expr, r) {
+			return r
+		}
+	}
+
+	for r := '¡'; ; r++ {
+		if isDelimiter(r) && !strings.ContainsRune(expr, r) {
+			return r
+		}
+	}
+}
+
+// formatPattern formats the ID, expression, flags and extensions as a string which is parsed back to them.
+func formatPattern(id int, expr string, flags CompileFlag, ext *ExprExt) string {
+	var b strings.Builder
+
+	if id != 0 {
+		fmt.Fprintf(&b, "%d:", id)
+	}
+
+	if escaped, exact := escapeSlash(expr); exact {
+		fmt.Fprintf(&b, "/%s/%s", escaped, flags)
+	} else {
+		delim := altDelimiter(expr)
+
+		fmt.Fprintf(&b, "m%c%s%c%s", delim, expr, delim, flags)
+	}
+
+	if ext != nil {
+		b.WriteString(ext.String())
+	}
+
+	return b.String()
+}
@@ -0,0 +1,199 @@
+package hyperscan
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// PatternError is the error of a pattern which failed to compile.
+type PatternError struct {
+	*Pattern
+
+	// The index of the pattern in the pattern set.
+	Index int
+
+	// The error returned by the compiler.
+	Err error
+}
+
+func (e *PatternError) Error() string {
+	msg := fmt.Sprintf("pattern %d `%s` at index %d, %s", e.Id, e.Expression, e.Index, e.Err)
+
+	if e.Pos.IsValid() {
+		msg = e.Pos.String() + ": " + msg
+	}
+
+	return msg
+}
+
+func (e *PatternError) Unwrap() error { return e.Err }
+
+// ValidationError lists all the patterns which failed to compile.
+//
+// It implements `Unwrap() []error` like the error returned by errors.Join.
+type ValidationError struct {
+	Errors []*PatternError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the failing patterns.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// Validate checks every pattern individually in the block mode,
+// and returns a *ValidationError listing all the failing patterns, or nil if the whole set compiles.
+func (p Patterns) Validate() error {
+	b := DatabaseBuilder{Patterns: p}
+
+	return b.Validate()
+}
+
+// Validate checks every pattern individually, in parallel, with the mode and platform of the builder,
+// then bisects the failure of the whole set to find the patterns which failed only with the others.
+//
+// It returns a *ValidationError listing all the failing patterns, or nil if the whole set compiles.
+func (b *DatabaseBuilder) Validate() error {
+	if len(b.Patterns) == 0 {
+		return ErrInvalid
+	}
+
+	compile := b.compileOnly()
+
+	errs := b.validateEach(compile)
+
+	var valid []int
+
+	for i := range b.Patterns {
+		if errs[i] == nil {
+			valid = append(valid, i)
+		}
+	}
+
+	for _, err := range bisect(b.Patterns, valid, compile) {
+		errs[err.Index] = err
+	}
+
+	var failed []*PatternError
+
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return &ValidationError{failed}
+}
+
+// compileOnly returns the function which compiles the patterns with the mode and platform of the builder,
+// and frees the compiled database.
+func (b *DatabaseBuilder) compileOnly() func(Patterns) error {
+	mode := b.mode()
+	platform, _ := b.Platform.(*hs.PlatformInfo)
+
+	return func(patterns Patterns) error {
+		db, err := hs.CompileMulti(patterns, mode, platform)
+		if err != nil {
+			return err //nolint: wrapcheck
+		}
+
+		return hs.FreeDatabase(db) //nolint: wrapcheck
+	}
+}
+
+// validateEach compiles each pattern individually, in parallel.
+func (b *DatabaseBuilder) validateEach(compile func(Patterns) error) []*PatternError {
+	errs := make([]*PatternError, len(b.Patterns))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+
+	for n := 0; n < runtime.GOMAXPROCS(0); n++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if err := compile(Patterns{b.Patterns[i]}); err != nil {
+					errs[i] = &PatternError{b.Patterns[i], i, err}
+				}
+			}
+		}()
+	}
+
+	for i := range b.Patterns {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return errs
+}
+
+// bisect finds the patterns which failed to compile with the others,
+// the set compiles without them.
+func bisect(all Patterns, valid []int, compile func(Patterns) error) (errs []*PatternError) {
+	subset := func(n int) Patterns {
+		patterns := make(Patterns, n)
+
+		for i := range patterns {
+			patterns[i] = all[valid[i]]
+		}
+
+		return patterns
+	}
+
+	for len(valid) > 0 {
+		err := compile(subset(len(valid)))
+		if err == nil {
+			break
+		}
+
+		var compileErr *CompileError
+
+		var culprit int
+
+		if errors.As(err, &compileErr) && compileErr.Expression >= 0 && compileErr.Expression < len(valid) {
+			culprit = compileErr.Expression
+		} else {
+			// the last pattern of the shortest prefix which fails to compile.
+			culprit = sort.Search(len(valid)-1, func(n int) bool { return compile(subset(n+1)) != nil })
+
+			if culprit < len(valid)-1 {
+				err = compile(subset(culprit + 1))
+			}
+		}
+
+		errs = append(errs, &PatternError{all[valid[culprit]], valid[culprit], err})
+		valid = append(valid[:culprit:culprit], valid[culprit+1:]...)
+	}
+
+	return errs
+}
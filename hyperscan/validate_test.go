@@ -0,0 +1,61 @@
+package hyperscan_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Given a pattern set with some broken patterns", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `foo`, Id: 1},
+			{Expression: `a(`, Id: 2},
+			{Expression: `bar\d+`, Flags: hyperscan.SomLeftMost, Id: 3},
+			{Expression: `(?U)x+`, Id: 4},
+		}
+
+		Convey("When validate the patterns", func() {
+			err := patterns.Validate()
+
+			Convey("Then all the failing patterns are reported", func() {
+				var validationErr *hyperscan.ValidationError
+
+				So(errors.As(err, &validationErr), ShouldBeTrue)
+				So(validationErr.Errors, ShouldHaveLength, 2)
+				So(validationErr.Unwrap(), ShouldHaveLength, 2)
+
+				So(validationErr.Errors[0].Id, ShouldEqual, 2)
+				So(validationErr.Errors[0].Index, ShouldEqual, 1)
+				So(validationErr.Errors[0].Error(), ShouldStartWith, "pattern 2 `a(` at index 1, ")
+
+				So(validationErr.Errors[1].Id, ShouldEqual, 4)
+				So(validationErr.Errors[1].Index, ShouldEqual, 3)
+
+				var compileErr *hyperscan.CompileError
+
+				So(errors.As(validationErr.Errors[1], &compileErr), ShouldBeTrue)
+			})
+		})
+
+		Convey("When validate the patterns for the streaming mode", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode}
+
+			var validationErr *hyperscan.ValidationError
+
+			So(errors.As(b.Validate(), &validationErr), ShouldBeTrue)
+			So(validationErr.Errors, ShouldHaveLength, 2)
+		})
+
+		Convey("When validate the valid patterns", func() {
+			So(hyperscan.Patterns{patterns[0], patterns[2]}.Validate(), ShouldBeNil)
+		})
+	})
+
+	Convey("Given an empty pattern set", t, func() {
+		So(hyperscan.Patterns{}.Validate(), ShouldEqual, hyperscan.ErrInvalid)
+	})
+}
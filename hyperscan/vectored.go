@@ -1,6 +1,10 @@
 package hyperscan
 
-import "github.com/flier/gohs/internal/hs"
+import (
+	"bytes"
+
+	"github.com/flier/gohs/internal/hs"
+)
 
 // VectoredScanner is the vectored regular expression scanner.
 type VectoredScanner interface {
@@ -28,15 +32,20 @@ func newVectoredDatabase(db hs.Database) *vectoredDatabase {
 
 type vectoredScanner struct {
 	*baseDatabase
+	opts *scanOptions
 }
 
 func newVectoredScanner(vdb *baseDatabase) *vectoredScanner {
-	return &vectoredScanner{vdb}
+	return &vectoredScanner{baseDatabase: vdb}
 }
 
 func (vs *vectoredScanner) Scan(data [][]byte, s *Scratch, handler MatchHandler, context interface{}) (err error) {
+	if err = checkLive("scan", vs.leak, s); err != nil {
+		return
+	}
+
 	if s == nil {
-		s, err = NewScratch(vs)
+		s, err = newScratch(vs)
 
 		if err != nil {
 			return
@@ -47,7 +56,56 @@ func (vs *vectoredScanner) Scan(data [][]byte, s *Scratch, handler MatchHandler,
 		}()
 	}
 
-	return hs.ScanVector(vs.db, data, 0, s.s, handler, context) //nolint: wrapcheck
+	release, err := s.acquire(vs.db, "scan")
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	f := vs.opts.newFilter(vs.ids)
+	if f != nil {
+		handler = f.handler(handler)
+	}
+
+	v := vs.opts.newVerification(vs.baseDatabase, 0)
+
+	var joined []byte
+
+	if v != nil || len(vs.fallbacks) > 0 {
+		joined = bytes.Join(data, nil)
+	}
+
+	if v != nil {
+		v.data = joined
+		handler = v.handler(handler)
+	}
+
+	m := vs.fallbacks.newMerge(joined)
+
+	if len(vs.shards) > 0 {
+		var events []hs.MatchEvent
+
+		if events, err = vs.scanShards(s, func(db hs.Database, scratch hs.Scratch, handler MatchHandler) error {
+			return hs.ScanVector(db, data, 0, scratch, handler, nil)
+		}); err != nil {
+			return err
+		}
+
+		m = m.with(events)
+	}
+
+	if m != nil {
+		err = m.flush(handler, context, hs.ScanVector(vs.db, data, 0, s.s, m.handler(handler), context))
+	} else {
+		err = hs.ScanVector(vs.db, data, 0, s.s, handler, context)
+	}
+
+	if f != nil {
+		err = f.result(err)
+	}
+
+	return err //nolint: wrapcheck
 }
 
 type vectoredMatcher struct {
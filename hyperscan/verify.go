@@ -0,0 +1,249 @@
+package hyperscan
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/flier/gohs/internal/hs"
+)
+
+// Verifier confirms the candidate matches of the patterns compiled with PrefilterMode,
+// which are a superset of the matches of the original patterns.
+type Verifier interface {
+	// Verify reports whether the pattern with the ID matches data ending at the end of data,
+	// and returns the start of the leftmost such match.
+	Verify(id uint, data []byte) (from int, ok bool)
+}
+
+// VerifierFunc type is an adapter to allow the use of ordinary functions as Verifier.
+type VerifierFunc func(id uint, data []byte) (from int, ok bool)
+
+// Verify reports whether the pattern with the ID matches data ending at the end of data.
+func (f VerifierFunc) Verify(id uint, data []byte) (int, bool) { return f(id, data) }
+
+// NewVerifier compiles the patterns of a database built by DatabaseBuilder, which were compiled with PrefilterMode,
+// with the capture-capable engine, or the regexp package if compile is nil.
+//
+// The returned Verifier implements io.Closer to release the underlying engine.
+func NewVerifier(db Database, compile CaptureCompiler) (Verifier, error) {
+	base, ok := db.(interface{ base() *baseDatabase })
+	if !ok || base.base().patterns == nil {
+		return nil, fmt.Errorf("database without patterns, %w", ErrInvalid)
+	}
+
+	if compile == nil {
+		compile = StdCapturer
+	}
+
+	v := make(capturerVerifier)
+
+	for _, p := range base.base().patterns {
+		if p.Id < 0 || p.Flags&PrefilterMode != PrefilterMode {
+			continue
+		}
+
+		c, err := compile(p)
+		if err != nil {
+			_ = v.Close()
+
+			return nil, fmt.Errorf("compile pattern %d, %w", p.Id, err)
+		}
+
+		v[uint(p.Id)] = append(v[uint(p.Id)], c)
+	}
+
+	return v, nil
+}
+
+type capturerVerifier map[uint][]Capturer
+
+func (v capturerVerifier) Verify(id uint, data []byte) (int, bool) {
+	for _, c := range v[id] {
+		if loc := c.FindSubmatchIndex(data); loc != nil {
+			return loc[0], true
+		}
+	}
+
+	return 0, false
+}
+
+func (v capturerVerifier) Close() error {
+	for _, capturers := range v {
+		for _, c := range capturers {
+			if closer, ok := c.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+	}
+
+	return nil
+}
+
+// PrefilterStats counts the candidate matches of the prefilter patterns and the confirmed ones,
+// it is safe for concurrent use.
+type PrefilterStats struct {
+	hits      uint64
+	confirmed uint64
+}
+
+// Hits returns the number of candidate matches reported by the prefilter patterns.
+func (s *PrefilterStats) Hits() uint64 { return atomic.LoadUint64(&s.hits) }
+
+// Confirmed returns the number of candidate matches confirmed by the verifier.
+func (s *PrefilterStats) Confirmed() uint64 { return atomic.LoadUint64(&s.confirmed) }
+
+// Ratio returns the ratio of the prefilter hits to the confirmed matches, or 0 if nothing was confirmed.
+func (s *PrefilterStats) Ratio() float64 {
+	confirmed := s.Confirmed()
+	if confirmed == 0 {
+		return 0
+	}
+
+	return float64(s.Hits()) / float64(confirmed)
+}
+
+// Reset clears the counters.
+func (s *PrefilterStats) Reset() {
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.confirmed, 0)
+}
+
+// VerifyPrefilter verifies the candidate matches of the patterns compiled with PrefilterMode before reporting them,
+// and counts them in stats if not nil. The other patterns are reported as usual.
+//
+// The database must be built by DatabaseBuilder to know the prefilter patterns. The reported start of a confirmed match
+// is the one returned by the verifier, since Hyperscan doesn't report the start of match in PrefilterMode.
+// A candidate match is verified within the largest width of a match of the pattern if it's bounded,
+// or from the start of data otherwise. In streaming mode, only the last 64KiB of a stream is kept,
+// so a candidate match is verified within the last 64KiB and a longer match could not be confirmed.
+func VerifyPrefilter(v Verifier, stats *PrefilterStats) ScanOption {
+	return func(opts *scanOptions) {
+		opts.verifier = v
+		opts.stats = stats
+	}
+}
+
+// verification verifies the candidate matches of the prefilter patterns during a scan or a stream.
+type verification struct {
+	verifier   Verifier
+	stats      *PrefilterStats
+	prefilters bitmap
+	widths     map[uint]int // the bounded widths of the prefilter patterns.
+	data       []byte       // the scanned data, or the history of a stream.
+	offset     uint64       // the offset of data in the stream.
+	window     int          // the bound of a verified match in a stream, or 0 if unbounded.
+}
+
+// newVerification returns a verification for a new scan of the database, or nil if there is nothing to verify,
+// which verifies the candidate matches within the window, or the whole data if window is 0.
+func (o *scanOptions) newVerification(d *baseDatabase, window int) *verification {
+	if o == nil || o.verifier == nil || d.prefilters.count() == 0 {
+		return nil
+	}
+
+	return &verification{
+		verifier: o.verifier, stats: o.stats, prefilters: d.prefilters, widths: d.widths, window: window,
+	}
+}
+
+func (v *verification) handler(next MatchHandler) MatchHandler {
+	return func(id uint, from, to uint64, flags uint, context interface{}) error {
+		if v.prefilters.has(id) {
+			if v.stats != nil {
+				atomic.AddUint64(&v.stats.hits, 1)
+			}
+
+			if to < v.offset || to-v.offset > uint64(len(v.data)) {
+				return nil
+			}
+
+			end, start := int(to-v.offset), 0
+
+			if w, bounded := v.widths[id]; bounded && end-start > w {
+				start = end - w
+			}
+
+			if v.window > 0 && end-start > v.window {
+				start = end - v.window
+			}
+
+			at, ok := v.verifier.Verify(id, v.data[start:end])
+			if !ok {
+				return nil
+			}
+
+			if v.stats != nil {
+				atomic.AddUint64(&v.stats.confirmed, 1)
+			}
+
+			from = v.offset + uint64(start+at)
+		}
+
+		if next == nil {
+			return nil
+		}
+
+		return next(id, from, to, flags, context)
+	}
+}
+
+// append appends the data of a stream to the history, which keeps the previous data within the window.
+func (v *verification) append(data []byte) {
+	if n := len(v.data) - historySize; n > 0 {
+		v.data = append(v.data[:0], v.data[n:]...)
+		v.offset += uint64(n)
+	}
+
+	v.data = append(v.data, data...)
+}
+
+func (v *verification) reset() {
+	v.data = v.data[:0]
+	v.offset = 0
+}
+
+func (v *verification) clone() *verification {
+	cloned := *v
+	cloned.data = append([]byte(nil), v.data...)
+
+	return &cloned
+}
+
+// prefilterWidths returns the largest width of a match of each pattern compiled with PrefilterMode,
+// except the patterns with an unbounded width.
+func (p Patterns) prefilterWidths() map[uint]int {
+	widths := make(map[uint]int)
+	unbounded := make(map[uint]bool)
+
+	for _, pat := range p {
+		if pat.Id < 0 || pat.Flags&PrefilterMode != PrefilterMode {
+			continue
+		}
+
+		id := uint(pat.Id)
+
+		if info, err := pat.Info(); err != nil || info.MaxWidth == hs.UnboundedMaxWidth {
+			unbounded[id] = true
+		} else if w := int(info.MaxWidth); w > widths[id] {
+			widths[id] = w
+		}
+	}
+
+	for id := range unbounded {
+		delete(widths, id)
+	}
+
+	return widths
+}
+
+// prefilters returns the IDs of the patterns compiled with PrefilterMode.
+func (p Patterns) prefilters() (ids bitmap) {
+	for _, pat := range p {
+		if pat.Id >= 0 && pat.Flags&PrefilterMode == PrefilterMode {
+			ids.set(uint(pat.Id))
+		}
+	}
+
+	return
+}
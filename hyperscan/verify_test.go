@@ -0,0 +1,260 @@
+package hyperscan_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/hyperscan"
+)
+
+// backref verifies the matches of `(\w+)=\1` ending at the end of data.
+func backref(id uint, data []byte) (int, bool) {
+	isWord := func(b []byte) bool {
+		for _, c := range b {
+			if !(c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+				return false
+			}
+		}
+
+		return len(b) > 0
+	}
+
+	for i := range data {
+		if parts := bytes.SplitN(data[i:], []byte("="), 2); len(parts) == 2 &&
+			isWord(parts[0]) && bytes.Equal(parts[0], parts[1]) {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+//nolint:funlen
+func TestVerifyPrefilter(t *testing.T) {
+	Convey("Given a database with a prefilter pattern", t, func() {
+		patterns := hyperscan.Patterns{
+			{Expression: `(\w+)=\1`, Flags: hyperscan.PrefilterMode, Id: 1},
+			{Expression: `foo`, Flags: hyperscan.SomLeftMost, Id: 2},
+		}
+
+		type match struct {
+			id       uint
+			from, to uint64
+		}
+
+		var matches []match
+
+		handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+			matches = append(matches, match{id, from, to})
+
+			return nil
+		}
+
+		var stats hyperscan.PrefilterStats
+
+		Convey("When scan a block with a verifier function", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns}
+
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			v, err := hyperscan.WithScanOptions(db,
+				hyperscan.VerifyPrefilter(hyperscan.VerifierFunc(backref), &stats))
+			So(err, ShouldBeNil)
+
+			So(v.(hyperscan.BlockScanner).Scan([]byte("a=b abc=abc foo"), nil, handler, nil), ShouldBeNil)
+			So(matches, ShouldResemble, []match{{1, 4, 11}, {2, 12, 15}})
+
+			So(stats.Confirmed(), ShouldEqual, 1)
+			So(stats.Hits(), ShouldBeGreaterThanOrEqualTo, stats.Confirmed())
+			So(stats.Ratio(), ShouldBeGreaterThanOrEqualTo, 1)
+
+			Convey("Then the original database reports the candidates", func() {
+				matches = nil
+
+				So(db.(hyperscan.BlockScanner).Scan([]byte("a=b"), nil, handler, nil), ShouldBeNil)
+				So(matches, ShouldNotBeEmpty)
+			})
+
+			Convey("Then the stats could be reset", func() {
+				stats.Reset()
+
+				So(stats.Hits(), ShouldBeZeroValue)
+				So(stats.Ratio(), ShouldBeZeroValue)
+			})
+		})
+
+		Convey("When scan a stream with a verifier function", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode}
+
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			v, err := hyperscan.WithScanOptions(db,
+				hyperscan.VerifyPrefilter(hyperscan.VerifierFunc(backref), &stats))
+			So(err, ShouldBeNil)
+
+			s, err := v.(hyperscan.StreamScanner).Open(0, nil, handler, nil)
+			So(err, ShouldBeNil)
+
+			So(s.Scan([]byte("a=b ab")), ShouldBeNil)
+			So(s.Scan([]byte("c=a")), ShouldBeNil)
+			So(s.Scan([]byte("bc foo")), ShouldBeNil)
+			So(s.Close(), ShouldBeNil)
+
+			So(matches, ShouldResemble, []match{{1, 4, 11}, {2, 12, 15}})
+			So(stats.Confirmed(), ShouldEqual, 1)
+		})
+	})
+
+	Convey("Given a prefilter pattern matching more than 64KiB", t, func() {
+		patterns := hyperscan.Patterns{{Expression: `a[^b]*b`, Flags: hyperscan.PrefilterMode, Id: 1}}
+		data := append(append([]byte("xa"), bytes.Repeat([]byte("x"), 1<<16)...), 'b')
+
+		// span verifies the matches of `a[^b]*b` ending at the end of data.
+		span := hyperscan.VerifierFunc(func(id uint, data []byte) (int, bool) {
+			i := bytes.LastIndexByte(data[:len(data)-1], 'b')
+
+			j := bytes.IndexByte(data[i+1:], 'a')
+
+			return i + 1 + j, j >= 0 && data[len(data)-1] == 'b'
+		})
+
+		var matches [][2]uint64
+
+		handler := func(id uint, from, to uint64, flags uint, context interface{}) error {
+			matches = append(matches, [2]uint64{from, to})
+
+			return nil
+		}
+
+		Convey("When scan a block", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns}
+
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			v, err := hyperscan.WithScanOptions(db, hyperscan.VerifyPrefilter(span, nil))
+			So(err, ShouldBeNil)
+
+			Convey("Then the match is verified from the start of data", func() {
+				So(v.(hyperscan.BlockScanner).Scan(data, nil, handler, nil), ShouldBeNil)
+				So(matches, ShouldResemble, [][2]uint64{{1, uint64(len(data))}})
+			})
+		})
+
+		Convey("When scan a stream", func() {
+			b := hyperscan.DatabaseBuilder{Patterns: patterns, Mode: hyperscan.StreamMode}
+
+			db, err := b.Build()
+			So(err, ShouldBeNil)
+
+			defer db.Close()
+
+			v, err := hyperscan.WithScanOptions(db, hyperscan.VerifyPrefilter(span, nil))
+			So(err, ShouldBeNil)
+
+			s, err := v.(hyperscan.StreamScanner).Open(0, nil, handler, nil)
+			So(err, ShouldBeNil)
+
+			Convey("Then the match is verified within the last 64KiB", func() {
+				for chunk := data; len(chunk) > 0; {
+					n := 1 << 12
+					if n > len(chunk) {
+						n = len(chunk)
+					}
+
+					So(s.Scan(chunk[:n]), ShouldBeNil)
+
+					chunk = chunk[n:]
+				}
+
+				So(s.Close(), ShouldBeNil)
+				So(matches, ShouldBeEmpty)
+			})
+		})
+	})
+
+	Convey("Given a prefilter pattern with a bounded width", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{{Expression: `a.{0,3}b`, Flags: hyperscan.PrefilterMode, Id: 1}},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		var verified []int
+
+		// tail verifies the matches of `a.{0,3}b` ending at the end of data, and records the verified length.
+		tail := hyperscan.VerifierFunc(func(id uint, data []byte) (int, bool) {
+			verified = append(verified, len(data))
+
+			i := bytes.LastIndexByte(data, 'a')
+
+			return i, i >= 0 && len(data)-i <= 5
+		})
+
+		v, err := hyperscan.WithScanOptions(db, hyperscan.VerifyPrefilter(tail, nil))
+		So(err, ShouldBeNil)
+
+		Convey("When scan a block", func() {
+			var matches [][2]uint64
+
+			data := append(bytes.Repeat([]byte("x"), 1000), "axb"...)
+
+			So(v.(hyperscan.BlockScanner).Scan(data, nil,
+				func(id uint, from, to uint64, flags uint, context interface{}) error {
+					matches = append(matches, [2]uint64{from, to})
+
+					return nil
+				}, nil), ShouldBeNil)
+
+			Convey("Then the candidate is verified within the width of pattern", func() {
+				So(matches, ShouldResemble, [][2]uint64{{1000, 1003}})
+				So(verified, ShouldResemble, []int{5})
+			})
+		})
+	})
+
+	Convey("Given a database with a prefilter pattern supported by regexp", t, func() {
+		b := hyperscan.DatabaseBuilder{
+			Patterns: hyperscan.Patterns{
+				{Expression: `\d{3}-\d{4}`, Flags: hyperscan.PrefilterMode, Id: 1},
+			},
+		}
+
+		db, err := b.Build()
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		verifier, err := hyperscan.NewVerifier(db, nil)
+		So(err, ShouldBeNil)
+
+		v, err := hyperscan.WithScanOptions(db, hyperscan.VerifyPrefilter(verifier, nil))
+		So(err, ShouldBeNil)
+
+		So(v.(hyperscan.BlockDatabase).FindAllStringIndex("call 555-1234 or 12-345", -1), ShouldResemble,
+			[][]int{{5, 13}})
+	})
+
+	Convey("Given a database without patterns", t, func() {
+		db, err := hyperscan.Compile(`\d+`)
+		So(err, ShouldBeNil)
+
+		defer db.Close()
+
+		_, err = hyperscan.NewVerifier(db, nil)
+		So(err, ShouldNotBeNil)
+	})
+}
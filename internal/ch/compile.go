@@ -18,6 +18,7 @@ type Pattern struct {
 	Expression string      // The expression to parse.
 	Flags      CompileFlag // Flags which modify the behaviour of the expression.
 	ID         int         // The ID number to be associated with the corresponding pattern
+	Pos        hs.Position // The position of the pattern in the source file, if any.
 }
 
 // NewPattern returns a new pattern base on expression and compile flags.
@@ -0,0 +1,155 @@
+// Package guard checks the use of the scratch spaces shared by Hyperscan and Chimera.
+package guard
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Checker creates the guards of the scratch spaces while it is enabled.
+type Checker struct {
+	enabled int32
+
+	// InUse is the error wrapped if a scratch space is used by two callers at the same time.
+	InUse error
+	// Invalid is the error wrapped if a scratch space wasn't allocated for the database.
+	Invalid error
+}
+
+// Set enables or disables the checker, and returns whether it was enabled.
+func (c *Checker) Set(enabled bool) bool {
+	var v int32
+
+	if enabled {
+		v = 1
+	}
+
+	return atomic.SwapInt32(&c.enabled, v) != 0
+}
+
+// Enabled returns whether the checker is enabled.
+func (c *Checker) Enabled() bool { return atomic.LoadInt32(&c.enabled) != 0 }
+
+// New returns the guard of a scratch space allocated for the database, or nil if the checker is disabled.
+func (c *Checker) New(db interface{}) *Guard {
+	if !c.Enabled() {
+		return nil
+	}
+
+	return &Guard{checker: c, dbs: map[interface{}][]uintptr{db: Callers()}, last: db}
+}
+
+// Guard records the databases which a scratch space was allocated for, and the caller which holds it.
+//
+// All the methods of a nil guard do nothing.
+type Guard struct {
+	checker *Checker
+
+	lock     sync.Mutex
+	dbs      map[interface{}][]uintptr // The databases with the stack where the scratch was allocated for them.
+	last     interface{}               // The last database which the scratch was allocated for.
+	owner    int64                     // The goroutine which holds the scratch, or zero.
+	acquired []uintptr                 // The stack where the scratch was acquired.
+}
+
+// Realloc records that the scratch space was reallocated for the database.
+func (g *Guard) Realloc(db interface{}) {
+	if g == nil {
+		return
+	}
+
+	pcs := Callers()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.dbs[db] = pcs
+	g.last = db
+}
+
+// Clone returns the guard of a clone of the scratch space.
+func (g *Guard) Clone() *Guard {
+	if g == nil {
+		return nil
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	dbs := make(map[interface{}][]uintptr, len(g.dbs))
+
+	for db, pcs := range g.dbs {
+		dbs[db] = pcs
+	}
+
+	return &Guard{checker: g.checker, dbs: dbs, last: g.last}
+}
+
+// Check returns an error if the scratch space wasn't allocated for the database.
+func (g *Guard) Check(db interface{}, op string) error {
+	if g == nil {
+		return nil
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if _, ok := g.dbs[db]; ok {
+		return nil
+	}
+
+	return fmt.Errorf("%s with scratch allocated for %d other databases, last allocated at\n%scalled at\n%s%w",
+		op, len(g.dbs), FormatStack(g.dbs[g.last]), FormatStack(Callers()), g.checker.Invalid)
+}
+
+// Acquire holds the scratch space until the returned function is called,
+// or returns an error if another caller holds it.
+func (g *Guard) Acquire(op string) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+
+	id := goid()
+	pcs := Callers()
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.owner != 0 {
+		return nil, fmt.Errorf("%s with scratch in use by goroutine %d, acquired at\n%scalled by goroutine %d at\n%s%w",
+			op, g.owner, FormatStack(g.acquired), id, FormatStack(pcs), g.checker.InUse)
+	}
+
+	g.owner = id
+	g.acquired = pcs
+
+	return g.release, nil
+}
+
+func (g *Guard) release() {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.owner = 0
+	g.acquired = nil
+}
+
+// goid returns the ID of the current goroutine, parsed from the header of its stack trace.
+func goid() int64 {
+	var buf [64]byte
+
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+
+	id, _ := strconv.ParseInt(string(b), 10, 64)
+
+	return id
+}
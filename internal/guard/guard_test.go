@@ -0,0 +1,91 @@
+package guard_test
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/internal/guard"
+)
+
+var (
+	errInUse   = errors.New("in use")
+	errInvalid = errors.New("invalid")
+)
+
+//nolint:funlen
+func TestGuard(t *testing.T) {
+	Convey("Given a disabled checker", t, func() {
+		c := &guard.Checker{InUse: errInUse, Invalid: errInvalid}
+
+		So(c.Enabled(), ShouldBeFalse)
+
+		Convey("Then the guards are nil and check nothing", func() {
+			g := c.New("foo")
+			So(g, ShouldBeNil)
+			So(g.Check("bar", "scan"), ShouldBeNil)
+
+			release, err := g.Acquire("scan")
+			So(err, ShouldBeNil)
+			release()
+		})
+	})
+
+	Convey("Given the guard of a scratch allocated for a database", t, func() {
+		c := &guard.Checker{InUse: errInUse, Invalid: errInvalid}
+
+		So(c.Set(true), ShouldBeFalse)
+		So(c.Enabled(), ShouldBeTrue)
+
+		g := c.New("foo")
+		So(g, ShouldNotBeNil)
+		So(g.Check("foo", "scan"), ShouldBeNil)
+
+		Convey("When check it for another database", func() {
+			err := g.Check("bar", "scan")
+
+			Convey("Then it fails with both call sites", func() {
+				So(errors.Is(err, errInvalid), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "scan with scratch allocated for 1 other databases")
+				So(err.Error(), ShouldContainSubstring, "last allocated at")
+				So(err.Error(), ShouldContainSubstring, "called at")
+			})
+
+			Convey("Then it passes once reallocated", func() {
+				g.Realloc("bar")
+
+				So(g.Check("bar", "scan"), ShouldBeNil)
+				So(g.Check("foo", "scan"), ShouldBeNil)
+				So(g.Clone().Check("bar", "scan"), ShouldBeNil)
+			})
+		})
+
+		Convey("When acquire it twice", func() {
+			release, err := g.Acquire("scan")
+			So(err, ShouldBeNil)
+
+			_, err = g.Acquire("scan")
+
+			Convey("Then it fails with both call sites", func() {
+				So(errors.Is(err, errInUse), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, "scan with scratch in use by goroutine")
+				So(err.Error(), ShouldContainSubstring, "TestGuard")
+			})
+
+			Convey("Then the clone is not held", func() {
+				release, err := g.Clone().Acquire("scan")
+				So(err, ShouldBeNil)
+				release()
+			})
+
+			Convey("Then it could be acquired once released", func() {
+				release()
+
+				release, err := g.Acquire("scan")
+				So(err, ShouldBeNil)
+				release()
+			})
+		})
+	})
+}
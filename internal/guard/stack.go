@@ -0,0 +1,39 @@
+package guard
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+const maxStackDepth = 32
+
+// Callers returns the stack of the function calling Callers, without the function itself.
+func Callers() []uintptr {
+	pcs := make([]uintptr, maxStackDepth)
+
+	return pcs[:runtime.Callers(3, pcs)] //nolint: gomnd
+}
+
+// FormatStack formats a stack returned by Callers, one frame per function and its location.
+func FormatStack(pcs []uintptr) string {
+	if len(pcs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+
+	frames := runtime.CallersFrames(pcs)
+
+	for {
+		frame, more := frames.Next()
+
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+
+		if !more {
+			break
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,27 @@
+package guard_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/flier/gohs/internal/guard"
+)
+
+func caller() []uintptr { return guard.Callers() }
+
+func TestStack(t *testing.T) {
+	Convey("Given the stack of a function", t, func() {
+		stack := guard.FormatStack(caller())
+
+		Convey("It starts with the caller of the function", func() {
+			So(stack, ShouldStartWith, "github.com/flier/gohs/internal/guard_test.TestStack.func1\n\t")
+			So(stack, ShouldContainSubstring, "stack_test.go:")
+			So(stack, ShouldNotContainSubstring, "guard_test.caller")
+		})
+	})
+
+	Convey("Given an empty stack", t, func() {
+		So(guard.FormatStack(nil), ShouldBeEmpty)
+	})
+}
@@ -38,7 +38,7 @@ func SerializeDatabase(db Database) (b []byte, err error) {
 	if ret != C.HS_SUCCESS {
 		err = Error(ret)
 	} else {
-		defer C.free(unsafe.Pointer(data))
+		defer miscAllocator.Free(unsafe.Pointer(data))
 
 		b = C.GoBytes(unsafe.Pointer(data), C.int(length))
 	}
@@ -47,6 +47,10 @@ func SerializeDatabase(db Database) (b []byte, err error) {
 }
 
 func DeserializeDatabase(data []byte) (Database, error) {
+	if len(data) == 0 {
+		return nil, ErrInvalid
+	}
+
 	var db *C.hs_database_t
 
 	ret := C.hs_deserialize_database((*C.char)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &db)
@@ -61,6 +65,10 @@ func DeserializeDatabase(data []byte) (Database, error) {
 }
 
 func DeserializeDatabaseAt(data []byte, db Database) error {
+	if len(data) == 0 {
+		return ErrInvalid
+	}
+
 	ret := C.hs_deserialize_database_at((*C.char)(unsafe.Pointer(&data[0])), C.size_t(len(data)), db)
 
 	runtime.KeepAlive(data)
@@ -72,6 +80,11 @@ func DeserializeDatabaseAt(data []byte, db Database) error {
 	return nil
 }
 
+// DatabaseAt returns the database placed at the memory, which must be 8-byte aligned.
+func DatabaseAt(mem []byte) Database {
+	return Database(unsafe.Pointer(&mem[0]))
+}
+
 func StreamSize(db Database) (int, error) {
 	var size C.size_t
 
@@ -93,6 +106,10 @@ func DatabaseSize(db Database) (int, error) {
 }
 
 func SerializedDatabaseSize(data []byte) (int, error) {
+	if len(data) == 0 {
+		return 0, ErrInvalid
+	}
+
 	var size C.size_t
 
 	ret := C.hs_serialized_database_size((*C.char)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &size)
@@ -113,12 +130,16 @@ func DatabaseInfo(db Database) (string, error) {
 		return "", Error(ret)
 	}
 
-	defer C.free(unsafe.Pointer(info))
+	defer miscAllocator.Free(unsafe.Pointer(info))
 
 	return C.GoString(info), nil
 }
 
 func SerializedDatabaseInfo(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", ErrInvalid
+	}
+
 	var info *C.char
 
 	ret := C.hs_serialized_database_info((*C.char)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &info)
@@ -129,7 +150,7 @@ func SerializedDatabaseInfo(data []byte) (string, error) {
 		return "", Error(ret)
 	}
 
-	defer C.free(unsafe.Pointer(info))
+	defer miscAllocator.Free(unsafe.Pointer(info))
 
 	return C.GoString(info), nil
 }
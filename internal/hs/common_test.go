@@ -121,6 +121,20 @@ func TestDatabase(t *testing.T) {
 			})
 		})
 
+		Convey("When deserialize an empty database", func() {
+			_, err := hs.DeserializeDatabase(nil)
+			So(err, ShouldEqual, hs.ErrInvalid)
+
+			_, err = hs.SerializedDatabaseSize([]byte{})
+			So(err, ShouldEqual, hs.ErrInvalid)
+
+			_, err = hs.SerializedDatabaseInfo(nil)
+			So(err, ShouldEqual, hs.ErrInvalid)
+
+			buf := make([]byte, 8)
+			So(hs.DeserializeDatabaseAt(nil, hs.DatabaseAt(buf)), ShouldEqual, hs.ErrInvalid)
+		})
+
 		So(hs.FreeDatabase(db), ShouldBeNil)
 	})
 }
@@ -0,0 +1,37 @@
+package hs
+
+import "fmt"
+
+// Position is the origin of a pattern in a source file.
+type Position struct {
+	Filename string // The file name, if any.
+	Line     int    // The line number, starting at 1.
+	Column   int    // The column number, starting at 1 (byte count).
+}
+
+// IsValid reports whether the position is valid.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String returns a string in one of several forms:
+//
+//	file:line:column    valid position with file name
+//	line:column         valid position without file name
+//	file                invalid position with file name
+//	-                   invalid position without file name
+func (p Position) String() string {
+	s := p.Filename
+
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}